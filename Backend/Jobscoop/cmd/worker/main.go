@@ -0,0 +1,46 @@
+package main
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/models"
+	"JobScoop/internal/services"
+	"JobScoop/internal/worker"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// The worker fetches the postings of subscribed companies and roles from the job sources and
+// stores them for the API. It shares the API's database and .env; the API creates the companies,
+// roles and subscriptions tables, so it is started first. Several workers may run at once.
+func main() {
+	db.ConnectDB()
+	defer func() {
+		if db.DB != nil {
+			db.DB.Close()
+			fmt.Println("Database connection closed successfully.")
+		}
+	}()
+
+	if err := services.LoadJobSourcesFromEnv(); err != nil {
+		log.Fatalf("Failed to load job sources: %v", err)
+	}
+	if err := services.LoadScraperConfigsFromEnv(); err != nil {
+		log.Fatalf("Failed to load scraper configs: %v", err)
+	}
+
+	// Create tables
+	models.CreateJobsTable()
+	models.CreateFetchTasksTable()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Println("Worker running")
+	worker.NewWorker().Run(ctx, 5*time.Second)
+	fmt.Println("Worker exiting")
+}
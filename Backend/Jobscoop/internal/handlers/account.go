@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/middleware"
+	"JobScoop/internal/models"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AccountExport is everything JobScoop stores about a user, as returned by /account/export.
+// NotIncluded names what the export was asked to carry but JobScoop does not store.
+type AccountExport struct {
+	ExportedAt    time.Time                   `json:"exported_at"`
+	Profile       AccountExportProfile        `json:"profile"`
+	Subscriptions []AccountExportSubscription `json:"subscriptions"`
+	SentJobs      []AccountExportSentJob      `json:"sent_jobs"`
+	LoginHistory  []LoginActivity             `json:"login_history"`
+	Identities    []AccountExportIdentity     `json:"linked_identities"`
+	APIKeys       []APIKey                    `json:"api_keys"`
+	NotIncluded   map[string]string           `json:"not_included"`
+}
+
+type AccountExportProfile struct {
+	Name             string     `json:"name"`
+	Email            string     `json:"email"`
+	EmailStatus      string     `json:"email_status"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	DigestFrequency  string     `json:"digest_frequency"`
+	LastDigestAt     *time.Time `json:"last_digest_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type AccountExportSubscription struct {
+	CompanyName  string     `json:"company_name"`
+	CareerLinks  []string   `json:"career_links"`
+	FeedLinks    []string   `json:"feed_links"`
+	RoleNames    []string   `json:"role_names"`
+	Active       bool       `json:"active"`
+	InterestTime *time.Time `json:"interest_time"`
+}
+
+// AccountExportSentJob is a posting a job alert digest carried to the user
+type AccountExportSentJob struct {
+	JobID  int       `json:"job_id"`
+	Title  string    `json:"title"`
+	URL    string    `json:"url"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+type AccountExportIdentity struct {
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	LinkedAt    time.Time  `json:"linked_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// AccountExportHandler returns the authenticated user's personal data as a JSON download.
+func AccountExportHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	export, err := buildAccountExport(userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"message": "User not found"}`, http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Database error exporting account"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="jobscoop-export.json"`)
+	json.NewEncoder(w).Encode(export)
+}
+
+func buildAccountExport(userID int) (*AccountExport, error) {
+	export := &AccountExport{
+		ExportedAt:    time.Now().UTC(),
+		Subscriptions: []AccountExportSubscription{},
+		LoginHistory:  []LoginActivity{},
+		Identities:    []AccountExportIdentity{},
+		SentJobs:      []AccountExportSentJob{},
+		APIKeys:       []APIKey{},
+		// There is no saved jobs feature yet, so there are none to export
+		NotIncluded: map[string]string{"saved_jobs": "JobScoop does not store saved jobs"},
+	}
+
+	p := &export.Profile
+	var lastDigestAt sql.NullTime
+	err := db.DB.QueryRow(
+		`SELECT name, email, email_status, totp_enabled, digest_frequency, last_digest_at, created_at FROM users WHERE id = $1`, userID,
+	).Scan(&p.Name, &p.Email, &p.EmailStatus, &p.TwoFactorEnabled, &p.DigestFrequency, &lastDigestAt, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastDigestAt.Valid {
+		p.LastDigestAt = &lastDigestAt.Time
+	}
+
+	// Ids are resolved to names so the archive makes sense on its own
+	rows, err := db.DB.Query(`
+		SELECT c.name,
+			ARRAY(SELECT link FROM career_sites WHERE id = ANY(s.career_site_ids) ORDER BY link),
+			s.feed_links,
+			ARRAY(SELECT name FROM roles WHERE id = ANY(s.role_ids) ORDER BY name),
+			s.active, s.interest_time
+		FROM subscriptions s JOIN companies c ON c.id = s.company_id
+		WHERE s.user_id = $1 ORDER BY c.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sub AccountExportSubscription
+		var interestTime sql.NullTime
+		if err := rows.Scan(&sub.CompanyName, pq.Array(&sub.CareerLinks), pq.Array(&sub.FeedLinks), pq.Array(&sub.RoleNames), &sub.Active, &interestTime); err != nil {
+			return nil, err
+		}
+		if interestTime.Valid {
+			sub.InterestTime = &interestTime.Time
+		}
+		export.Subscriptions = append(export.Subscriptions, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.DB.Query(`
+		SELECT sent_jobs.job_id, jobs.title, jobs.canonical_url, sent_jobs.sent_at
+		FROM sent_jobs JOIN jobs ON jobs.id = sent_jobs.job_id
+		WHERE sent_jobs.user_id = $1 ORDER BY sent_jobs.sent_at DESC, sent_jobs.job_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sent AccountExportSentJob
+		if err := rows.Scan(&sent.JobID, &sent.Title, &sent.URL, &sent.SentAt); err != nil {
+			return nil, err
+		}
+		export.SentJobs = append(export.SentJobs, sent)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.DB.Query(`
+		SELECT ip, user_agent, success, created_at FROM login_attempts
+		WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entry LoginActivity
+		if err := rows.Scan(&entry.IP, &entry.UserAgent, &entry.Success, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		export.LoginHistory = append(export.LoginHistory, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.DB.Query(`
+		SELECT provider, COALESCE(email, ''), created_at, last_login_at FROM identities
+		WHERE user_id = $1 ORDER BY provider`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var identity AccountExportIdentity
+		var lastLoginAt sql.NullTime
+		if err := rows.Scan(&identity.Provider, &identity.Email, &identity.LinkedAt, &lastLoginAt); err != nil {
+			return nil, err
+		}
+		if lastLoginAt.Valid {
+			identity.LastLoginAt = &lastLoginAt.Time
+		}
+		export.Identities = append(export.Identities, identity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Key metadata only; the hashes are secrets of the service, not personal data
+	rows, err = db.DB.Query(`
+		SELECT id, name, prefix, scopes, created_at, expires_at, last_used_at FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key APIKey
+		var expiresAt, lastUsedAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.CreatedAt, &expiresAt, &lastUsedAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			key.ExpiresAt = &expiresAt.Time
+		}
+		if lastUsedAt.Valid {
+			key.LastUsedAt = &lastUsedAt.Time
+		}
+		export.APIKeys = append(export.APIKeys, key)
+	}
+	return export, rows.Err()
+}
+
+// DeleteAccountHandler schedules the authenticated user's account for deletion. The password,
+// and the second factor when enabled, must be entered again. The account is disabled at once
+// and purged in the background once the grace period is over.
+func DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
+		http.Error(w, `{"message": "Password is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	var password string
+	var totpEnabled bool
+	var secret sql.NullString
+	err := db.DB.QueryRow(
+		`SELECT password, totp_enabled, totp_secret FROM users WHERE id = $1 AND deleted_at IS NULL`, userID,
+	).Scan(&password, &totpEnabled, &secret)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"message": "User not found"}`, http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(password), []byte(req.Password)) != nil {
+		http.Error(w, `{"message": "Invalid password"}`, http.StatusUnauthorized)
+		return
+	}
+	if totpEnabled {
+		if req.Code == "" && req.RecoveryCode == "" {
+			http.Error(w, `{"message": "Two-factor code is required"}`, http.StatusBadRequest)
+			return
+		}
+		valid, err := verifySecondFactor(userID, secret.String, req.Code, req.RecoveryCode)
+		if err != nil {
+			http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if !valid {
+			http.Error(w, `{"message": "Invalid password or code"}`, http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// Disabling the account reuses every check that already keeps disabled users out
+	now := time.Now().UTC()
+	if _, err := db.DB.Exec(`UPDATE users SET deleted_at = $1, disabled_at = $1 WHERE id = $2`, now, userID); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := revokeAllSessions(userID); err != nil {
+		http.Error(w, `{"message": "Failed to revoke sessions"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Account scheduled for deletion",
+		"status":      "success",
+		"purge_after": now.Add(models.AccountDeletionGrace()),
+	})
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAccountExportHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	now := time.Now().UTC()
+	mock.ExpectQuery("SELECT name, email, email_status, totp_enabled, digest_frequency, last_digest_at, created_at FROM users WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "email", "email_status", "totp_enabled", "digest_frequency", "last_digest_at", "created_at"}).
+			AddRow("John Doe", "john@example.com", "verified", true, "weekly", now, now))
+	mock.ExpectQuery("SELECT c.name,.*FROM subscriptions s JOIN companies c").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "career_links", "feed_links", "role_names", "active", "interest_time"}).
+			AddRow("Company A", "{https://companyA.com/careers}", "{https://companyA.com/jobs.rss}", "{\"Data Scientist\",\"Software Engineer\"}", true, nil))
+	mock.ExpectQuery("SELECT sent_jobs.job_id, jobs.title, jobs.canonical_url, sent_jobs.sent_at\\s+FROM sent_jobs JOIN jobs").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "title", "canonical_url", "sent_at"}).AddRow(4, "Backend Engineer", "https://boards.greenhouse.io/acme/jobs/4", now))
+	mock.ExpectQuery("SELECT ip, user_agent, success, created_at FROM login_attempts").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"ip", "user_agent", "success", "created_at"}).
+			AddRow("203.0.113.7", "curl/8.0", true, now))
+	mock.ExpectQuery("SELECT provider, COALESCE\\(email, ''\\), created_at, last_login_at FROM identities").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"provider", "email", "created_at", "last_login_at"}))
+	mock.ExpectQuery("SELECT id, name, prefix, scopes, created_at, expires_at, last_used_at FROM api_keys").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "prefix", "scopes", "created_at", "expires_at", "last_used_at"}).
+			AddRow(4, "nightly cron", "jsk_0a1b2c3d", "{jobs:read}", now, nil, nil))
+
+	rr := httptest.NewRecorder()
+	AccountExportHandler(rr, withUserID(httptest.NewRequest(http.MethodGet, "/account/export", nil), 1))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var export AccountExport
+	if err := json.Unmarshal(rr.Body.Bytes(), &export); err != nil {
+		t.Fatalf("Invalid export: %v", err)
+	}
+	if export.Profile.Email != "john@example.com" || !export.Profile.TwoFactorEnabled {
+		t.Errorf("Unexpected profile: %+v", export.Profile)
+	}
+	if len(export.Subscriptions) != 1 || len(export.Subscriptions[0].RoleNames) != 2 || export.Subscriptions[0].CareerLinks[0] != "https://companyA.com/careers" {
+		t.Errorf("Expected names to be resolved, got %+v", export.Subscriptions)
+	}
+	if export.Profile.DigestFrequency != "weekly" || export.Profile.LastDigestAt == nil || len(export.Subscriptions[0].FeedLinks) != 1 {
+		t.Errorf("Expected the digest settings and feed links, got %+v", export)
+	}
+	if len(export.SentJobs) != 1 || export.SentJobs[0].JobID != 4 || export.NotIncluded["saved_jobs"] == "" {
+		t.Errorf("Expected the sent jobs and a note on saved jobs, got %+v and %v", export.SentJobs, export.NotIncluded)
+	}
+	if len(export.LoginHistory) != 1 || len(export.APIKeys) != 1 || export.Identities == nil {
+		t.Errorf("Unexpected history, keys or identities: %+v", export)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteAccountHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("securepassword"), bcrypt.MinCost)
+
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func()
+		expectedCode int
+	}{
+		{
+			name: "Scheduled For Deletion",
+			body: `{"password": "securepassword"}`,
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT password, totp_enabled, totp_secret FROM users WHERE id = \\$1 AND deleted_at IS NULL").
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"password", "totp_enabled", "totp_secret"}).AddRow(string(hashedPassword), false, nil))
+				mock.ExpectExec("UPDATE users SET deleted_at = \\$1, disabled_at = \\$1 WHERE id = \\$2").
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE refresh_tokens SET revoked_at").
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE users SET sessions_revoked_at").
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "Wrong Password",
+			body: `{"password": "wrongpassword"}`,
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT password, totp_enabled, totp_secret FROM users").
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"password", "totp_enabled", "totp_secret"}).AddRow(string(hashedPassword), false, nil))
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name: "Two-Factor Code Missing",
+			body: `{"password": "securepassword"}`,
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT password, totp_enabled, totp_secret FROM users").
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"password", "totp_enabled", "totp_secret"}).AddRow(string(hashedPassword), true, "JBSWY3DPEHPK3PXP"))
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{name: "Missing Password", body: `{}`, mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			rr := httptest.NewRecorder()
+			DeleteAccountHandler(rr, withUserID(httptest.NewRequest(http.MethodPost, "/account/delete", bytes.NewBufferString(tt.body)), 1))
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,490 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/middleware"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const (
+	adminPageSize    = 50
+	maxAdminPageSize = 200
+)
+
+// AdminUser is a user as shown to admins
+type AdminUser struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	EmailStatus string     `json:"email_status"`
+	IsAdmin     bool       `json:"is_admin"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DisabledAt  *time.Time `json:"disabled_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+}
+
+// AdminCatalogEntry is a company or role with the number of subscriptions using it
+type AdminCatalogEntry struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	Subscriptions int    `json:"subscriptions"`
+}
+
+type adminRenameRequest struct {
+	Name string `json:"name"`
+}
+
+type adminMergeRequest struct {
+	IntoID int `json:"into_id"`
+}
+
+// pageParams reads ?limit= and ?offset= with sane bounds
+func pageParams(r *http.Request) (int, int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = adminPageSize
+	}
+	if limit > maxAdminPageSize {
+		limit = maxAdminPageSize
+	}
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// searchPattern turns ?q= into an ILIKE pattern, escaping the wildcards users may type
+func searchPattern(r *http.Request) string {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	q = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q)
+	return "%" + q + "%"
+}
+
+// idFromPath reads the {id} route variable, writing a 400 if it is not a number
+func idFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		http.Error(w, `{"message": "Invalid id"}`, http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func writeAdminSuccess(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"status":  "success",
+	})
+}
+
+// AdminListUsersHandler lists users, optionally filtered by name or email with ?q=.
+func AdminListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset := pageParams(r)
+	rows, err := db.DB.Query(`
+		SELECT id, name, email, email_status, is_admin, created_at, disabled_at, deleted_at FROM users
+		WHERE name ILIKE $1 OR email ILIKE $1
+		ORDER BY id LIMIT $2 OFFSET $3`,
+		searchPattern(r), limit, offset)
+	if err != nil {
+		http.Error(w, `{"message": "Database error fetching users"}`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	users := []AdminUser{}
+	for rows.Next() {
+		var u AdminUser
+		var disabledAt, deletedAt sql.NullTime
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.EmailStatus, &u.IsAdmin, &u.CreatedAt, &disabledAt, &deletedAt); err != nil {
+			http.Error(w, `{"message": "Error scanning users"}`, http.StatusInternalServerError)
+			return
+		}
+		if disabledAt.Valid {
+			u.DisabledAt = &disabledAt.Time
+		}
+		if deletedAt.Valid {
+			u.DeletedAt = &deletedAt.Time
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, `{"message": "Error iterating users"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"users":  users,
+	})
+}
+
+// AdminSetUserDisabledHandler disables (PUT .../disable) or re-enables (PUT .../enable) an account.
+// Disabling also ends every session of the user. Enabling an account its owner deleted restores it,
+// as long as it has not been purged yet.
+func AdminSetUserDisabledHandler(disable bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		targetID, ok := idFromPath(w, r)
+		if !ok {
+			return
+		}
+		if adminID, _ := middleware.UserIDFromContext(r.Context()); adminID == targetID {
+			http.Error(w, `{"message": "Admins cannot disable their own account"}`, http.StatusBadRequest)
+			return
+		}
+
+		query, args := `UPDATE users SET disabled_at = NULL, deleted_at = NULL WHERE id = $1`, []interface{}{targetID}
+		if disable {
+			query, args = `UPDATE users SET disabled_at = $1 WHERE id = $2`, []interface{}{time.Now().UTC(), targetID}
+		}
+		res, err := db.DB.Exec(query, args...)
+		if err != nil {
+			http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if count, err := res.RowsAffected(); err != nil || count == 0 {
+			http.Error(w, `{"message": "User not found"}`, http.StatusNotFound)
+			return
+		}
+
+		if disable {
+			if err := revokeAllSessions(targetID); err != nil {
+				http.Error(w, `{"message": "Failed to revoke sessions"}`, http.StatusInternalServerError)
+				return
+			}
+			writeAdminSuccess(w, "User disabled")
+			return
+		}
+		writeAdminSuccess(w, "User enabled")
+	}
+}
+
+// AdminSetUserAdminHandler grants or removes the admin role with {"is_admin": true|false}.
+func AdminSetUserAdminHandler(w http.ResponseWriter, r *http.Request) {
+	targetID, ok := idFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		IsAdmin *bool `json:"is_admin"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IsAdmin == nil {
+		http.Error(w, `{"message": "is_admin is required"}`, http.StatusBadRequest)
+		return
+	}
+	// Keeps at least one admin around
+	if adminID, _ := middleware.UserIDFromContext(r.Context()); adminID == targetID && !*req.IsAdmin {
+		http.Error(w, `{"message": "Admins cannot remove their own admin role"}`, http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.DB.Exec(`UPDATE users SET is_admin = $1 WHERE id = $2`, *req.IsAdmin, targetID)
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if count, err := res.RowsAffected(); err != nil || count == 0 {
+		http.Error(w, `{"message": "User not found"}`, http.StatusNotFound)
+		return
+	}
+	writeAdminSuccess(w, "User updated")
+}
+
+// AdminListCompaniesHandler lists companies with their subscription counts, filtered with ?q=.
+func AdminListCompaniesHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset := pageParams(r)
+	adminListCatalog(w, `
+		SELECT c.id, c.name, COUNT(s.id) FROM companies c
+		LEFT JOIN subscriptions s ON s.company_id = c.id
+		WHERE c.name ILIKE $1
+		GROUP BY c.id ORDER BY c.name LIMIT $2 OFFSET $3`,
+		"companies", searchPattern(r), limit, offset)
+}
+
+// AdminListRolesHandler lists roles with their subscription counts, filtered with ?q=.
+func AdminListRolesHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset := pageParams(r)
+	adminListCatalog(w, `
+		SELECT ro.id, ro.name, COUNT(s.id) FROM roles ro
+		LEFT JOIN subscriptions s ON ro.id = ANY(s.role_ids)
+		WHERE ro.name ILIKE $1
+		GROUP BY ro.id ORDER BY ro.name LIMIT $2 OFFSET $3`,
+		"roles", searchPattern(r), limit, offset)
+}
+
+func adminListCatalog(w http.ResponseWriter, query, key string, args ...interface{}) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		http.Error(w, `{"message": "Database error fetching `+key+`"}`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entries := []AdminCatalogEntry{}
+	for rows.Next() {
+		var e AdminCatalogEntry
+		if err := rows.Scan(&e.ID, &e.Name, &e.Subscriptions); err != nil {
+			http.Error(w, `{"message": "Error scanning `+key+`"}`, http.StatusInternalServerError)
+			return
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, `{"message": "Error iterating `+key+`"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		key:      entries,
+	})
+}
+
+// adminRename renames a row of companies or roles; a name already in use must be merged instead
+func adminRename(w http.ResponseWriter, r *http.Request, table string) {
+	id, ok := idFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req adminRenameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		http.Error(w, `{"message": "Name is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.DB.Exec(`UPDATE `+table+` SET name = $1 WHERE id = $2`, strings.TrimSpace(req.Name), id)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		http.Error(w, `{"message": "That name is already taken, merge into it instead"}`, http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if count, err := res.RowsAffected(); err != nil || count == 0 {
+		http.Error(w, `{"message": "Not found"}`, http.StatusNotFound)
+		return
+	}
+	writeAdminSuccess(w, "Renamed successfully")
+}
+
+// AdminRenameCompanyHandler fixes the name of a company.
+func AdminRenameCompanyHandler(w http.ResponseWriter, r *http.Request) {
+	adminRename(w, r, "companies")
+}
+
+// AdminRenameRoleHandler fixes the name of a role.
+func AdminRenameRoleHandler(w http.ResponseWriter, r *http.Request) {
+	adminRename(w, r, "roles")
+}
+
+// adminMergeTarget reads the merge request and checks that both rows exist
+func adminMergeTarget(w http.ResponseWriter, r *http.Request, tx *sql.Tx, table string) (int, int, bool) {
+	fromID, ok := idFromPath(w, r)
+	if !ok {
+		return 0, 0, false
+	}
+	var req adminMergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IntoID <= 0 || req.IntoID == fromID {
+		http.Error(w, `{"message": "into_id must name another row"}`, http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	var found int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE id = $1 OR id = $2`, fromID, req.IntoID).Scan(&found); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return 0, 0, false
+	}
+	if found != 2 {
+		http.Error(w, `{"message": "Not found"}`, http.StatusNotFound)
+		return 0, 0, false
+	}
+	return fromID, req.IntoID, true
+}
+
+// adminStatement is one statement of an admin transaction
+type adminStatement struct {
+	query string
+	args  []interface{}
+}
+
+// runAdminTx executes the statements and commits, writing a 500 on the first failure
+func runAdminTx(w http.ResponseWriter, tx *sql.Tx, statements []adminStatement) bool {
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+			http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+			return false
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// companyMergeStatements move everything from company fromID to company intoID and delete fromID.
+// A user subscribed to both keeps one subscription holding the union of career sites, feeds and
+// roles, and inherits the postings found for the one that goes. Stored postings move along (the
+// company delete would cascade to them) and a posting both companies listed from different
+// sources collapses into the older row, the way SaveJob would have linked it.
+func companyMergeStatements(fromID, intoID int) []adminStatement {
+	return []adminStatement{
+		{`UPDATE career_sites SET company_id = $1 WHERE company_id = $2`, []interface{}{intoID, fromID}},
+		{`UPDATE subscriptions t SET
+			career_site_ids = ARRAY(SELECT DISTINCT unnest(t.career_site_ids || s.career_site_ids)),
+			feed_links = ARRAY(SELECT DISTINCT unnest(t.feed_links || s.feed_links)),
+			role_ids = ARRAY(SELECT DISTINCT unnest(t.role_ids || s.role_ids)),
+			active = t.active OR s.active
+		FROM subscriptions s
+		WHERE t.company_id = $1 AND s.company_id = $2 AND s.user_id = t.user_id`, []interface{}{intoID, fromID}},
+		// Links the kept subscription already holds would collide once repointed; their postings
+		// are then left without a link and merge into the kept one below
+		{`DELETE FROM job_sources a USING subscriptions s, subscriptions t, job_sources b
+		WHERE a.subscription_id = s.id AND t.company_id = $1 AND s.company_id = $2 AND s.user_id = t.user_id
+			AND b.subscription_id = t.id AND b.source = a.source AND b.external_id = a.external_id`, []interface{}{intoID, fromID}},
+		{`UPDATE jobs SET subscription_id = t.id FROM subscriptions s, subscriptions t
+		WHERE jobs.subscription_id = s.id AND t.company_id = $1 AND s.company_id = $2 AND s.user_id = t.user_id`, []interface{}{intoID, fromID}},
+		{`UPDATE job_sources SET subscription_id = jobs.subscription_id FROM jobs
+		WHERE job_sources.job_id = jobs.id AND jobs.company_id = $1 AND job_sources.subscription_id <> jobs.subscription_id`, []interface{}{fromID}},
+		{`WITH candidates AS (
+			SELECT DISTINCT ON (m.id) m.id AS from_job, k.id AS into_job
+			FROM jobs m JOIN jobs k ON k.company_id = $1 AND k.subscription_id = m.subscription_id AND k.dedup_key = m.dedup_key
+			WHERE m.company_id = $2 AND NOT EXISTS (
+				SELECT 1 FROM job_sources a JOIN job_sources b ON b.source = a.source
+				WHERE a.job_id = m.id AND b.job_id = k.id)
+			ORDER BY m.id, k.id
+		), pairs AS (
+			SELECT DISTINCT ON (into_job) from_job, into_job FROM candidates ORDER BY into_job, from_job
+		), moved_sources AS (
+			UPDATE job_sources SET job_id = pairs.into_job FROM pairs WHERE job_sources.job_id = pairs.from_job
+		), moved_sent AS (
+			INSERT INTO sent_jobs (user_id, job_id, sent_at)
+			SELECT sent_jobs.user_id, pairs.into_job, sent_jobs.sent_at FROM sent_jobs JOIN pairs ON sent_jobs.job_id = pairs.from_job
+			ON CONFLICT DO NOTHING
+		), kept AS (
+			UPDATE jobs k SET
+				posted_at = COALESCE(k.posted_at, m.posted_at),
+				first_seen = LEAST(k.first_seen, m.first_seen),
+				last_seen = GREATEST(k.last_seen, m.last_seen)
+			FROM pairs JOIN jobs m ON m.id = pairs.from_job
+			WHERE k.id = pairs.into_job
+		)
+		DELETE FROM jobs WHERE id IN (SELECT from_job FROM pairs)`, []interface{}{intoID, fromID}},
+		{`UPDATE jobs SET company_id = $1 WHERE company_id = $2`, []interface{}{intoID, fromID}},
+		// Tasks the target company already has are dropped; the scheduler recreates any still needed
+		{`UPDATE fetch_tasks f SET company_id = $1
+		WHERE f.company_id = $2 AND NOT EXISTS (
+			SELECT 1 FROM fetch_tasks t
+			WHERE t.company_id = $1 AND t.role_id = f.role_id AND t.source = f.source AND t.subscription_id = f.subscription_id)`, []interface{}{intoID, fromID}},
+		{`DELETE FROM fetch_tasks WHERE company_id = $1`, []interface{}{fromID}},
+		{`DELETE FROM subscriptions s USING subscriptions t
+		WHERE t.company_id = $1 AND s.company_id = $2 AND s.user_id = t.user_id`, []interface{}{intoID, fromID}},
+		{`UPDATE subscriptions SET company_id = $1 WHERE company_id = $2`, []interface{}{intoID, fromID}},
+		{`DELETE FROM companies WHERE id = $1`, []interface{}{fromID}},
+	}
+}
+
+// AdminMergeCompanyHandler merges the company in the path into {"into_id": n}.
+func AdminMergeCompanyHandler(w http.ResponseWriter, r *http.Request) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	fromID, intoID, ok := adminMergeTarget(w, r, tx, "companies")
+	if !ok {
+		return
+	}
+	if runAdminTx(w, tx, companyMergeStatements(fromID, intoID)) {
+		writeAdminSuccess(w, "Companies merged")
+	}
+}
+
+// roleMergeStatements replace role fromID by intoID in every subscription and delete fromID
+func roleMergeStatements(fromID, intoID int) []adminStatement {
+	return []adminStatement{
+		{`UPDATE subscriptions SET role_ids = ARRAY(SELECT DISTINCT unnest(array_replace(role_ids, $1::int, $2::int)))
+		WHERE $1::int = ANY(role_ids)`, []interface{}{fromID, intoID}},
+		{`DELETE FROM roles WHERE id = $1`, []interface{}{fromID}},
+	}
+}
+
+// AdminMergeRoleHandler merges the role in the path into {"into_id": n}.
+func AdminMergeRoleHandler(w http.ResponseWriter, r *http.Request) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	fromID, intoID, ok := adminMergeTarget(w, r, tx, "roles")
+	if !ok {
+		return
+	}
+	if runAdminTx(w, tx, roleMergeStatements(fromID, intoID)) {
+		writeAdminSuccess(w, "Roles merged")
+	}
+}
+
+// adminDelete deletes a row after running cleanup statements that take its id as $1
+func adminDelete(w http.ResponseWriter, r *http.Request, table string, cleanup []string) {
+	id, ok := idFromPath(w, r)
+	if !ok {
+		return
+	}
+	tx, err := db.DB.Begin()
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range cleanup {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	res, err := tx.Exec(`DELETE FROM `+table+` WHERE id = $1`, id)
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if count, err := res.RowsAffected(); err != nil || count == 0 {
+		http.Error(w, `{"message": "Not found"}`, http.StatusNotFound)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	writeAdminSuccess(w, "Deleted successfully")
+}
+
+// AdminDeleteCompanyHandler deletes a company with its career sites; subscriptions to it go with it.
+func AdminDeleteCompanyHandler(w http.ResponseWriter, r *http.Request) {
+	adminDelete(w, r, "companies", []string{
+		`DELETE FROM career_sites WHERE company_id = $1`,
+	})
+}
+
+// AdminDeleteRoleHandler deletes a role and removes it from every subscription.
+func AdminDeleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+	adminDelete(w, r, "roles", []string{
+		`UPDATE subscriptions SET role_ids = array_remove(role_ids, $1::int) WHERE $1::int = ANY(role_ids)`,
+	})
+}
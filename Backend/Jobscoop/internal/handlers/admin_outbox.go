@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/models"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// AdminOutboxEmail is a queued email as shown to admins. Bodies are left out, they can carry
+// reset codes and sign-in links.
+type AdminOutboxEmail struct {
+	ID            int        `json:"id"`
+	Recipients    []string   `json:"recipients"`
+	Subject       string     `json:"subject"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     *string    `json:"last_error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at"`
+}
+
+// AdminListEmailsHandler lists the email outbox, newest first, optionally filtered with
+// ?status=pending, sending, sent or dead.
+func AdminListEmailsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.EmailPending, models.EmailSending, models.EmailSent, models.EmailDead:
+	default:
+		http.Error(w, `{"message": "Unknown status"}`, http.StatusBadRequest)
+		return
+	}
+
+	limit, offset := pageParams(r)
+	rows, err := db.DB.Query(`
+		SELECT id, recipients, subject, status, attempts, last_error, next_attempt_at, created_at, sent_at
+		FROM email_outbox
+		WHERE $1 = '' OR status = $1
+		ORDER BY id DESC LIMIT $2 OFFSET $3`,
+		status, limit, offset)
+	if err != nil {
+		http.Error(w, `{"message": "Database error fetching emails"}`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	emails := []AdminOutboxEmail{}
+	for rows.Next() {
+		var e AdminOutboxEmail
+		var lastError sql.NullString
+		var sentAt sql.NullTime
+		if err := rows.Scan(&e.ID, pq.Array(&e.Recipients), &e.Subject, &e.Status, &e.Attempts, &lastError, &e.NextAttemptAt, &e.CreatedAt, &sentAt); err != nil {
+			http.Error(w, `{"message": "Error scanning emails"}`, http.StatusInternalServerError)
+			return
+		}
+		if lastError.Valid {
+			e.LastError = &lastError.String
+		}
+		if sentAt.Valid {
+			e.SentAt = &sentAt.Time
+		}
+		emails = append(emails, e)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, `{"message": "Error iterating emails"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"emails": emails,
+	})
+}
+
+// AdminRequeueEmailHandler gives a dead-lettered (or still pending) email a fresh set of attempts.
+func AdminRequeueEmailHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	requeued, err := models.RequeueEmail(id, time.Now().UTC())
+	if err != nil {
+		http.Error(w, `{"message": "Database error requeueing email"}`, http.StatusInternalServerError)
+		return
+	}
+	if !requeued {
+		http.Error(w, `{"message": "No pending or dead email with this id"}`, http.StatusNotFound)
+		return
+	}
+	writeAdminSuccess(w, "Email requeued")
+}
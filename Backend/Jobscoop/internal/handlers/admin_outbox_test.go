@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+func TestAdminListEmailsHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	now := time.Now().UTC()
+	mock.ExpectQuery("SELECT id, recipients, subject, status, attempts, last_error, next_attempt_at, created_at, sent_at\\s+FROM email_outbox").
+		WithArgs("dead", adminPageSize, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "recipients", "subject", "status", "attempts", "last_error", "next_attempt_at", "created_at", "sent_at"}).
+			AddRow(9, "{john@example.com}", "Confirm your JobScoop email address", "dead", 10, "dial tcp: connection refused", now, now, nil))
+
+	rr := httptest.NewRecorder()
+	AdminListEmailsHandler(rr, httptest.NewRequest(http.MethodGet, "/admin/emails?status=dead", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Emails []AdminOutboxEmail `json:"emails"`
+	}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if len(resp.Emails) != 1 || resp.Emails[0].Recipients[0] != "john@example.com" || resp.Emails[0].LastError == nil || resp.Emails[0].SentAt != nil {
+		t.Errorf("Unexpected emails: %+v", resp.Emails)
+	}
+
+	rr = httptest.NewRecorder()
+	AdminListEmailsHandler(rr, httptest.NewRequest(http.MethodGet, "/admin/emails?status=bounced", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown status, got %d", rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestAdminRequeueEmailHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	tests := []struct {
+		name         string
+		id           string
+		mockSetup    func()
+		expectedCode int
+	}{
+		{
+			name: "Dead Email Requeued",
+			id:   "9",
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE email_outbox SET status = 'pending', attempts = 0, next_attempt_at = \\$1, locked_until = NULL\\s+WHERE id = \\$2 AND status IN \\('pending', 'dead'\\)").
+					WithArgs(sqlmock.AnyArg(), 9).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "Already Sent",
+			id:   "10",
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE email_outbox SET status = 'pending'").
+					WithArgs(sqlmock.AnyArg(), 10).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{name: "Invalid Id", id: "abc", mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/admin/emails/"+tt.id+"/requeue", nil), map[string]string{"id": tt.id})
+			rr := httptest.NewRecorder()
+			AdminRequeueEmailHandler(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
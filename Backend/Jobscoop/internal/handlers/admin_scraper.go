@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"JobScoop/internal/services"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// scrapeCareerPageFunc is replaced in tests
+var scrapeCareerPageFunc = services.ScrapeCareerPage
+
+type adminScraperTestRequest struct {
+	URL    string                  `json:"url"`
+	Config *services.ScraperConfig `json:"config"`
+}
+
+// AdminTestScraperHandler scrapes a career page and previews the postings found, without saving
+// anything. It uses the config in the request, else the one configured for the page's domain,
+// else the heuristics. Like the scraper source it only fetches pages on public addresses.
+func AdminTestScraperHandler(w http.ResponseWriter, r *http.Request) {
+	var req adminScraperTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"message": "Invalid request payload"}`, http.StatusBadRequest)
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, `{"message": "A valid http or https url is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	mode := "request"
+	config := req.Config
+	if config != nil {
+		if err := config.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf(`{"message": %q}`, "Invalid scraper config: "+err.Error()), http.StatusBadRequest)
+			return
+		}
+	} else if c, ok := services.ScraperConfigFor(u.Hostname()); ok {
+		mode = "configured"
+		config = &c
+	} else {
+		mode = "heuristic"
+	}
+
+	jobs, err := scrapeCareerPageFunc(r.Context(), nil, u.String(), config)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"message": %q}`, "Failed to scrape page: "+err.Error()), http.StatusBadGateway)
+		return
+	}
+	if jobs == nil {
+		jobs = []services.Job{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"mode":   mode,
+		"config": config,
+		"count":  len(jobs),
+		"jobs":   jobs,
+	})
+}
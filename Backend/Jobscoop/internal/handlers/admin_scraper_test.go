@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"JobScoop/internal/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminTestScraperHandler(t *testing.T) {
+	originalScrape := scrapeCareerPageFunc
+	defer func() { scrapeCareerPageFunc = originalScrape }()
+	defer services.SetScraperConfigs()
+	services.SetScraperConfigs(services.ScraperConfig{Domain: "acme.com", Jobs: "li.opening"})
+
+	tests := []struct {
+		name       string
+		body       string
+		scrapeErr  error
+		wantStatus int
+		wantMode   string
+		wantJobs   string
+	}{
+		{
+			name:       "config from the request",
+			body:       `{"url": "https://careers.globex.com/open-roles", "config": {"jobs": "tr.job", "title": "td a", "next_page": "a[rel=next]"}}`,
+			wantStatus: http.StatusOK,
+			wantMode:   "request",
+			wantJobs:   "tr.job",
+		},
+		{
+			name:       "configured domain",
+			body:       `{"url": "https://www.acme.com/careers"}`,
+			wantStatus: http.StatusOK,
+			wantMode:   "configured",
+			wantJobs:   "li.opening",
+		},
+		{
+			name:       "heuristics",
+			body:       `{"url": "https://initech.com/jobs"}`,
+			wantStatus: http.StatusOK,
+			wantMode:   "heuristic",
+		},
+		{
+			name:       "invalid selector",
+			body:       `{"url": "https://initech.com/jobs", "config": {"jobs": "li["}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not a web page",
+			body:       `{"url": "file:///etc/passwd"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "page unavailable",
+			body:       `{"url": "https://initech.com/jobs"}`,
+			scrapeErr:  errors.New(`GET "https://initech.com/jobs": status 503`),
+			wantStatus: http.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotConfig *services.ScraperConfig
+			scrapeCareerPageFunc = func(ctx context.Context, client *http.Client, link string, config *services.ScraperConfig) ([]services.Job, error) {
+				gotConfig = config
+				if tt.scrapeErr != nil {
+					return nil, tt.scrapeErr
+				}
+				return []services.Job{{Source: "scraper", Title: "Backend Engineer", URL: link + "/backend-engineer"}}, nil
+			}
+
+			rr := httptest.NewRecorder()
+			AdminTestScraperHandler(rr, httptest.NewRequest(http.MethodPost, "/admin/scraper/test", strings.NewReader(tt.body)))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			var resp struct {
+				Message string         `json:"message"`
+				Mode    string         `json:"mode"`
+				Count   int            `json:"count"`
+				Jobs    []services.Job `json:"jobs"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("Expected a JSON response: %v", err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if resp.Mode != tt.wantMode || resp.Count != 1 || len(resp.Jobs) != 1 {
+				t.Errorf("Unexpected response: %+v", resp)
+			}
+			if (gotConfig == nil) != (tt.wantJobs == "") || (gotConfig != nil && gotConfig.Jobs != tt.wantJobs) {
+				t.Errorf("Scraped with config %+v; want jobs selector %q", gotConfig, tt.wantJobs)
+			}
+		})
+	}
+}
+
+func TestAdminTestScraperHandlerRefusesPrivateAddresses(t *testing.T) {
+	var requested bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer server.Close()
+
+	rr := httptest.NewRecorder()
+	AdminTestScraperHandler(rr, httptest.NewRequest(http.MethodPost, "/admin/scraper/test", strings.NewReader(`{"url": "`+server.URL+`/jobs"}`)))
+	if rr.Code != http.StatusBadGateway || !strings.Contains(rr.Body.String(), "not a public address") {
+		t.Errorf("Expected the loopback page to be refused, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if requested {
+		t.Error("Expected no request to reach the server")
+	}
+}
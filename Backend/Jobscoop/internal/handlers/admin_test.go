@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+func TestAdminListUsersHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	now := time.Now().UTC()
+	mock.ExpectQuery("SELECT id, name, email, email_status, is_admin, created_at, disabled_at, deleted_at FROM users").
+		WithArgs(`%john\_d%`, 10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "email_status", "is_admin", "created_at", "disabled_at", "deleted_at"}).
+			AddRow(3, "John Doe", "john_d@example.com", "verified", false, now, nil, nil).
+			AddRow(4, "John Dee", "john_dee@example.com", "pending", false, now, now, now))
+
+	rr := httptest.NewRecorder()
+	AdminListUsersHandler(rr, withUserID(httptest.NewRequest(http.MethodGet, "/admin/users?q=john_d&limit=10&offset=20", nil), 1))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		Users []AdminUser `json:"users"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if len(response.Users) != 2 || response.Users[0].DisabledAt != nil || response.Users[1].DisabledAt == nil || response.Users[1].DeletedAt == nil {
+		t.Errorf("Unexpected users: %+v", response.Users)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestAdminSetUserDisabledHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	tests := []struct {
+		name         string
+		disable      bool
+		targetID     string
+		mockSetup    func()
+		expectedCode int
+	}{
+		{
+			name:     "Disable Revokes Sessions",
+			disable:  true,
+			targetID: "3",
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET disabled_at = \\$1 WHERE id = \\$2").
+					WithArgs(sqlmock.AnyArg(), 3).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE refresh_tokens SET revoked_at").
+					WithArgs(sqlmock.AnyArg(), 3).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec("UPDATE users SET sessions_revoked_at").
+					WithArgs(sqlmock.AnyArg(), 3).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:     "Enable Also Cancels Deletion",
+			targetID: "3",
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET disabled_at = NULL, deleted_at = NULL WHERE id = \\$1").
+					WithArgs(3).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:     "Unknown User",
+			disable:  true,
+			targetID: "99",
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET disabled_at").
+					WithArgs(sqlmock.AnyArg(), 99).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{name: "Cannot Disable Self", disable: true, targetID: "1", mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+		{name: "Invalid Id", disable: true, targetID: "abc", mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/admin/users/"+tt.targetID+"/disable", nil), map[string]string{"id": tt.targetID})
+			rr := httptest.NewRecorder()
+			AdminSetUserDisabledHandler(tt.disable)(rr, withUserID(req, 1))
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestAdminSetUserAdminHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	tests := []struct {
+		name         string
+		targetID     string
+		body         string
+		mockSetup    func()
+		expectedCode int
+	}{
+		{
+			name:     "Promote",
+			targetID: "3",
+			body:     `{"is_admin": true}`,
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET is_admin = \\$1 WHERE id = \\$2").
+					WithArgs(true, 3).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCode: http.StatusOK,
+		},
+		{name: "Cannot Demote Self", targetID: "1", body: `{"is_admin": false}`, mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+		{name: "Missing Flag", targetID: "3", body: `{}`, mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/admin/users/"+tt.targetID+"/admin", bytes.NewBufferString(tt.body)), map[string]string{"id": tt.targetID})
+			rr := httptest.NewRecorder()
+			AdminSetUserAdminHandler(rr, withUserID(req, 1))
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestAdminListCompaniesHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	mock.ExpectQuery("SELECT c.id, c.name, COUNT\\(s.id\\) FROM companies c").
+		WithArgs("%goo%", adminPageSize, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "count"}).
+			AddRow(1, "Google", 12).
+			AddRow(7, "google", 1))
+
+	rr := httptest.NewRecorder()
+	AdminListCompaniesHandler(rr, withUserID(httptest.NewRequest(http.MethodGet, "/admin/companies?q=goo", nil), 1))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		Companies []AdminCatalogEntry `json:"companies"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if len(response.Companies) != 2 || response.Companies[0].Subscriptions != 12 {
+		t.Errorf("Unexpected companies: %+v", response.Companies)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestAdminRenameCompanyHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	mock.ExpectExec("UPDATE companies SET name = \\$1 WHERE id = \\$2").
+		WithArgs("Google", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/admin/companies/7", bytes.NewBufferString(`{"name": " Google "}`)), map[string]string{"id": "7"})
+	rr := httptest.NewRecorder()
+	AdminRenameCompanyHandler(rr, withUserID(req, 1))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 on rename, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Renaming onto an existing name must go through a merge
+	mock.ExpectExec("UPDATE companies SET name").
+		WithArgs("Google", 8).
+		WillReturnError(&pq.Error{Code: "23505"})
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/admin/companies/8", bytes.NewBufferString(`{"name": "Google"}`)), map[string]string{"id": "8"})
+	rr = httptest.NewRecorder()
+	AdminRenameCompanyHandler(rr, withUserID(req, 1))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a taken name, got %d", rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestAdminMergeCompanyHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func()
+		expectedCode int
+	}{
+		{
+			name: "Merge",
+			body: `{"into_id": 1}`,
+			mockSetup: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM companies WHERE id = \\$1 OR id = \\$2").
+					WithArgs(7, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				mock.ExpectExec("UPDATE career_sites SET company_id = \\$1 WHERE company_id = \\$2").
+					WithArgs(1, 7).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectExec("UPDATE subscriptions t SET").
+					WithArgs(1, 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("DELETE FROM job_sources a USING subscriptions s, subscriptions t, job_sources b").
+					WithArgs(1, 7).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("UPDATE jobs SET subscription_id = t.id FROM subscriptions s, subscriptions t").
+					WithArgs(1, 7).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec("UPDATE job_sources SET subscription_id = jobs.subscription_id FROM jobs").
+					WithArgs(7).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec("WITH candidates AS .+DELETE FROM jobs WHERE id IN \\(SELECT from_job FROM pairs\\)").
+					WithArgs(1, 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				// The stored postings must move before the company delete cascades to them
+				mock.ExpectExec("UPDATE jobs SET company_id = \\$1 WHERE company_id = \\$2").
+					WithArgs(1, 7).
+					WillReturnResult(sqlmock.NewResult(0, 12))
+				mock.ExpectExec("UPDATE fetch_tasks f SET company_id = \\$1").
+					WithArgs(1, 7).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectExec("DELETE FROM fetch_tasks WHERE company_id = \\$1").
+					WithArgs(7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("DELETE FROM subscriptions s USING subscriptions t").
+					WithArgs(1, 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE subscriptions SET company_id = \\$1 WHERE company_id = \\$2").
+					WithArgs(1, 7).
+					WillReturnResult(sqlmock.NewResult(0, 4))
+				mock.ExpectExec("DELETE FROM companies WHERE id = \\$1").
+					WithArgs(7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "Unknown Target",
+			body: `{"into_id": 99}`,
+			mockSetup: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM companies").
+					WithArgs(7, 99).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mock.ExpectRollback()
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name: "Into Itself",
+			body: `{"into_id": 7}`,
+			mockSetup: func() {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/admin/companies/7/merge", bytes.NewBufferString(tt.body)), map[string]string{"id": "7"})
+			rr := httptest.NewRecorder()
+			AdminMergeCompanyHandler(rr, withUserID(req, 1))
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestAdminMergeAndDeleteRole(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM roles WHERE id = \\$1 OR id = \\$2").
+		WithArgs(5, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectExec("UPDATE subscriptions SET role_ids = ARRAY\\(SELECT DISTINCT unnest\\(array_replace").
+		WithArgs(5, 2).
+		WillReturnResult(sqlmock.NewResult(0, 6))
+	mock.ExpectExec("DELETE FROM roles WHERE id = \\$1").
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/admin/roles/5/merge", bytes.NewBufferString(`{"into_id": 2}`)), map[string]string{"id": "5"})
+	rr := httptest.NewRecorder()
+	AdminMergeRoleHandler(rr, withUserID(req, 1))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 on merge, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE subscriptions SET role_ids = array_remove\\(role_ids, \\$1::int\\)").
+		WithArgs(6).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM roles WHERE id = \\$1").
+		WithArgs(6).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/admin/roles/6", nil), map[string]string{"id": "6"})
+	rr = httptest.NewRecorder()
+	AdminDeleteRoleHandler(rr, withUserID(req, 1))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 on delete, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/middleware"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const maxAPIKeysPerUser = 20
+
+type APIKeyRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty"`
+}
+
+// APIKey is a personal API key as listed to its owner; the secret itself is never returned again.
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// newAPIKey returns a key of the form jsk_<prefix>_<secret> and its visible prefix
+func newAPIKey() (string, string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix := middleware.APIKeyPrefix + hex.EncodeToString(b)
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return prefix + "_" + secret, prefix, nil
+}
+
+func validScopes(scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, scope := range scopes {
+		known := false
+		for _, s := range middleware.APIKeyScopes {
+			if scope == s {
+				known = true
+			}
+		}
+		if !known {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateAPIKeyHandler creates a personal API key. The key is only shown in this response.
+func CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"message": "Invalid request payload"}`, http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		http.Error(w, `{"message": "Name is required and must be at most 100 characters"}`, http.StatusBadRequest)
+		return
+	}
+	if !validScopes(req.Scopes) {
+		http.Error(w, `{"message": "Scopes must be one or more of: `+strings.Join(middleware.APIKeyScopes, ", ")+`"}`, http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInDays < 0 {
+		http.Error(w, `{"message": "expires_in_days must be positive"}`, http.StatusBadRequest)
+		return
+	}
+
+	var active int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL`, userID).Scan(&active); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if active >= maxAPIKeysPerUser {
+		http.Error(w, `{"message": "Too many API keys, revoke one first"}`, http.StatusConflict)
+		return
+	}
+
+	key, prefix, err := newAPIKey()
+	if err != nil {
+		http.Error(w, `{"message": "Error generating key"}`, http.StatusInternalServerError)
+		return
+	}
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().UTC().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	created := APIKey{Name: req.Name, Prefix: prefix, Scopes: req.Scopes, ExpiresAt: expiresAt}
+	err = db.DB.QueryRow(`
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		userID, req.Name, prefix, hashToken(key), pq.Array(req.Scopes), expiresAt,
+	).Scan(&created.ID, &created.CreatedAt)
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "API key created. Copy it now, it will not be shown again.",
+		"status":  "success",
+		"key":     key,
+		"api_key": created,
+	})
+}
+
+// ListAPIKeysHandler lists the authenticated user's active API keys.
+func ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT id, name, prefix, scopes, created_at, expires_at, last_used_at FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC`, userID)
+	if err != nil {
+		http.Error(w, `{"message": "Database error fetching API keys"}`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		var expiresAt, lastUsedAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.CreatedAt, &expiresAt, &lastUsedAt); err != nil {
+			http.Error(w, `{"message": "Error scanning API keys"}`, http.StatusInternalServerError)
+			return
+		}
+		if expiresAt.Valid {
+			key.ExpiresAt = &expiresAt.Time
+		}
+		if lastUsedAt.Valid {
+			key.LastUsedAt = &lastUsedAt.Time
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, `{"message": "Error iterating API keys"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"api_keys": keys,
+	})
+}
+
+// RenameAPIKeyHandler changes the name of one of the authenticated user's API keys.
+func RenameAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	keyID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"message": "Invalid API key id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"message": "Invalid request payload"}`, http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		http.Error(w, `{"message": "Name is required and must be at most 100 characters"}`, http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.DB.Exec(
+		`UPDATE api_keys SET name = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`,
+		req.Name, keyID, userID,
+	)
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if count, err := res.RowsAffected(); err != nil || count == 0 {
+		http.Error(w, `{"message": "API key not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "API key renamed",
+		"status":  "success",
+	})
+}
+
+// RevokeAPIKeyHandler revokes one of the authenticated user's API keys. It stops working immediately.
+func RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	keyID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"message": "Invalid API key id"}`, http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.DB.Exec(
+		`UPDATE api_keys SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`,
+		time.Now().UTC(), keyID, userID,
+	)
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if count, err := res.RowsAffected(); err != nil || count == 0 {
+		http.Error(w, `{"message": "API key not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "API key revoked",
+		"status":  "success",
+	})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+func TestCreateAPIKeyHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func()
+		expectedCode int
+	}{
+		{
+			name: "Valid Key",
+			body: `{"name": "nightly cron", "scopes": ["jobs:read"], "expires_in_days": 90}`,
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM api_keys WHERE user_id = \\$1 AND revoked_at IS NULL").
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mock.ExpectQuery("INSERT INTO api_keys").
+					WithArgs(1, "nightly cron", sqlmock.AnyArg(), sqlmock.AnyArg(), pq.Array([]string{"jobs:read"}), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(4, time.Now()))
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name: "Too Many Keys",
+			body: `{"name": "another", "scopes": ["subscriptions:write"]}`,
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM api_keys").
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(maxAPIKeysPerUser))
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{name: "Unknown Scope", body: `{"name": "admin", "scopes": ["admin"]}`, mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+		{name: "No Scopes", body: `{"name": "empty", "scopes": []}`, mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+		{name: "Missing Name", body: `{"scopes": ["jobs:read"]}`, mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := withUserID(httptest.NewRequest(http.MethodPost, "/api-keys", bytes.NewBufferString(tt.body)), 1)
+			rr := httptest.NewRecorder()
+			CreateAPIKeyHandler(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+			if tt.expectedCode == http.StatusCreated {
+				var response struct {
+					Key    string `json:"key"`
+					APIKey APIKey `json:"api_key"`
+				}
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				if !strings.HasPrefix(response.Key, response.APIKey.Prefix+"_") || !strings.HasPrefix(response.APIKey.Prefix, "jsk_") {
+					t.Errorf("Expected the key to start with its visible prefix, got %q and %q", response.Key, response.APIKey.Prefix)
+				}
+				if response.APIKey.ExpiresAt == nil {
+					t.Error("Expected an expiry")
+				}
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestListAPIKeysHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	now := time.Now().UTC()
+	mock.ExpectQuery("SELECT id, name, prefix, scopes, created_at, expires_at, last_used_at FROM api_keys").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "prefix", "scopes", "created_at", "expires_at", "last_used_at"}).
+			AddRow(4, "nightly cron", "jsk_0a1b2c3d", "{jobs:read,subscriptions:write}", now, nil, now).
+			AddRow(5, "unused", "jsk_99887766", "{jobs:read}", now, nil, nil))
+
+	rr := httptest.NewRecorder()
+	ListAPIKeysHandler(rr, withUserID(httptest.NewRequest(http.MethodGet, "/api-keys", nil), 1))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		APIKeys []APIKey `json:"api_keys"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if len(response.APIKeys) != 2 || len(response.APIKeys[0].Scopes) != 2 || response.APIKeys[0].LastUsedAt == nil || response.APIKeys[1].LastUsedAt != nil {
+		t.Errorf("Unexpected keys: %+v", response.APIKeys)
+	}
+	if strings.Contains(rr.Body.String(), "key_hash") {
+		t.Error("The key hash must not be listed")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRenameAndRevokeAPIKey(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	mock.ExpectExec("UPDATE api_keys SET name = \\$1 WHERE id = \\$2 AND user_id = \\$3 AND revoked_at IS NULL").
+		WithArgs("weekly cron", 4, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/api-keys/4", bytes.NewBufferString(`{"name": "weekly cron"}`)), map[string]string{"id": "4"})
+	rr := httptest.NewRecorder()
+	RenameAPIKeyHandler(rr, withUserID(req, 1))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 on rename, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Keys of other users are not found
+	mock.ExpectExec("UPDATE api_keys SET revoked_at = \\$1 WHERE id = \\$2 AND user_id = \\$3 AND revoked_at IS NULL").
+		WithArgs(sqlmock.AnyArg(), 9, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api-keys/9", nil), map[string]string{"id": "9"})
+	rr = httptest.NewRecorder()
+	RevokeAPIKeyHandler(rr, withUserID(req, 1))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's key, got %d", rr.Code)
+	}
+
+	mock.ExpectExec("UPDATE api_keys SET revoked_at").
+		WithArgs(sqlmock.AnyArg(), 4, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api-keys/4", nil), map[string]string{"id": "4"})
+	rr = httptest.NewRecorder()
+	RevokeAPIKeyHandler(rr, withUserID(req, 1))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 on revoke, got %d", rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
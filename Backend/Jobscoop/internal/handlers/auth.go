@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/middleware"
+	"JobScoop/internal/services"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+var errAccountDisabled = errors.New("account is disabled")
+
+// randomToken returns n random bytes encoded as URL-safe base64
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex SHA-256 of a high-entropy token for storage and lookup
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createRefreshToken stores a new refresh token in the given family and returns the raw token
+// with the user's session generation. An empty familyID starts a new family (a new login session).
+func createRefreshToken(userID int, familyID string) (string, int, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", 0, err
+	}
+	if familyID == "" {
+		familyID, err = randomToken(16)
+		if err != nil {
+			return "", 0, err
+		}
+	}
+
+	// Disabled accounts get no new sessions, whichever way they signed in
+	var generation int
+	err = db.DB.QueryRow(`
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		SELECT id, $2, $3, $4 FROM users WHERE id = $1 AND disabled_at IS NULL
+		RETURNING (SELECT session_generation FROM users WHERE id = $1)`,
+		userID, familyID, hashToken(token), time.Now().UTC().Add(refreshTokenTTL),
+	).Scan(&generation)
+	if err == sql.ErrNoRows {
+		return "", 0, errAccountDisabled
+	} else if err != nil {
+		return "", 0, err
+	}
+	return token, generation, nil
+}
+
+// issueTokenPair creates an access token and a refresh token for the user
+func issueTokenPair(userID int, familyID string) (string, string, error) {
+	refreshToken, generation, err := createRefreshToken(userID, familyID)
+	if err != nil {
+		return "", "", err
+	}
+	accessToken, err := services.IssueAccessToken(userID, generation)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshHandler exchanges a refresh token for a new access/refresh token pair.
+// Each refresh token can be used once; presenting an already rotated token revokes its whole family.
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"message": "Invalid request payload"}`, http.StatusBadRequest)
+		return
+	}
+	if req.RefreshToken == "" {
+		http.Error(w, `{"message": "Refresh token is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	var (
+		id        int
+		userID    int
+		familyID  string
+		expiresAt time.Time
+		rotatedAt sql.NullTime
+		revokedAt sql.NullTime
+	)
+	err := db.DB.QueryRow(
+		`SELECT id, user_id, family_id, expires_at, rotated_at, revoked_at FROM refresh_tokens WHERE token_hash = $1`,
+		hashToken(req.RefreshToken),
+	).Scan(&id, &userID, &familyID, &expiresAt, &rotatedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"message": "Invalid refresh token"}`, http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if revokedAt.Valid {
+		http.Error(w, `{"message": "Refresh token has been revoked"}`, http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	// A rotated token showing up again means it was copied; kill the whole session
+	if rotatedAt.Valid {
+		if err := revokeRefreshFamily(familyID, now); err != nil {
+			log.Printf("Failed to revoke refresh token family %s after reuse: %v", familyID, err)
+			http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, `{"message": "Refresh token reuse detected"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if now.After(expiresAt) {
+		http.Error(w, `{"message": "Refresh token has expired"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// Mark the token as used; losing this race to a concurrent refresh is also treated as reuse
+	res, err := db.DB.Exec(`UPDATE refresh_tokens SET rotated_at = $1 WHERE id = $2 AND rotated_at IS NULL`, now, id)
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if count, err := res.RowsAffected(); err != nil || count != 1 {
+		if err := revokeRefreshFamily(familyID, now); err != nil {
+			log.Printf("Failed to revoke refresh token family %s after reuse: %v", familyID, err)
+			http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, `{"message": "Refresh token reuse detected"}`, http.StatusUnauthorized)
+		return
+	}
+
+	accessToken, refreshToken, err := issueTokenPair(userID, familyID)
+	if err == errAccountDisabled {
+		http.Error(w, `{"message": "This account has been disabled"}`, http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Error issuing tokens"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Token refreshed successfully",
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"userid":        userID,
+	})
+}
+
+// revokeRefreshFamily revokes every refresh token descended from the same login
+func revokeRefreshFamily(familyID string, now time.Time) error {
+	_, err := db.DB.Exec(`UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`, now, familyID)
+	return err
+}
+
+// revokeAccessToken adds the token's jti to the deny list until it would have expired anyway
+func revokeAccessToken(claims *services.Claims) error {
+	expiresAt := time.Now().UTC().Add(services.AccessTokenTTL)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time.UTC()
+	}
+	_, err := db.DB.Exec(
+		`INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`,
+		claims.ID, expiresAt,
+	)
+	return err
+}
+
+// revokeAllSessions revokes every refresh token of the user and every access token issued until now,
+// by moving the user on to the next session generation.
+func revokeAllSessions(userID int) error {
+	now := time.Now().UTC()
+	if _, err := db.DB.Exec(`UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`, now, userID); err != nil {
+		return err
+	}
+	_, err := db.DB.Exec(`UPDATE users SET sessions_revoked_at = $1, session_generation = session_generation + 1 WHERE id = $2`, now, userID)
+	return err
+}
+
+// LogoutHandler revokes the presented access token and, if given, the session of the refresh token.
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// The refresh token is optional so that clients which lost it can still log out
+	var req RefreshRequest
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&req)
+	}
+
+	if req.RefreshToken != "" {
+		_, err := db.DB.Exec(`
+			UPDATE refresh_tokens SET revoked_at = $1
+			WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $2 AND user_id = $3)
+			AND revoked_at IS NULL`,
+			time.Now().UTC(), hashToken(req.RefreshToken), claims.UserID)
+		if err != nil {
+			http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := revokeAccessToken(claims); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Logged out successfully",
+		"status":  "success",
+	})
+}
+
+// LogoutAllHandler revokes every session of the authenticated user.
+func LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if err := revokeAllSessions(userID); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Logged out of all sessions",
+		"status":  "success",
+	})
+}
+
+// JWKSHandler publishes the public keys that verify JobScoop tokens.
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := services.JWKS()
+	if err != nil {
+		http.Error(w, `{"message": "Signing keys unavailable"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": keys,
+	})
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRefreshHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	columns := []string{"id", "user_id", "family_id", "expires_at", "rotated_at", "revoked_at"}
+	future := time.Now().UTC().Add(time.Hour)
+
+	tests := []struct {
+		name         string
+		refreshToken string
+		mockSetup    func()
+		expectedCode int
+		expectedMsg  string
+	}{
+		{
+			name:         "Successful Rotation",
+			refreshToken: "good-token",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT id, user_id, family_id, expires_at, rotated_at, revoked_at FROM refresh_tokens WHERE token_hash = \\$1").
+					WithArgs(hashToken("good-token")).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, "family-1", future, nil, nil))
+				mock.ExpectExec("UPDATE refresh_tokens SET rotated_at = \\$1 WHERE id = \\$2 AND rotated_at IS NULL").
+					WithArgs(sqlmock.AnyArg(), 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("INSERT INTO refresh_tokens").
+					WithArgs(1, "family-1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"session_generation"}).AddRow(0))
+			},
+			expectedCode: http.StatusOK,
+			expectedMsg:  "Token refreshed successfully",
+		},
+		{
+			name:         "Reused Token Revokes Family",
+			refreshToken: "old-token",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT id, user_id, family_id, expires_at, rotated_at, revoked_at FROM refresh_tokens").
+					WithArgs(hashToken("old-token")).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, "family-1", future, time.Now().UTC(), nil))
+				mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = \\$1 WHERE family_id = \\$2").
+					WithArgs(sqlmock.AnyArg(), "family-1").
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedMsg:  "Refresh token reuse detected",
+		},
+		{
+			name:         "Reuse With Failed Revocation",
+			refreshToken: "old-token",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT id, user_id, family_id, expires_at, rotated_at, revoked_at FROM refresh_tokens").
+					WithArgs(hashToken("old-token")).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, "family-1", future, time.Now().UTC(), nil))
+				mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = \\$1 WHERE family_id = \\$2").
+					WithArgs(sqlmock.AnyArg(), "family-1").
+					WillReturnError(sql.ErrConnDone)
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedMsg:  "Database error",
+		},
+		{
+			name:         "Revoked Token",
+			refreshToken: "revoked-token",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT id, user_id, family_id, expires_at, rotated_at, revoked_at FROM refresh_tokens").
+					WithArgs(hashToken("revoked-token")).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, "family-1", future, nil, time.Now().UTC()))
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedMsg:  "Refresh token has been revoked",
+		},
+		{
+			name:         "Expired Token",
+			refreshToken: "expired-token",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT id, user_id, family_id, expires_at, rotated_at, revoked_at FROM refresh_tokens").
+					WithArgs(hashToken("expired-token")).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 1, "family-1", time.Now().UTC().Add(-time.Hour), nil, nil))
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedMsg:  "Refresh token has expired",
+		},
+		{
+			name:         "Unknown Token",
+			refreshToken: "unknown-token",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT id, user_id, family_id, expires_at, rotated_at, revoked_at FROM refresh_tokens").
+					WithArgs(hashToken("unknown-token")).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedMsg:  "Invalid refresh token",
+		},
+		{
+			name:         "Missing Token",
+			mockSetup:    func() {},
+			expectedCode: http.StatusBadRequest,
+			expectedMsg:  "Refresh token is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			body, _ := json.Marshal(RefreshRequest{RefreshToken: tt.refreshToken})
+			req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewBuffer(body))
+			rr := httptest.NewRecorder()
+			RefreshHandler(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, rr.Code)
+			}
+
+			var response map[string]interface{}
+			json.Unmarshal(rr.Body.Bytes(), &response)
+			if response["message"] != tt.expectedMsg {
+				t.Errorf("Expected message '%s', got '%v'", tt.expectedMsg, response["message"])
+			}
+			if rr.Code == http.StatusOK && (response["token"] == "" || response["refresh_token"] == "") {
+				t.Errorf("Expected a new token pair, got %v", response)
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/mailer"
+	"JobScoop/internal/middleware"
+	"JobScoop/internal/services"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Digest frequencies a user can choose; off is also what the unsubscribe link sets.
+const (
+	DigestInstant = "instant"
+	DigestDaily   = "daily"
+	DigestWeekly  = "weekly"
+	DigestOff     = "off"
+)
+
+// unsubscribeTokenTTL keeps the link in an old digest working for a while
+const unsubscribeTokenTTL = 90 * 24 * time.Hour
+
+// DigestSettings is how often the authenticated user gets job alert digests
+type DigestSettings struct {
+	Frequency    string     `json:"frequency"`
+	LastDigestAt *time.Time `json:"last_digest_at,omitempty"`
+}
+
+// GetDigestSettingsHandler returns the authenticated user's digest frequency.
+func GetDigestSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var settings DigestSettings
+	var lastDigestAt sql.NullTime
+	err := db.DB.QueryRow(`SELECT digest_frequency, last_digest_at FROM users WHERE id = $1`, userID).Scan(&settings.Frequency, &lastDigestAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"message": "User not found"}`, http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if lastDigestAt.Valid {
+		settings.LastDigestAt = &lastDigestAt.Time
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"digest": settings,
+	})
+}
+
+// UpdateDigestSettingsHandler sets the authenticated user's digest frequency: instant, daily, weekly or off.
+func UpdateDigestSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req DigestSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"message": "Invalid request payload"}`, http.StatusBadRequest)
+		return
+	}
+	switch req.Frequency {
+	case DigestInstant, DigestDaily, DigestWeekly, DigestOff:
+	default:
+		http.Error(w, `{"message": "Frequency must be instant, daily, weekly or off"}`, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.DB.Exec(`UPDATE users SET digest_frequency = $1 WHERE id = $2`, req.Frequency, userID); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Digest settings updated",
+		"status":  "success",
+	})
+}
+
+// unsubscribePage asks to confirm the unsubscribe, so that mail scanners and link prefetchers
+// opening the link do not turn digests off
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Unsubscribe from JobScoop</title></head>
+<body>
+<p>Stop getting job alert digests from JobScoop?</p>
+<form method="post" action="{{.}}"><button type="submit">Unsubscribe</button></form>
+</body></html>
+`))
+
+// UnsubscribeHandler turns digests off on a POST: the confirmation form, a one-click
+// List-Unsubscribe request or a JSON body with the token. A GET only shows the confirmation form.
+func UnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" && r.Method == http.MethodPost {
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"message": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+		token = req.Token
+	}
+	if token == "" {
+		http.Error(w, `{"message": "Unsubscribe token is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, _, err := services.ParseEmailToken(token, services.DigestUnsubscribePurpose)
+	if err != nil {
+		http.Error(w, `{"message": "Invalid or expired unsubscribe link"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		unsubscribePage.Execute(w, apiBaseURL()+"/unsubscribe?token="+url.QueryEscape(token))
+		return
+	}
+
+	// The link keeps working after an email change, it only ever turns mail off
+	res, err := db.DB.Exec(`UPDATE users SET digest_frequency = 'off' WHERE id = $1`, userID)
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if count, err := res.RowsAffected(); err != nil || count == 0 {
+		http.Error(w, `{"message": "Invalid or expired unsubscribe link"}`, http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "You have been unsubscribed from job alerts",
+		"status":  "success",
+	})
+}
+
+// digestJob is one posting as shown in a digest
+type digestJob struct {
+	ID       int
+	Title    string
+	Location string
+	URL      string
+}
+
+// digestGroup holds the new postings of one subscribed company and role
+type digestGroup struct {
+	Company        string
+	Role           string
+	companyID      int
+	subscriptionID int
+	Jobs           []digestJob
+}
+
+// SendDueDigests mails a digest of new postings to every verified user whose digest is due, and
+// returns how many digests were queued. Users with nothing new are marked as done until the
+// next period.
+func SendDueDigests(now time.Time) (int, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, email FROM users
+		WHERE email_status = 'verified' AND disabled_at IS NULL
+		AND digest_frequency IN ('instant', 'daily', 'weekly')
+		AND (last_digest_at IS NULL OR last_digest_at <= CASE digest_frequency WHEN 'daily' THEN $1 WHEN 'weekly' THEN $2 ELSE $3 END)
+		AND EXISTS (SELECT 1 FROM subscriptions WHERE subscriptions.user_id = users.id AND subscriptions.active)
+		ORDER BY id`,
+		now.Add(-24*time.Hour), now.Add(-7*24*time.Hour), now)
+	if err != nil {
+		return 0, err
+	}
+	type recipient struct {
+		id    int
+		email string
+	}
+	var due []recipient
+	for rows.Next() {
+		var u recipient
+		if err := rows.Scan(&u.id, &u.email); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, u := range due {
+		queued, err := sendDigest(u.id, u.email, now)
+		if err != nil {
+			log.Printf("Failed to send digest to user %d: %v", u.id, err)
+			continue
+		}
+		if queued {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// sendDigest queues one user's digest if there are postings they have not been sent yet.
+// A search that fails is left out; its postings are picked up by a later digest.
+func sendDigest(userID int, email string, now time.Time) (bool, error) {
+	rows, err := db.DB.Query(`
+		SELECT companies.id, companies.name, roles.name, subscriptions.id
+		FROM subscriptions
+		JOIN companies ON companies.id = subscriptions.company_id
+		JOIN roles ON roles.id = ANY(subscriptions.role_ids)
+		WHERE subscriptions.user_id = $1 AND subscriptions.active
+		ORDER BY companies.name, roles.name`, userID)
+	if err != nil {
+		return false, err
+	}
+	var groups []digestGroup
+	for rows.Next() {
+		var g digestGroup
+		if err := rows.Scan(&g.companyID, &g.Company, &g.Role, &g.subscriptionID); err != nil {
+			rows.Close()
+			return false, err
+		}
+		groups = append(groups, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	// Collect the postings, each only once even when several roles match it
+	seen := map[int]bool{}
+	var ids []int64
+	for i := range groups {
+		q := services.JobQuery{Company: groups[i].Company, Role: groups[i].Role, SubscriptionID: groups[i].subscriptionID, CompanyID: groups[i].companyID}
+		jobs, err := fetchJobsFunc(context.Background(), q)
+		if err != nil {
+			log.Printf("Failed to fetch %s jobs at %s: %v", groups[i].Role, groups[i].Company, err)
+			continue
+		}
+		for _, job := range jobs {
+			if seen[job.JobID] {
+				continue
+			}
+			seen[job.JobID] = true
+			ids = append(ids, int64(job.JobID))
+			groups[i].Jobs = append(groups[i].Jobs, digestJob{ID: job.JobID, Title: job.Title, Location: job.Location, URL: job.URL})
+		}
+	}
+
+	// Drop what earlier digests already carried
+	alreadySent := map[int]bool{}
+	if len(ids) > 0 {
+		rows, err := db.DB.Query(`SELECT job_id FROM sent_jobs WHERE user_id = $1 AND job_id = ANY($2)`, userID, pq.Array(ids))
+		if err != nil {
+			return false, err
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return false, err
+			}
+			alreadySent[id] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return false, err
+		}
+	}
+
+	var newIDs []int64
+	var fresh []digestGroup
+	for _, g := range groups {
+		var jobs []digestJob
+		for _, job := range g.Jobs {
+			if !alreadySent[job.ID] {
+				jobs = append(jobs, job)
+				newIDs = append(newIDs, int64(job.ID))
+			}
+		}
+		if len(jobs) > 0 {
+			g.Jobs = jobs
+			fresh = append(fresh, g)
+		}
+	}
+
+	// The digest, the record of what it carried and the time it went out are stored together,
+	// so a failure part way does not mail the same postings again on the next run
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if len(newIDs) > 0 {
+		if _, err := tx.Exec(`
+			INSERT INTO sent_jobs (user_id, job_id, sent_at) SELECT $1, unnest($2::INT[]), $3
+			ON CONFLICT DO NOTHING`, userID, pq.Array(newIDs), now); err != nil {
+			return false, err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE users SET last_digest_at = $1 WHERE id = $2`, now, userID); err != nil {
+		return false, err
+	}
+	if len(newIDs) > 0 {
+		if err := queueDigest(tx, userID, email, fresh, len(newIDs)); err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return len(newIDs) > 0, nil
+}
+
+// queueDigest renders the job_digest email with a one-click unsubscribe link and queues it within tx
+func queueDigest(tx *sql.Tx, userID int, email string, groups []digestGroup, count int) error {
+	token, err := services.IssueEmailToken(userID, email, services.DigestUnsubscribePurpose, unsubscribeTokenTTL)
+	if err != nil {
+		return err
+	}
+	unsubscribe := apiBaseURL() + "/unsubscribe?token=" + url.QueryEscape(token)
+
+	msg, err := mailer.Render("job_digest", mailer.DefaultLocale, map[string]interface{}{
+		"Count":           count,
+		"Groups":          groups,
+		"UnsubscribeLink": unsubscribe,
+	})
+	if err != nil {
+		return fmt.Errorf("rendering digest: %w", err)
+	}
+	msg.To = []string{email}
+	msg.ListUnsubscribe = unsubscribe
+	return mailer.EnqueueTx(tx, msg)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/services"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestUpdateDigestSettingsHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func()
+		expectedCode int
+	}{
+		{
+			name: "Weekly",
+			body: `{"frequency": "weekly"}`,
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET digest_frequency = \\$1 WHERE id = \\$2").
+					WithArgs("weekly", 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCode: http.StatusOK,
+		},
+		{name: "Unknown Frequency", body: `{"frequency": "hourly"}`, mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+		{name: "Invalid Payload", body: `frequency=daily`, mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			rr := httptest.NewRecorder()
+			UpdateDigestSettingsHandler(rr, withUserID(httptest.NewRequest(http.MethodPut, "/account/digest", bytes.NewBufferString(tt.body)), 1))
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestUnsubscribeHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	valid, _ := services.IssueEmailToken(1, "john@example.com", services.DigestUnsubscribePurpose, time.Hour)
+	verification, _ := services.IssueEmailToken(1, "john@example.com", services.EmailVerificationPurpose, time.Hour)
+
+	tests := []struct {
+		name         string
+		method       string
+		token        string
+		mockSetup    func()
+		expectedCode int
+	}{
+		{name: "Link Clicked Asks To Confirm", method: http.MethodGet, token: valid, mockSetup: func() {}, expectedCode: http.StatusOK},
+		{
+			name:   "Confirmed",
+			method: http.MethodPost,
+			token:  valid,
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET digest_frequency = 'off' WHERE id = \\$1").
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "One-Click Post",
+			method: http.MethodPost,
+			token:  valid,
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET digest_frequency = 'off'").
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "Account Gone",
+			method: http.MethodPost,
+			token:  valid,
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET digest_frequency = 'off'").
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{name: "Other Purpose Rejected", method: http.MethodGet, token: verification, mockSetup: func() {}, expectedCode: http.StatusUnauthorized},
+		{name: "Missing Token", method: http.MethodGet, token: "", mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := httptest.NewRequest(tt.method, "/unsubscribe?token="+url.QueryEscape(tt.token), strings.NewReader("List-Unsubscribe=One-Click"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rr := httptest.NewRecorder()
+			UnsubscribeHandler(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+			if tt.method == http.MethodGet && rr.Code == http.StatusOK && !strings.Contains(rr.Body.String(), `<form method="post" action="`) {
+				t.Errorf("Expected a confirmation form, got %s", rr.Body.String())
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestSendDueDigests(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	sent := useMemoryMailer(t)
+
+	var searched []int
+	original := fetchJobsFunc
+	defer func() { fetchJobsFunc = original }()
+	fetchJobsFunc = func(ctx context.Context, q services.JobQuery) ([]JobResponse, error) {
+		// The postings found on each subscription's own links are read with the shared ones
+		searched = append(searched, q.SubscriptionID)
+		if q.CompanyID != 5 {
+			t.Errorf("Expected Acme's postings to be read by its id, got company %d", q.CompanyID)
+		}
+		company := q.Company
+		switch q.Role {
+		case "Backend Engineer":
+			// Jane was mailed posting 2 when LinkedIn listed it; the company's ATS has since taken it over
+			return []JobResponse{
+				{JobID: 1, Job: services.Job{Source: "linkedin", ID: "1", Title: "Senior Backend Engineer", Company: company, Location: "Remote", URL: "https://jobs.example.com/1"}},
+				{JobID: 2, Job: services.Job{Source: "greenhouse", ID: "22", Title: "Backend Engineer", Company: company, URL: "https://jobs.example.com/2"}},
+			}, nil
+		case "Data Engineer":
+			return nil, errors.New("search failed")
+		}
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	mock.ExpectQuery("SELECT id, email FROM users\\s+WHERE email_status = 'verified' AND disabled_at IS NULL").
+		WithArgs(now.Add(-24*time.Hour), now.Add(-7*24*time.Hour), now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(1, "john@example.com").AddRow(2, "jane@example.com"))
+
+	// John has not been sent posting 2 yet, one of his searches fails
+	mock.ExpectQuery("SELECT companies.id, companies.name, roles.name, subscriptions.id\\s+FROM subscriptions").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"company_id", "company", "role", "id"}).
+			AddRow(5, "Acme", "Backend Engineer", 11).
+			AddRow(5, "Acme", "Data Engineer", 11))
+	mock.ExpectQuery("SELECT job_id FROM sent_jobs WHERE user_id = \\$1 AND job_id = ANY\\(\\$2\\)").
+		WithArgs(1, pq.Array([]int64{1, 2})).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO sent_jobs").
+		WithArgs(1, pq.Array([]int64{2}), now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users SET last_digest_at = \\$1 WHERE id = \\$2").
+		WithArgs(now, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Jane has seen everything, so she only gets marked as done
+	mock.ExpectQuery("SELECT companies.id, companies.name, roles.name, subscriptions.id").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"company_id", "company", "role", "id"}).AddRow(5, "Acme", "Backend Engineer", 12))
+	mock.ExpectQuery("SELECT job_id FROM sent_jobs").
+		WithArgs(2, pq.Array([]int64{1, 2})).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id"}).AddRow(1).AddRow(2))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET last_digest_at").
+		WithArgs(now, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	count, err := SendDueDigests(now)
+	if err != nil || count != 1 {
+		t.Fatalf("Expected one digest, got %d (%v)", count, err)
+	}
+	if len(searched) != 3 || searched[0] != 11 || searched[1] != 11 || searched[2] != 12 {
+		t.Errorf("Expected each subscription's postings to be read, got %v", searched)
+	}
+
+	msgs := sent.Messages()
+	if len(msgs) != 1 || msgs[0].To[0] != "john@example.com" {
+		t.Fatalf("Expected a digest to john@example.com, got %+v", msgs)
+	}
+	if !strings.Contains(msgs[0].Text, "https://jobs.example.com/2") || strings.Contains(msgs[0].Text, "https://jobs.example.com/1") {
+		t.Errorf("Expected only the new posting in the digest:\n%s", msgs[0].Text)
+	}
+	if !strings.Contains(msgs[0].ListUnsubscribe, "/unsubscribe?token=") || !strings.Contains(msgs[0].Text, msgs[0].ListUnsubscribe) {
+		t.Errorf("Expected an unsubscribe link, got %q", msgs[0].ListUnsubscribe)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
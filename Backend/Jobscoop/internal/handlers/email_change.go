@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/middleware"
+	"JobScoop/internal/services"
+	"database/sql"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const emailChangeTokenTTL = 24 * time.Hour
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email"`
+	Password string `json:"password"`
+}
+
+// sendEmailChangeEmails mails the confirmation link to the new address and a notice to the old one
+func sendEmailChangeEmails(userID int, oldEmail, newEmail string) error {
+	token, err := services.IssueEmailToken(userID, newEmail, services.EmailChangePurpose, emailChangeTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := apiBaseURL() + "/confirm-email-change?token=" + url.QueryEscape(token)
+	if err := sendEmail(newEmail, "email_change_confirm", map[string]interface{}{"Link": link}); err != nil {
+		return err
+	}
+	return sendEmail(oldEmail, "email_change_notice", map[string]interface{}{"NewEmail": newEmail})
+}
+
+// ChangeEmailHandler starts an email change for the authenticated user. The password must be
+// entered again; the address is only swapped once the link sent to the new address is used.
+func ChangeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
+		http.Error(w, `{"message": "New email and password are required"}`, http.StatusBadRequest)
+		return
+	}
+	req.NewEmail = strings.TrimSpace(req.NewEmail)
+	if addr, err := mail.ParseAddress(req.NewEmail); err != nil || addr.Address != req.NewEmail || len(req.NewEmail) > 100 {
+		http.Error(w, `{"message": "Invalid email address"}`, http.StatusBadRequest)
+		return
+	}
+
+	var email, password string
+	err := db.DB.QueryRow(`SELECT email, password FROM users WHERE id = $1`, userID).Scan(&email, &password)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"message": "User not found"}`, http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(password), []byte(req.Password)) != nil {
+		http.Error(w, `{"message": "Invalid password"}`, http.StatusUnauthorized)
+		return
+	}
+	if strings.EqualFold(email, req.NewEmail) {
+		http.Error(w, `{"message": "That is already your email address"}`, http.StatusBadRequest)
+		return
+	}
+
+	var taken bool
+	if err := db.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)`, req.NewEmail).Scan(&taken); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if taken {
+		http.Error(w, `{"message": "Email is already in use"}`, http.StatusConflict)
+		return
+	}
+
+	// Only the latest request can be confirmed, earlier links stop working
+	if _, err := db.DB.Exec(`UPDATE users SET pending_email = $1 WHERE id = $2`, req.NewEmail, userID); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := sendEmailChangeEmails(userID, email, req.NewEmail); err != nil {
+		http.Error(w, `{"message": "Failed to send email"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Check your new email address to confirm the change",
+		"status":  "success",
+	})
+}
+
+// emailChangePage asks to confirm the new address, so that mail scanners and link prefetchers
+// opening the link do not change it
+var emailChangePage = template.Must(template.New("email_change").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Confirm your new JobScoop email</title></head>
+<body>
+<p>Use {{.Email}} as the email address of your JobScoop account? You will be signed out everywhere.</p>
+<form method="post" action="{{.Action}}"><button type="submit">Confirm</button></form>
+</body></html>
+`))
+
+// ConfirmEmailChangeHandler swaps in the new address on a POST: the confirmation form or a JSON
+// body with the token. A GET of the emailed link only shows the confirmation form. Pending password
+// resets follow the account, and every session is ended.
+func ConfirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" && r.Method == http.MethodPost {
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"message": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+		token = req.Token
+	}
+	if token == "" {
+		http.Error(w, `{"message": "Confirmation token is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, newEmail, err := services.ParseEmailToken(token, services.EmailChangePurpose)
+	if err != nil {
+		http.Error(w, `{"message": "Invalid or expired confirmation link"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		emailChangePage.Execute(w, map[string]string{
+			"Email":  newEmail,
+			"Action": apiBaseURL() + "/confirm-email-change?token=" + url.QueryEscape(token),
+		})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var oldEmail string
+	err = tx.QueryRow(
+		`SELECT email FROM users WHERE id = $1 AND pending_email = $2 AND disabled_at IS NULL FOR UPDATE`,
+		userID, newEmail,
+	).Scan(&oldEmail)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"message": "Invalid or expired confirmation link"}`, http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// Following the link proves control of the new address, so it counts as verified
+	_, err = tx.Exec(
+		`UPDATE users SET email = $1, pending_email = NULL, email_status = 'verified' WHERE id = $2`,
+		newEmail, userID,
+	)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		http.Error(w, `{"message": "Email is already in use"}`, http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// reset_tokens is keyed by address; its attempt counters and lockouts move with the account
+	if _, err := tx.Exec(`DELETE FROM reset_tokens WHERE email = $1`, newEmail); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec(`UPDATE reset_tokens SET email = $1 WHERE email = $2`, newEmail, oldEmail); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if err := revokeAllSessions(userID); err != nil {
+		http.Error(w, `{"message": "Failed to revoke sessions"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Email changed successfully, please log in again",
+		"status":  "success",
+	})
+}
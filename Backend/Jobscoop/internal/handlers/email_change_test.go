@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/services"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestChangeEmailHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	sent := useMemoryMailer(t)
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("securepassword"), bcrypt.MinCost)
+	userRow := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"email", "password"}).AddRow("john@school.edu", string(hashedPassword))
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func()
+		expectedCode int
+		expectSent   bool
+	}{
+		{
+			name: "Change Requested",
+			body: `{"new_email": "john@example.com", "password": "securepassword"}`,
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT email, password FROM users WHERE id = \\$1").
+					WithArgs(1).
+					WillReturnRows(userRow())
+				mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE email = \\$1\\)").
+					WithArgs("john@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+				mock.ExpectExec("UPDATE users SET pending_email = \\$1 WHERE id = \\$2").
+					WithArgs("john@example.com", 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCode: http.StatusOK,
+			expectSent:   true,
+		},
+		{
+			name: "Address Taken",
+			body: `{"new_email": "jane@example.com", "password": "securepassword"}`,
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT email, password FROM users").
+					WithArgs(1).
+					WillReturnRows(userRow())
+				mock.ExpectQuery("SELECT EXISTS").
+					WithArgs("jane@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name: "Wrong Password",
+			body: `{"new_email": "john@example.com", "password": "wrongpassword"}`,
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT email, password FROM users").
+					WithArgs(1).
+					WillReturnRows(userRow())
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{name: "Invalid Address", body: `{"new_email": "John <john@example.com>", "password": "securepassword"}`, mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+		{name: "Missing Password", body: `{"new_email": "john@example.com"}`, mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+			sent.Reset()
+
+			rr := httptest.NewRecorder()
+			ChangeEmailHandler(rr, withUserID(httptest.NewRequest(http.MethodPost, "/account/email", bytes.NewBufferString(tt.body)), 1))
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+			msgs := sent.Messages()
+			if tt.expectSent && (len(msgs) != 2 || msgs[0].To[0] != "john@example.com" || msgs[1].To[0] != "john@school.edu" ||
+				!strings.Contains(msgs[0].Text, "/confirm-email-change?token=") || strings.Contains(msgs[1].Text, "token=")) {
+				t.Errorf("Expected a link to the new address and a notice to the old one, got %+v", msgs)
+			}
+			if !tt.expectSent && len(msgs) != 0 {
+				t.Errorf("Expected no mail, got %+v", msgs)
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestConfirmEmailChangeHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	valid, _ := services.IssueEmailToken(1, "john@example.com", services.EmailChangePurpose, time.Hour)
+	verification, _ := services.IssueEmailToken(1, "john@example.com", services.EmailVerificationPurpose, time.Hour)
+
+	tests := []struct {
+		name         string
+		method       string
+		token        string
+		mockSetup    func()
+		expectedCode int
+	}{
+		{
+			name:   "Link Only Shows A Form",
+			method: http.MethodGet,
+			token:  valid,
+			// Mail scanners and prefetchers open the link, nothing changes until the form is sent
+			mockSetup:    func() {},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "Confirmed",
+			method: http.MethodPost,
+			token:  valid,
+			mockSetup: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT email FROM users WHERE id = \\$1 AND pending_email = \\$2 AND disabled_at IS NULL FOR UPDATE").
+					WithArgs(1, "john@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("john@school.edu"))
+				mock.ExpectExec("UPDATE users SET email = \\$1, pending_email = NULL, email_status = 'verified' WHERE id = \\$2").
+					WithArgs("john@example.com", 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("DELETE FROM reset_tokens WHERE email = \\$1").
+					WithArgs("john@example.com").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("UPDATE reset_tokens SET email = \\$1 WHERE email = \\$2").
+					WithArgs("john@example.com", "john@school.edu").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectExec("UPDATE refresh_tokens SET revoked_at").
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE users SET sessions_revoked_at").
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "Superseded Or Already Used",
+			method: http.MethodPost,
+			token:  valid,
+			mockSetup: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT email FROM users WHERE id = \\$1 AND pending_email = \\$2").
+					WithArgs(1, "john@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"email"}))
+				mock.ExpectRollback()
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{name: "Verification Token Rejected", method: http.MethodGet, token: verification, mockSetup: func() {}, expectedCode: http.StatusUnauthorized},
+		{name: "Missing Token", method: http.MethodGet, token: "", mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := httptest.NewRequest(tt.method, "/confirm-email-change?token="+url.QueryEscape(tt.token), nil)
+			rr := httptest.NewRecorder()
+			ConfirmEmailChangeHandler(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+			if tt.method == http.MethodGet && rr.Code == http.StatusOK && !strings.Contains(rr.Body.String(), `<form method="post" action="`) {
+				t.Errorf("Expected a confirmation form, got %s", rr.Body.String())
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
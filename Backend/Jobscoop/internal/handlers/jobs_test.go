@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/models"
+	"JobScoop/internal/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetAllJobs(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	original := recentJobsFunc
+	defer func() { recentJobsFunc = original }()
+	firstSeen := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	recentJobsFunc = func(companyID, subscriptionID int, since time.Time) ([]models.Job, error) {
+		// Postings are read by company id, another company with the same name has its own
+		if companyID != 4 || subscriptionID != 1 || time.Since(since) < storedJobsMaxAge {
+			t.Errorf("Unexpected read of company %d for subscription %d since %v", companyID, subscriptionID, since)
+		}
+		return []models.Job{
+			{ID: 1, Source: "greenhouse", ExternalID: "4", CanonicalURL: "https://boards.greenhouse.io/mock/jobs/4", Title: "Mock Role", Location: "Berlin",
+				Sources: []string{"greenhouse", "linkedin"}, FirstSeen: firstSeen, LastSeen: firstSeen.Add(time.Hour)},
+			{ID: 2, Source: "scraper", ExternalID: "https://mock.example/jobs/7", CanonicalURL: "https://mock.example/jobs/7", Title: "Senior Mock Role",
+				Sources: []string{"scraper"}, FirstSeen: firstSeen, LastSeen: firstSeen},
+			{ID: 3, Source: "greenhouse", ExternalID: "5", Title: "Office Manager", Sources: []string{"greenhouse"}, FirstSeen: firstSeen, LastSeen: firstSeen},
+		}, nil
+	}
+
+	// One query resolves the company and role names of every subscription
+	mock.ExpectQuery("SELECT s.id, s.company_id, c.name, ARRAY\\(SELECT name FROM roles WHERE id = ANY\\(s.role_ids\\) ORDER BY name\\)\\s+FROM subscriptions s JOIN companies c").
+		WithArgs(1, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "company_id", "name", "role_names"}).AddRow(1, 4, "Mock Company", "{Mock Role,Other Role}"))
+
+	rr := httptest.NewRecorder()
+	GetAllJobs(rr, withUserID(httptest.NewRequest(http.MethodPost, "/subscriptions/jobs", nil), 1))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var body struct {
+		Jobs []JobResponse `json:"jobs"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if len(body.Jobs) != 2 {
+		t.Fatalf("Expected the two postings of the role once each, got %+v", body.Jobs)
+	}
+	if got := body.Jobs[0]; got.JobID != 1 || got.Key() != "greenhouse:4" || got.Company != "Mock Company" || len(got.Sources) != 2 || !got.FirstSeen.Equal(firstSeen) {
+		t.Errorf("Expected the stored ATS listing linked to both sources, got %+v", got)
+	}
+	if got := body.Jobs[1]; got.JobID != 2 || got.ID != "" || got.Key() != "https://mock.example/jobs/7" {
+		t.Errorf("Expected the scraped posting keyed by its link, got %+v", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestFetchJobsReadsStoredJobs(t *testing.T) {
+	original := recentJobsFunc
+	defer func() { recentJobsFunc = original }()
+
+	recentJobsFunc = func(companyID, subscriptionID int, since time.Time) ([]models.Job, error) {
+		if companyID != 7 || subscriptionID != 3 {
+			t.Errorf("Expected company 7 with the postings of subscription 3's own links, got %d and %d", companyID, subscriptionID)
+		}
+		return []models.Job{
+			{ID: 1, Source: "lever", ExternalID: "abc", Title: "Data Engineer"},
+			{ID: 2, Source: "lever", ExternalID: "def", Title: "Designer"},
+		}, nil
+	}
+	jobs, err := fetchJobs(context.Background(), services.JobQuery{Company: "Acme", Role: "Data Engineer", SubscriptionID: 3, CompanyID: 7})
+	if err != nil || len(jobs) != 1 || jobs[0].JobID != 1 || jobs[0].Key() != "lever:abc" || jobs[0].Company != "Acme" {
+		t.Errorf("Expected the stored Data Engineer posting, got %+v (%v)", jobs, err)
+	}
+
+	recentJobsFunc = func(int, int, time.Time) ([]models.Job, error) { return nil, errors.New("connection refused") }
+	if _, err := fetchJobs(context.Background(), services.JobQuery{Company: "Acme", Role: "Data Engineer"}); err == nil {
+		t.Error("Expected the read error")
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/middleware"
+	"database/sql"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Failed logins are counted per account and per client IP. Past the threshold each further
+// failure doubles the lockout, starting at loginBaseLockout and capped at loginMaxLockout.
+const (
+	accountFailureThreshold = 5
+	ipFailureThreshold      = 20
+	loginBaseLockout        = 1 * time.Minute
+	loginMaxLockout         = 1 * time.Hour
+	loginFailureWindow      = 24 * time.Hour
+	loginActivityLimit      = 20
+)
+
+var (
+	loginLockedUntilFunc   = loginLockedUntil
+	recordLoginFailureFunc = recordLoginFailure
+	clearLoginFailuresFunc = clearLoginFailures
+	recordLoginAttemptFunc = recordLoginAttempt
+)
+
+// dummyPasswordHash is compared against when the account does not exist,
+// so that unknown emails take as long to reject as wrong passwords
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("jobscoop-dummy-password"), bcrypt.DefaultCost)
+
+func accountThrottleKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// clientIP returns the address of the caller. X-Forwarded-For is only honoured
+// when TRUST_PROXY_HEADERS is set, since clients can put anything in it.
+func clientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY_HEADERS") == "true" {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// lockoutFor returns how long a key with the given failure count stays locked
+func lockoutFor(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	lockout := loginBaseLockout
+	for i := threshold; i < failures && lockout < loginMaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > loginMaxLockout {
+		lockout = loginMaxLockout
+	}
+	return lockout
+}
+
+// loginLockedUntil returns the latest lockout among the keys, or the zero time if none is locked
+func loginLockedUntil(keys []string) (time.Time, error) {
+	var lockedUntil sql.NullTime
+	err := db.DB.QueryRow(
+		`SELECT MAX(locked_until) FROM login_throttles WHERE key = ANY($1) AND locked_until > $2`,
+		pq.Array(keys), time.Now().UTC(),
+	).Scan(&lockedUntil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return lockedUntil.Time, nil
+}
+
+// recordLoginFailure bumps the failure counter of each key and locks it once past its threshold.
+// Counters start over when the previous failure is older than loginFailureWindow.
+func recordLoginFailure(keys []string) error {
+	now := time.Now().UTC()
+	for _, key := range keys {
+		var failures int
+		err := db.DB.QueryRow(`
+			INSERT INTO login_throttles (key, failures, last_failure_at) VALUES ($1, 1, $2)
+			ON CONFLICT (key) DO UPDATE SET
+				failures = CASE WHEN login_throttles.last_failure_at < $3 THEN 1 ELSE login_throttles.failures + 1 END,
+				last_failure_at = $2
+			RETURNING failures`,
+			key, now, now.Add(-loginFailureWindow),
+		).Scan(&failures)
+		if err != nil {
+			return err
+		}
+
+		threshold := accountFailureThreshold
+		if strings.HasPrefix(key, "ip:") {
+			threshold = ipFailureThreshold
+		}
+		if lockout := lockoutFor(failures, threshold); lockout > 0 {
+			if _, err := db.DB.Exec(`UPDATE login_throttles SET locked_until = $2 WHERE key = $1`, key, now.Add(lockout)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// clearLoginFailures resets a key after a successful login
+func clearLoginFailures(key string) error {
+	_, err := db.DB.Exec(`DELETE FROM login_throttles WHERE key = $1`, key)
+	return err
+}
+
+// recordLoginAttempt stores one row of sign-in history; userID 0 means the account does not exist
+func recordLoginAttempt(userID int, email, ip, userAgent string, success bool) error {
+	var user sql.NullInt64
+	if userID != 0 {
+		user = sql.NullInt64{Int64: int64(userID), Valid: true}
+	}
+	_, err := db.DB.Exec(
+		`INSERT INTO login_attempts (user_id, email, ip, user_agent, success) VALUES ($1, $2, $3, $4, $5)`,
+		user, email, ip, userAgent, success,
+	)
+	return err
+}
+
+// LoginActivity is one entry of a user's recent sign-in history
+type LoginActivity struct {
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Success   bool      `json:"success"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoginActivityHandler lists the most recent successful and failed sign-ins of the authenticated user.
+func LoginActivityHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT ip, user_agent, success, created_at FROM login_attempts
+		WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`,
+		userID, loginActivityLimit)
+	if err != nil {
+		http.Error(w, `{"message": "Database error fetching login activity"}`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	activity := []LoginActivity{}
+	for rows.Next() {
+		var entry LoginActivity
+		if err := rows.Scan(&entry.IP, &entry.UserAgent, &entry.Success, &entry.CreatedAt); err != nil {
+			http.Error(w, `{"message": "Error scanning login activity"}`, http.StatusInternalServerError)
+			return
+		}
+		activity = append(activity, entry)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, `{"message": "Error iterating login activity"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"activity": activity,
+	})
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLockoutFor(t *testing.T) {
+	tests := []struct {
+		failures  int
+		threshold int
+		expected  time.Duration
+	}{
+		{failures: 4, threshold: 5, expected: 0},
+		{failures: 5, threshold: 5, expected: time.Minute},
+		{failures: 6, threshold: 5, expected: 2 * time.Minute},
+		{failures: 8, threshold: 5, expected: 8 * time.Minute},
+		{failures: 50, threshold: 5, expected: time.Hour},
+		{failures: 19, threshold: 20, expected: 0},
+		{failures: 20, threshold: 20, expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := lockoutFor(tt.failures, tt.threshold); got != tt.expected {
+			t.Errorf("lockoutFor(%d, %d) = %v, expected %v", tt.failures, tt.threshold, got, tt.expected)
+		}
+	}
+}
+
+func TestRecordLoginFailure(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	// The account key crosses its threshold and gets locked; the IP key is still far below its own
+	mock.ExpectQuery("INSERT INTO login_throttles").
+		WithArgs("email:john@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(accountFailureThreshold))
+	mock.ExpectExec("UPDATE login_throttles SET locked_until = \\$2 WHERE key = \\$1").
+		WithArgs("email:john@example.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO login_throttles").
+		WithArgs("ip:203.0.113.7", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(accountFailureThreshold))
+
+	if err := recordLoginFailure([]string{accountThrottleKey(" John@Example.com"), ipThrottleKey("203.0.113.7")}); err != nil {
+		t.Fatalf("recordLoginFailure returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.RemoteAddr = "198.51.100.2:51234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+
+	t.Setenv("TRUST_PROXY_HEADERS", "")
+	if ip := clientIP(req); ip != "198.51.100.2" {
+		t.Errorf("Expected the remote address when proxy headers are not trusted, got %s", ip)
+	}
+
+	t.Setenv("TRUST_PROXY_HEADERS", "true")
+	if ip := clientIP(req); ip != "203.0.113.7" {
+		t.Errorf("Expected the forwarded address when proxy headers are trusted, got %s", ip)
+	}
+}
+
+func TestLoginActivityHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	now := time.Now().UTC()
+	mock.ExpectQuery("SELECT ip, user_agent, success, created_at FROM login_attempts").
+		WithArgs(1, loginActivityLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"ip", "user_agent", "success", "created_at"}).
+			AddRow("203.0.113.7", "curl/8.0", false, now).
+			AddRow("198.51.100.2", "Mozilla/5.0", true, now.Add(-time.Hour)))
+
+	req := withUserID(httptest.NewRequest(http.MethodGet, "/account/login-activity", nil), 1)
+	rr := httptest.NewRecorder()
+	LoginActivityHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		Activity []LoginActivity `json:"activity"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(response.Activity) != 2 || response.Activity[0].Success || !response.Activity[1].Success {
+		t.Errorf("Unexpected activity: %+v", response.Activity)
+	}
+
+	// Without an authenticated user the handler refuses
+	rr = httptest.NewRecorder()
+	LoginActivityHandler(rr, httptest.NewRequest(http.MethodGet, "/account/login-activity", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/middleware"
+	"JobScoop/internal/services"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const recoveryCodeCount = 10
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type MFACodeRequest struct {
+	MFAToken     string `json:"mfa_token,omitempty"`
+	Password     string `json:"password,omitempty"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+func mfaThrottleKey(userID int) string {
+	return "mfa:" + strconv.Itoa(userID)
+}
+
+// normalizeRecoveryCode lets users type recovery codes with any case, spaces or dashes
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// generateRecoveryCodes returns codes formatted as xxxxx-xxxxx, each carrying 50 random bits
+func generateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+	return codes, nil
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, burns a recovery code.
+// Accepted TOTP steps are remembered so the same code cannot be used twice.
+func verifySecondFactor(userID int, secret, code, recoveryCode string) (bool, error) {
+	now := time.Now().UTC()
+	if code != "" {
+		step, ok := services.ValidateTOTP(secret, code, now)
+		if !ok {
+			return false, nil
+		}
+		res, err := db.DB.Exec(
+			`UPDATE users SET totp_last_step = $1 WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $1)`,
+			step, userID,
+		)
+		if err != nil {
+			return false, err
+		}
+		count, err := res.RowsAffected()
+		return count == 1, err
+	}
+
+	if recoveryCode != "" {
+		res, err := db.DB.Exec(
+			`UPDATE recovery_codes SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`,
+			now, userID, hashToken(normalizeRecoveryCode(recoveryCode)),
+		)
+		if err != nil {
+			return false, err
+		}
+		count, err := res.RowsAffected()
+		return count == 1, err
+	}
+	return false, nil
+}
+
+// EnrollTOTPHandler starts two-factor enrollment by storing a new secret and returning its otpauth URI.
+// The secret only takes effect once ConfirmTOTPHandler has seen a valid code for it.
+func EnrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	secret, err := services.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, `{"message": "Error generating secret"}`, http.StatusInternalServerError)
+		return
+	}
+
+	var email string
+	err = db.DB.QueryRow(
+		`UPDATE users SET totp_secret = $1, totp_last_step = NULL WHERE id = $2 AND totp_enabled = FALSE RETURNING email`,
+		secret, userID,
+	).Scan(&email)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"message": "Two-factor authentication is already enabled"}`, http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Scan the URI with an authenticator app and confirm with a code",
+		"status":      "success",
+		"otpauth_uri": services.TOTPURI(secret, email),
+		"secret":      secret,
+	})
+}
+
+// ConfirmTOTPHandler enables two-factor authentication after checking a first code,
+// and returns the recovery codes. They are shown only this once.
+func ConfirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, `{"message": "Code is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	var secret sql.NullString
+	var enabled bool
+	err := db.DB.QueryRow(`SELECT totp_secret, totp_enabled FROM users WHERE id = $1`, userID).Scan(&secret, &enabled)
+	if err != nil {
+		http.Error(w, `{"message": "User not found"}`, http.StatusNotFound)
+		return
+	}
+	if enabled {
+		http.Error(w, `{"message": "Two-factor authentication is already enabled"}`, http.StatusConflict)
+		return
+	}
+	if !secret.Valid {
+		http.Error(w, `{"message": "Start enrollment first"}`, http.StatusBadRequest)
+		return
+	}
+
+	step, valid := services.ValidateTOTP(secret.String, req.Code, time.Now())
+	if !valid {
+		http.Error(w, `{"message": "Invalid code"}`, http.StatusUnauthorized)
+		return
+	}
+
+	codes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		http.Error(w, `{"message": "Error generating recovery codes"}`, http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// The secret must still be the one the code was checked against
+	res, err := tx.Exec(
+		`UPDATE users SET totp_enabled = TRUE, totp_last_step = $1 WHERE id = $2 AND totp_enabled = FALSE AND totp_secret = $3`,
+		step, userID, secret.String,
+	)
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if count, err := res.RowsAffected(); err != nil || count == 0 {
+		http.Error(w, `{"message": "Enrollment changed, please start again"}`, http.StatusConflict)
+		return
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	for _, code := range codes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hashToken(normalizeRecoveryCode(code))); err != nil {
+			http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "Two-factor authentication enabled",
+		"status":         "success",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTPHandler turns two-factor authentication off. It needs the password and a current code or recovery code.
+func DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" || (req.Code == "" && req.RecoveryCode == "") {
+		http.Error(w, `{"message": "Password and code are required"}`, http.StatusBadRequest)
+		return
+	}
+
+	var password string
+	var secret sql.NullString
+	err := db.DB.QueryRow(
+		`SELECT password, totp_secret FROM users WHERE id = $1 AND totp_enabled = TRUE`, userID,
+	).Scan(&password, &secret)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"message": "Two-factor authentication is not enabled"}`, http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(password), []byte(req.Password)) != nil {
+		http.Error(w, `{"message": "Invalid password or code"}`, http.StatusUnauthorized)
+		return
+	}
+	valid, err := verifySecondFactor(userID, secret.String, req.Code, req.RecoveryCode)
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, `{"message": "Invalid password or code"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if _, err := db.DB.Exec(`UPDATE users SET totp_enabled = FALSE, totp_secret = NULL, totp_last_step = NULL WHERE id = $1`, userID); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if _, err := db.DB.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Two-factor authentication disabled",
+		"status":  "success",
+	})
+}
+
+// VerifyMFAHandler is the second login step: it exchanges the mfa pending token from LoginHandler
+// and a TOTP or recovery code for an access/refresh token pair.
+func VerifyMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var req MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		http.Error(w, "Token and code are required", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := services.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		http.Error(w, "Invalid or expired login session", http.StatusUnauthorized)
+		return
+	}
+
+	// Codes are only six digits, so guesses are throttled like passwords
+	throttleKeys := []string{mfaThrottleKey(userID)}
+	lockedUntil, err := loginLockedUntilFunc(throttleKeys)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !lockedUntil.IsZero() {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(lockedUntil).Seconds()))))
+		http.Error(w, "Too many failed attempts. Please try again later.", http.StatusTooManyRequests)
+		return
+	}
+
+	var email string
+	var secret sql.NullString
+	err = db.DB.QueryRow(
+		`SELECT email, totp_secret FROM users WHERE id = $1 AND totp_enabled = TRUE`, userID,
+	).Scan(&email, &secret)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invalid or expired login session", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	ip := clientIP(r)
+	valid, err := verifySecondFactor(userID, secret.String, req.Code, req.RecoveryCode)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		if err := recordLoginFailureFunc(throttleKeys); err != nil {
+			fmt.Println(err)
+		}
+		if err := recordLoginAttemptFunc(userID, email, ip, r.UserAgent(), false); err != nil {
+			fmt.Println(err)
+		}
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	if err := clearLoginFailuresFunc(throttleKeys[0]); err != nil {
+		fmt.Println(err)
+	}
+	if err := recordLoginAttemptFunc(userID, email, ip, r.UserAgent(), true); err != nil {
+		fmt.Println(err)
+	}
+
+	signedToken, refreshToken, err := issueTokenPair(userID, "")
+	if err == errAccountDisabled {
+		http.Error(w, "This account has been disabled", http.StatusForbidden)
+		return
+	} else if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Error signing the token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Login successful",
+		"token":         signedToken,
+		"refresh_token": refreshToken,
+		"userid":        userID,
+	})
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/services"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestEnrollAndConfirmTOTP(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	// Enrollment stores a fresh secret and hands back its otpauth URI
+	mock.ExpectQuery("UPDATE users SET totp_secret = \\$1, totp_last_step = NULL WHERE id = \\$2 AND totp_enabled = FALSE RETURNING email").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("john@example.com"))
+
+	rr := httptest.NewRecorder()
+	EnrollTOTPHandler(rr, withUserID(httptest.NewRequest(http.MethodPost, "/auth/2fa/enroll", nil), 1))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var enrollment struct {
+		URI    string `json:"otpauth_uri"`
+		Secret string `json:"secret"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &enrollment)
+	if !strings.HasPrefix(enrollment.URI, "otpauth://totp/") || enrollment.Secret == "" {
+		t.Fatalf("Unexpected enrollment response: %s", rr.Body.String())
+	}
+
+	code, _ := services.TOTPCode(enrollment.Secret, services.TOTPStep(time.Now()))
+
+	// A wrong code leaves two-factor disabled
+	mock.ExpectQuery("SELECT totp_secret, totp_enabled FROM users WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled"}).AddRow(enrollment.Secret, false))
+	body, _ := json.Marshal(MFACodeRequest{Code: "000000"})
+	if code == "000000" {
+		body, _ = json.Marshal(MFACodeRequest{Code: "111111"})
+	}
+	rr = httptest.NewRecorder()
+	ConfirmTOTPHandler(rr, withUserID(httptest.NewRequest(http.MethodPost, "/auth/2fa/confirm", bytes.NewBuffer(body)), 1))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a wrong code, got %d", rr.Code)
+	}
+
+	// The right code enables it and stores only hashes of the recovery codes
+	mock.ExpectQuery("SELECT totp_secret, totp_enabled FROM users WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "totp_enabled"}).AddRow(enrollment.Secret, false))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET totp_enabled = TRUE").
+		WithArgs(sqlmock.AnyArg(), 1, enrollment.Secret).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM recovery_codes WHERE user_id = \\$1").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	for i := 0; i < recoveryCodeCount; i++ {
+		mock.ExpectExec("INSERT INTO recovery_codes").
+			WithArgs(1, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	body, _ = json.Marshal(MFACodeRequest{Code: code})
+	rr = httptest.NewRecorder()
+	ConfirmTOTPHandler(rr, withUserID(httptest.NewRequest(http.MethodPost, "/auth/2fa/confirm", bytes.NewBuffer(body)), 1))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var confirmation struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &confirmation)
+	if len(confirmation.RecoveryCodes) != recoveryCodeCount {
+		t.Errorf("Expected %d recovery codes, got %d", recoveryCodeCount, len(confirmation.RecoveryCodes))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestVerifyMFAHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	var failures int
+	loginLockedUntilFunc = func(keys []string) (time.Time, error) { return time.Time{}, nil }
+	recordLoginFailureFunc = func(keys []string) error { failures++; return nil }
+	clearLoginFailuresFunc = func(key string) error { return nil }
+	recordLoginAttemptFunc = func(userID int, email, ip, userAgent string, success bool) error { return nil }
+	defer func() {
+		loginLockedUntilFunc = loginLockedUntil
+		recordLoginFailureFunc = recordLoginFailure
+		clearLoginFailuresFunc = clearLoginFailures
+		recordLoginAttemptFunc = recordLoginAttempt
+	}()
+
+	secret, _ := services.GenerateTOTPSecret()
+	code, _ := services.TOTPCode(secret, services.TOTPStep(time.Now()))
+	mfaToken, _ := services.IssueMFAToken(1)
+	accessToken, _ := services.IssueAccessToken(1, 0)
+
+	expectUser := func() {
+		mock.ExpectQuery("SELECT email, totp_secret FROM users WHERE id = \\$1 AND totp_enabled = TRUE").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"email", "totp_secret"}).AddRow("john@example.com", secret))
+	}
+
+	tests := []struct {
+		name             string
+		request          MFACodeRequest
+		mockSetup        func()
+		expectedCode     int
+		expectedFailures int
+	}{
+		{
+			name:    "Valid Code",
+			request: MFACodeRequest{MFAToken: mfaToken, Code: code},
+			mockSetup: func() {
+				expectUser()
+				mock.ExpectExec("UPDATE users SET totp_last_step = \\$1 WHERE id = \\$2").
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("INSERT INTO refresh_tokens").
+					WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"session_generation"}).AddRow(0))
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:    "Replayed Code",
+			request: MFACodeRequest{MFAToken: mfaToken, Code: code},
+			mockSetup: func() {
+				expectUser()
+				mock.ExpectExec("UPDATE users SET totp_last_step = \\$1 WHERE id = \\$2").
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectedCode:     http.StatusUnauthorized,
+			expectedFailures: 1,
+		},
+		{
+			name:    "Recovery Code",
+			request: MFACodeRequest{MFAToken: mfaToken, RecoveryCode: "ABCDE-FGHIJ"},
+			mockSetup: func() {
+				expectUser()
+				mock.ExpectExec("UPDATE recovery_codes SET used_at = \\$1 WHERE user_id = \\$2 AND code_hash = \\$3 AND used_at IS NULL").
+					WithArgs(sqlmock.AnyArg(), 1, hashToken("abcdefghij")).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("INSERT INTO refresh_tokens").
+					WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"session_generation"}).AddRow(0))
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:    "Used Recovery Code",
+			request: MFACodeRequest{MFAToken: mfaToken, RecoveryCode: "abcde-fghij"},
+			mockSetup: func() {
+				expectUser()
+				mock.ExpectExec("UPDATE recovery_codes SET used_at").
+					WithArgs(sqlmock.AnyArg(), 1, hashToken("abcdefghij")).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectedCode:     http.StatusUnauthorized,
+			expectedFailures: 1,
+		},
+		{
+			name:         "Access Token Instead Of MFA Token",
+			request:      MFACodeRequest{MFAToken: accessToken, Code: code},
+			mockSetup:    func() {},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Missing Code",
+			request:      MFACodeRequest{MFAToken: mfaToken},
+			mockSetup:    func() {},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+			failures = 0
+
+			body, _ := json.Marshal(tt.request)
+			rr := httptest.NewRecorder()
+			VerifyMFAHandler(rr, httptest.NewRequest(http.MethodPost, "/auth/2fa/verify", bytes.NewBuffer(body)))
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+			if failures != tt.expectedFailures {
+				t.Errorf("Expected %d recorded failures, got %d", tt.expectedFailures, failures)
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/middleware"
+	"JobScoop/internal/services"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	oidcStateTTL      = 10 * time.Minute
+	oidcLinkTicketTTL = 2 * time.Minute
+	oidcLoginCodeTTL  = time.Minute
+	oidcStateCookie   = "jobscoop_oidc_state"
+)
+
+// oidcProviderFromRequest returns the provider named in the route, writing a 404 if it is not configured
+func oidcProviderFromRequest(w http.ResponseWriter, r *http.Request) (*services.OIDCProvider, bool) {
+	provider, ok := services.OIDCProviderByName(mux.Vars(r)["provider"])
+	if !ok {
+		http.Error(w, `{"message": "Unknown identity provider"}`, http.StatusNotFound)
+		return nil, false
+	}
+	return provider, true
+}
+
+// startOIDC records a pending authorization request and returns the provider URL to send the browser to.
+// The state is also put in a cookie so that the callback only completes in the browser that started it.
+func startOIDC(w http.ResponseWriter, r *http.Request, provider *services.OIDCProvider, linkUserID int) (string, error) {
+	state, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	var linkUser sql.NullInt64
+	if linkUserID != 0 {
+		linkUser = sql.NullInt64{Int64: int64(linkUserID), Valid: true}
+	}
+	_, err = db.DB.Exec(
+		`INSERT INTO oidc_login_states (state_hash, provider, nonce, code_verifier, link_user_id, expires_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		hashToken(state), provider.Name, nonce, verifier, linkUser, time.Now().UTC().Add(oidcStateTTL),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc/",
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(apiBaseURL(), "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return authURL, nil
+}
+
+// ListOIDCProvidersHandler lists the identity providers users can sign in with.
+func ListOIDCProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	providers := []map[string]string{}
+	for _, p := range services.OIDCProviders() {
+		name := p.DisplayName
+		if name == "" {
+			name = p.Name
+		}
+		providers = append(providers, map[string]string{"name": p.Name, "display_name": name})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "success",
+		"providers": providers,
+	})
+}
+
+// StartOIDCLoginHandler redirects the browser to the provider to sign in, or to link the provider
+// when the URL carries a link ticket from StartOIDCLinkHandler.
+func StartOIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oidcProviderFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	linkUserID := 0
+	if ticket := r.URL.Query().Get("link_ticket"); ticket != "" {
+		// Each ticket is single use
+		err := db.DB.QueryRow(`
+			DELETE FROM oidc_link_tickets
+			WHERE ticket_hash = $1 AND provider = $2 AND expires_at > $3
+			RETURNING user_id`,
+			hashToken(ticket), provider.Name, time.Now().UTC(),
+		).Scan(&linkUserID)
+		if err == sql.ErrNoRows {
+			http.Error(w, `{"message": "Linking expired, please try again"}`, http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	authURL, err := startOIDC(w, r, provider, linkUserID)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, `{"message": "Could not start sign in"}`, http.StatusBadGateway)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// StartOIDCLinkHandler starts linking a provider to the authenticated user.
+// The call carries a bearer token, so it cannot set the state cookie on the browser; it returns a
+// one-time start URL instead, which the browser opens to be redirected to the provider.
+func StartOIDCLinkHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	provider, ok := oidcProviderFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	ticket, err := randomToken(32)
+	if err != nil {
+		http.Error(w, `{"message": "Could not start linking"}`, http.StatusInternalServerError)
+		return
+	}
+	_, err = db.DB.Exec(
+		`INSERT INTO oidc_link_tickets (ticket_hash, provider, user_id, expires_at) VALUES ($1, $2, $3, $4)`,
+		hashToken(ticket), provider.Name, userID, time.Now().UTC().Add(oidcLinkTicketTTL),
+	)
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	startURL := apiBaseURL() + "/auth/oidc/" + url.PathEscape(provider.Name) + "/start?link_ticket=" + url.QueryEscape(ticket)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":            "success",
+		"authorization_url": startURL,
+	})
+}
+
+// UnlinkOIDCHandler removes the authenticated user's identity at the provider.
+func UnlinkOIDCHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	res, err := db.DB.Exec(`DELETE FROM identities WHERE user_id = $1 AND provider = $2`, userID, mux.Vars(r)["provider"])
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if count, err := res.RowsAffected(); err != nil || count == 0 {
+		http.Error(w, `{"message": "Provider is not linked"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Provider unlinked",
+		"status":  "success",
+	})
+}
+
+// OIDCCallbackHandler completes a login or link when the provider redirects back, and sends the
+// browser on to the web app with a one-time code for the login.
+// A login for an unknown subject creates a new user, unless the email already belongs to one:
+// that account must sign in with its password and link the provider explicitly.
+func OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oidcProviderFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	if q.Get("error") != "" {
+		http.Error(w, `{"message": "Sign in was cancelled or refused by the provider"}`, http.StatusUnauthorized)
+		return
+	}
+
+	state := q.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if state == "" || q.Get("code") == "" || err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		http.Error(w, `{"message": "Invalid sign in state"}`, http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/auth/oidc/", MaxAge: -1})
+
+	// Each state is single use
+	var nonce, verifier string
+	var linkUser sql.NullInt64
+	err = db.DB.QueryRow(`
+		DELETE FROM oidc_login_states
+		WHERE state_hash = $1 AND provider = $2 AND expires_at > $3
+		RETURNING nonce, code_verifier, link_user_id`,
+		hashToken(state), provider.Name, time.Now().UTC(),
+	).Scan(&nonce, &verifier, &linkUser)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"message": "Sign in expired, please try again"}`, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), q.Get("code"), verifier, nonce)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, `{"message": "Could not verify the sign in with the provider"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if linkUser.Valid {
+		linkIdentity(w, r, provider.Name, int(linkUser.Int64), claims)
+		return
+	}
+
+	var userID int
+	var totpEnabled bool
+	err = db.DB.QueryRow(`
+		SELECT u.id, u.totp_enabled FROM identities i JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2`,
+		provider.Name, claims.Subject,
+	).Scan(&userID, &totpEnabled)
+	if err == sql.ErrNoRows {
+		userID, err = createOIDCUser(provider.Name, claims)
+		if err == errEmailTaken {
+			http.Error(w, `{"message": "An account with this email already exists. Sign in with your password and link the provider from your account."}`, http.StatusConflict)
+			return
+		}
+	}
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if _, err := db.DB.Exec(`UPDATE identities SET last_login_at = $1 WHERE provider = $2 AND subject = $3`, time.Now().UTC(), provider.Name, claims.Subject); err != nil {
+		fmt.Println(err)
+	}
+
+	// Two-factor users still have the second step ahead, the attempt is recorded once it is done
+	if !totpEnabled {
+		if err := recordLoginAttemptFunc(userID, claims.Email, clientIP(r), r.UserAgent(), true); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	// This is a top-level browser navigation: hand the web app a one-time code rather than the
+	// tokens, which it exchanges with ExchangeOIDCCodeHandler
+	code, err := randomToken(32)
+	if err != nil {
+		http.Error(w, `{"message": "Error signing the token"}`, http.StatusInternalServerError)
+		return
+	}
+	_, err = db.DB.Exec(
+		`INSERT INTO oidc_login_codes (code_hash, user_id, mfa_required, expires_at) VALUES ($1, $2, $3, $4)`,
+		hashToken(code), userID, totpEnabled, time.Now().UTC().Add(oidcLoginCodeTTL),
+	)
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, frontendURL()+"/oidc/callback?code="+url.QueryEscape(code), http.StatusFound)
+}
+
+type OIDCCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// ExchangeOIDCCodeHandler trades the one-time code of a provider sign in for the same response as
+// a password login: a token pair, or an MFA token when two-factor is enabled.
+func ExchangeOIDCCodeHandler(w http.ResponseWriter, r *http.Request) {
+	var req OIDCCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, `{"message": "Invalid request payload"}`, http.StatusBadRequest)
+		return
+	}
+
+	// Each code is single use
+	var userID int
+	var mfaRequired bool
+	err := db.DB.QueryRow(`
+		DELETE FROM oidc_login_codes WHERE code_hash = $1 AND expires_at > $2
+		RETURNING user_id, mfa_required`,
+		hashToken(req.Code), time.Now().UTC(),
+	).Scan(&userID, &mfaRequired)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"message": "Sign in expired, please try again"}`, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// Same second step as a password login when two-factor is enabled
+	if mfaRequired {
+		mfaToken, err := services.IssueMFAToken(userID)
+		if err != nil {
+			http.Error(w, `{"message": "Error signing the token"}`, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":      "Two-factor authentication required",
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		})
+		return
+	}
+
+	signedToken, refreshToken, err := issueTokenPair(userID, "")
+	if err == errAccountDisabled {
+		http.Error(w, `{"message": "This account has been disabled"}`, http.StatusForbidden)
+		return
+	} else if err != nil {
+		fmt.Println(err)
+		http.Error(w, `{"message": "Error signing the token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Login successful",
+		"token":         signedToken,
+		"refresh_token": refreshToken,
+		"userid":        userID,
+	})
+}
+
+var errEmailTaken = errors.New("email already registered")
+
+// createOIDCUser signs up a new user for an unknown external subject and links the identity.
+// The random password cannot be guessed; the user can set a real one through the reset flow.
+func createOIDCUser(provider string, claims *services.IDTokenClaims) (int, error) {
+	if claims.Email == "" {
+		return 0, fmt.Errorf("provider %s did not return an email", provider)
+	}
+
+	var exists bool
+	if err := db.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)`, claims.Email).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, errEmailTaken
+	}
+
+	password, err := randomToken(32)
+	if err != nil {
+		return 0, err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+	name := claims.Name
+	if name == "" {
+		name = strings.Split(claims.Email, "@")[0]
+	}
+	status := "pending"
+	if claims.EmailVerified {
+		status = "verified"
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(
+		`INSERT INTO users (name, email, password, email_status) VALUES ($1, $2, $3, $4) RETURNING id`,
+		name, claims.Email, string(hashed), status,
+	).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)`,
+		userID, provider, claims.Subject, claims.Email,
+	); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if status == "pending" {
+		if err := sendVerificationEmail(userID, claims.Email); err != nil {
+			fmt.Println(err)
+		}
+	}
+	return userID, nil
+}
+
+// linkIdentity attaches the verified external subject to an existing user and sends the browser
+// back to the web app
+func linkIdentity(w http.ResponseWriter, r *http.Request, provider string, userID int, claims *services.IDTokenClaims) {
+	res, err := db.DB.Exec(`
+		INSERT INTO identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING`,
+		userID, provider, claims.Subject, claims.Email,
+	)
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if count, err := res.RowsAffected(); err != nil || count == 0 {
+		var owner int
+		err := db.DB.QueryRow(`SELECT user_id FROM identities WHERE provider = $1 AND subject = $2`, provider, claims.Subject).Scan(&owner)
+		if err != nil || owner != userID {
+			http.Error(w, `{"message": "This provider account is linked to another user, or another account of this provider is already linked"}`, http.StatusConflict)
+			return
+		}
+	}
+
+	http.Redirect(w, r, frontendURL()+"/oidc/callback?linked="+url.QueryEscape(provider), http.StatusFound)
+}
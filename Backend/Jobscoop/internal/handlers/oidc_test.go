@@ -0,0 +1,395 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/services"
+	"JobScoop/internal/services/oidctest"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+// captured is a sqlmock argument that matches anything and remembers the value
+type captured struct{ value driver.Value }
+
+func (c *captured) Match(v driver.Value) bool {
+	c.value = v
+	return true
+}
+
+// startFakeOIDC configures a provider named "fake" backed by a fake identity provider
+func startFakeOIDC(t *testing.T) *oidctest.Provider {
+	idp := oidctest.New("jobscoop-client")
+	services.SetOIDCProviders(&services.OIDCProvider{
+		Name:        "fake",
+		Issuer:      idp.Issuer(),
+		ClientID:    "jobscoop-client",
+		RedirectURL: "http://localhost:8080/auth/oidc/fake/callback",
+	})
+	t.Cleanup(func() {
+		services.SetOIDCProviders()
+		idp.Server.Close()
+	})
+	return idp
+}
+
+// beginOIDCLogin runs the start handler, follows the provider redirect like a browser and
+// returns the callback request with the state cookie. It expects the state row to be stored
+// and then consumed by the callback.
+func beginOIDCLogin(t *testing.T, idp *oidctest.Provider, mock sqlmock.Sqlmock, linkUserID interface{}) *http.Request {
+	nonce, verifier := &captured{}, &captured{}
+	mock.ExpectExec("INSERT INTO oidc_login_states").
+		WithArgs(sqlmock.AnyArg(), "fake", nonce, verifier, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/auth/oidc/fake/start", nil), map[string]string{"provider": "fake"})
+	rr := httptest.NewRecorder()
+	StartOIDCLoginHandler(rr, req)
+	if rr.Code != http.StatusFound {
+		t.Fatalf("Expected a redirect to the provider, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	callback, err := idp.Authorize(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Authorize returned error: %v", err)
+	}
+	state := callback.Query().Get("state")
+	mock.ExpectQuery("DELETE FROM oidc_login_states").
+		WithArgs(hashToken(state), "fake", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"nonce", "code_verifier", "link_user_id"}).
+			AddRow(nonce.value, verifier.value, linkUserID))
+
+	cb := mux.SetURLVars(httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil), map[string]string{"provider": "fake"})
+	for _, c := range rr.Result().Cookies() {
+		cb.AddCookie(c)
+	}
+	return cb
+}
+
+func TestOIDCCallbackHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	recordLoginAttemptFunc = func(userID int, email, ip, userAgent string, success bool) error { return nil }
+	defer func() { recordLoginAttemptFunc = recordLoginAttempt }()
+
+	idp := startFakeOIDC(t)
+
+	tests := []struct {
+		name             string
+		linkUserID       interface{}
+		mockSetup        func()
+		expectedCode     int
+		expectedLocation string
+	}{
+		{
+			name: "New Subject Creates User",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT u.id, u.totp_enabled FROM identities i JOIN users u").
+					WithArgs("fake", "fake-subject").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "totp_enabled"}))
+				mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE email = \\$1\\)").
+					WithArgs("john@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+				mock.ExpectBegin()
+				mock.ExpectQuery("INSERT INTO users \\(name, email, password, email_status\\)").
+					WithArgs("John Doe", "john@example.com", bcryptHash{}, "verified").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+				mock.ExpectExec("INSERT INTO identities").
+					WithArgs(9, "fake", "fake-subject", "john@example.com").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+				mock.ExpectExec("UPDATE identities SET last_login_at").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO oidc_login_codes").
+					WithArgs(sqlmock.AnyArg(), 9, false, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			expectedCode:     http.StatusFound,
+			expectedLocation: "http://localhost:3000/oidc/callback?code=",
+		},
+		{
+			name: "Linked Subject Logs In",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT u.id, u.totp_enabled FROM identities i JOIN users u").
+					WithArgs("fake", "fake-subject").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "totp_enabled"}).AddRow(3, false))
+				mock.ExpectExec("UPDATE identities SET last_login_at").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO oidc_login_codes").
+					WithArgs(sqlmock.AnyArg(), 3, false, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			expectedCode:     http.StatusFound,
+			expectedLocation: "http://localhost:3000/oidc/callback?code=",
+		},
+		{
+			name: "Linked Subject With Two-Factor",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT u.id, u.totp_enabled FROM identities i JOIN users u").
+					WithArgs("fake", "fake-subject").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "totp_enabled"}).AddRow(3, true))
+				mock.ExpectExec("UPDATE identities SET last_login_at").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO oidc_login_codes").
+					WithArgs(sqlmock.AnyArg(), 3, true, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			expectedCode:     http.StatusFound,
+			expectedLocation: "http://localhost:3000/oidc/callback?code=",
+		},
+		{
+			name: "Email Belongs To Existing Account",
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT u.id, u.totp_enabled FROM identities i JOIN users u").
+					WithArgs("fake", "fake-subject").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "totp_enabled"}))
+				mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE email = \\$1\\)").
+					WithArgs("john@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:       "Link To Signed In User",
+			linkUserID: 5,
+			mockSetup: func() {
+				mock.ExpectExec("INSERT INTO identities").
+					WithArgs(5, "fake", "fake-subject", "john@example.com").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			expectedCode:     http.StatusFound,
+			expectedLocation: "http://localhost:3000/oidc/callback?linked=fake",
+		},
+		{
+			name:       "Link Subject Owned By Someone Else",
+			linkUserID: 5,
+			mockSetup: func() {
+				mock.ExpectExec("INSERT INTO identities").
+					WithArgs(5, "fake", "fake-subject", "john@example.com").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT user_id FROM identities WHERE provider = \\$1 AND subject = \\$2").
+					WithArgs("fake", "fake-subject").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(8))
+			},
+			expectedCode: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := beginOIDCLogin(t, idp, mock, tt.linkUserID)
+			tt.mockSetup()
+
+			rr := httptest.NewRecorder()
+			OIDCCallbackHandler(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+			// The tokens never travel in the redirect, only a one-time code for the web app
+			if location := rr.Header().Get("Location"); !strings.HasPrefix(location, tt.expectedLocation) || strings.Contains(location, "token") {
+				t.Errorf("Expected a redirect to %s..., got %q", tt.expectedLocation, location)
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestExchangeOIDCCodeHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	tests := []struct {
+		name         string
+		body         string
+		mockSetup    func()
+		expectedCode int
+		expectedMsg  string
+	}{
+		{
+			name: "Login",
+			body: `{"code": "code-1"}`,
+			mockSetup: func() {
+				mock.ExpectQuery("DELETE FROM oidc_login_codes WHERE code_hash = \\$1 AND expires_at > \\$2").
+					WithArgs(hashToken("code-1"), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "mfa_required"}).AddRow(3, false))
+				mock.ExpectQuery("INSERT INTO refresh_tokens").
+					WithArgs(3, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"session_generation"}).AddRow(0))
+			},
+			expectedCode: http.StatusOK,
+			expectedMsg:  "Login successful",
+		},
+		{
+			name: "Two-Factor",
+			body: `{"code": "code-2"}`,
+			mockSetup: func() {
+				mock.ExpectQuery("DELETE FROM oidc_login_codes").
+					WithArgs(hashToken("code-2"), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "mfa_required"}).AddRow(3, true))
+			},
+			expectedCode: http.StatusOK,
+			expectedMsg:  "Two-factor authentication required",
+		},
+		{
+			name: "Used Or Expired Code",
+			body: `{"code": "code-1"}`,
+			mockSetup: func() {
+				mock.ExpectQuery("DELETE FROM oidc_login_codes").
+					WithArgs(hashToken("code-1"), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "mfa_required"}))
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedMsg:  "Sign in expired, please try again",
+		},
+		{
+			name:         "Missing Code",
+			body:         `{}`,
+			mockSetup:    func() {},
+			expectedCode: http.StatusBadRequest,
+			expectedMsg:  "Invalid request payload",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/oidc/exchange", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			ExchangeOIDCCodeHandler(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+			var response map[string]interface{}
+			json.Unmarshal(rr.Body.Bytes(), &response)
+			if response["message"] != tt.expectedMsg {
+				t.Errorf("Expected message '%s', got '%v'", tt.expectedMsg, response["message"])
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestOIDCCallbackRejectsBadState(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	idp := startFakeOIDC(t)
+
+	// The callback is replayed in a browser that did not start the login
+	req := beginOIDCLogin(t, idp, mock, nil)
+	stolen := mux.SetURLVars(httptest.NewRequest(http.MethodGet, req.URL.RequestURI(), nil), map[string]string{"provider": "fake"})
+	rr := httptest.NewRecorder()
+	OIDCCallbackHandler(rr, stolen)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without the state cookie, got %d", rr.Code)
+	}
+
+	// The nonce in the ID token does not match the one stored with the state
+	idp.Nonce = "forged-nonce"
+	rr = httptest.NewRecorder()
+	OIDCCallbackHandler(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a nonce mismatch, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Unknown providers are not found
+	rr = httptest.NewRecorder()
+	OIDCCallbackHandler(rr, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/auth/oidc/nope/callback", nil), map[string]string{"provider": "nope"}))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown provider, got %d", rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestStartOIDCLinkHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+	startFakeOIDC(t)
+
+	ticket := &captured{}
+	mock.ExpectExec("INSERT INTO oidc_link_tickets \\(ticket_hash, provider, user_id, expires_at\\)").
+		WithArgs(ticket, "fake", 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/auth/oidc/fake/link", nil), map[string]string{"provider": "fake"})
+	rr := httptest.NewRecorder()
+	StartOIDCLinkHandler(rr, withUserID(req, 1))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(rr.Result().Cookies()) != 0 {
+		t.Error("Expected no cookie on the XHR response")
+	}
+	var body struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &body)
+	start, err := url.Parse(body.AuthorizationURL)
+	if err != nil || start.Path != "/auth/oidc/fake/start" || hashToken(start.Query().Get("link_ticket")) != ticket.value {
+		t.Fatalf("Expected a start URL carrying the ticket, got %q", body.AuthorizationURL)
+	}
+
+	// The browser opens it: the ticket is spent, and the redirect sets the state cookie
+	mock.ExpectQuery("DELETE FROM oidc_link_tickets").
+		WithArgs(ticket.value, "fake", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO oidc_login_states").
+		WithArgs(sqlmock.AnyArg(), "fake", sqlmock.AnyArg(), sqlmock.AnyArg(), sql.NullInt64{Int64: 1, Valid: true}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	rr = httptest.NewRecorder()
+	StartOIDCLoginHandler(rr, mux.SetURLVars(httptest.NewRequest(http.MethodGet, start.RequestURI(), nil), map[string]string{"provider": "fake"}))
+	if rr.Code != http.StatusFound || len(rr.Result().Cookies()) != 1 || rr.Result().Cookies()[0].Name != oidcStateCookie {
+		t.Fatalf("Expected a redirect setting the state cookie, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// A spent or expired ticket does not start anything
+	mock.ExpectQuery("DELETE FROM oidc_link_tickets").
+		WithArgs(ticket.value, "fake", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	rr = httptest.NewRecorder()
+	StartOIDCLoginHandler(rr, mux.SetURLVars(httptest.NewRequest(http.MethodGet, start.RequestURI(), nil), map[string]string{"provider": "fake"}))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a spent ticket, got %d", rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
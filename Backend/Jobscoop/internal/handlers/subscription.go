@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"JobScoop/internal/db"
+	"JobScoop/internal/middleware"
+	"JobScoop/internal/services"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
@@ -13,16 +17,15 @@ import (
 
 // SubscriptionRequest represents the incoming JSON request
 type SubscriptionRequest struct {
-	Email         string `json:"email"`
 	Subscriptions []struct {
 		CompanyName string   `json:"companyName"`
 		CareerLinks []string `json:"careerLinks"`
+		FeedLinks   []string `json:"feedLinks"`
 		RoleNames   []string `json:"roleNames"`
 	} `json:"subscriptions"`
 }
 
 var (
-	getUserIDByEmailFunc        = getUserIDByEmail
 	getOrCreateCompanyIDFunc    = getOrCreateCompanyID
 	getOrCreateCareerSiteIDFunc = getOrCreateCareerSiteID
 	getOrCreateRoleIDFunc       = getOrCreateRoleID
@@ -43,21 +46,21 @@ func SaveSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if email is provided
-	if req.Email == "" {
-		http.Error(w, `{"message": "Email is required"}`, http.StatusBadRequest)
-		return
-	}
-
-	// Fetch the user ID based on email
-	userID, err := getUserIDByEmailFunc(req.Email)
-	if err != nil {
-		http.Error(w, `{"message": "User not found. Please sign up."}`, http.StatusNotFound)
+	// The authenticated user comes from the access token, never from the payload
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
 
 	// Process each subscription entry
 	for _, sub := range req.Subscriptions {
+		feedLinks, ok := validFeedLinks(sub.FeedLinks)
+		if !ok {
+			http.Error(w, `{"message": "Feed links must be public http or https URLs"}`, http.StatusBadRequest)
+			return
+		}
+
 		// Get or create company and its ID
 		companyID, err := getOrCreateCompanyIDFunc(sub.CompanyName)
 		if err != nil {
@@ -158,6 +161,19 @@ func SaveSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		}
+
+		// Add the feed links to the ones the subscription already has
+		if len(feedLinks) > 0 {
+			_, err = db.DB.Exec(`
+				UPDATE subscriptions
+				SET feed_links = ARRAY(SELECT DISTINCT unnest(feed_links || $1::TEXT[]) ORDER BY 1)
+				WHERE user_id=$2 AND company_id=$3`,
+				pq.Array(feedLinks), userID, companyID)
+			if err != nil {
+				http.Error(w, `{"message": "Error updating subscription"}`, http.StatusInternalServerError)
+				return
+			}
+		}
 	}
 
 	// Respond with success message
@@ -169,16 +185,23 @@ func SaveSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// getUserIDByEmail fetches user ID based on email
-func getUserIDByEmail(email string) (int, error) {
-	var userID int
-	err := db.DB.QueryRow("SELECT id FROM users WHERE email = $1", email).Scan(&userID)
-	if err == sql.ErrNoRows {
-		return 0, fmt.Errorf("user not found")
-	} else if err != nil {
-		return 0, err
+// validFeedLinks trims and dedupes the feed links of a subscription, reporting whether they are all
+// http or https URLs on public hosts
+func validFeedLinks(links []string) ([]string, bool) {
+	seen := map[string]bool{}
+	valid := []string{}
+	for _, link := range links {
+		link = strings.TrimSpace(link)
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !services.PublicHost(u.Hostname()) {
+			return nil, false
+		}
+		if !seen[link] {
+			seen[link] = true
+			valid = append(valid, link)
+		}
 	}
-	return userID, nil
+	return valid, true
 }
 
 // getOrCreateCompanyID fetches or inserts a company
@@ -230,38 +253,22 @@ func getOrCreateRoleID(roleName string) (int, error) {
 type SubscriptionResponse struct {
 	CompanyName string   `json:"companyName"`
 	CareerLinks []string `json:"careerLinks"`
+	FeedLinks   []string `json:"feedLinks"`
 	RoleNames   []string `json:"roleNames"`
 	Active      bool     `json:"active"`
 }
 
-// Request struct to get email
-type GetSubscriptionsRequest struct {
-	Email string `json:"email"`
-}
-
-// FetchUserSubscriptionsHandler retrieves subscriptions based on the provided email.
+// FetchUserSubscriptionsHandler retrieves the subscriptions of the authenticated user.
 func FetchUserSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
-	// Decode request to get email
-	var req GetSubscriptionsRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"message": "Invalid request payload"}`, http.StatusBadRequest)
-		return
-	}
-	if req.Email == "" {
-		http.Error(w, `{"message": "Email is required"}`, http.StatusBadRequest)
-		return
-	}
-
-	// Get user ID from email
-	userID, err := getUserIDByEmailFunc(req.Email)
-	if err != nil {
-		http.Error(w, `{"message": "User not found"}`, http.StatusNotFound)
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
 
 	// Query subscriptions for the user
 	rows, err := db.DB.Query(`
-		SELECT id, company_id, career_site_ids, role_ids, active
+		SELECT id, company_id, career_site_ids, role_ids, active, feed_links
 		FROM subscriptions 
 		WHERE user_id=$1`, userID)
 	if err != nil {
@@ -280,8 +287,9 @@ func FetchUserSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
 		var careerSiteIDs []int64
 		var roleIDs []int64
 		var active bool
+		var feedLinks []string
 
-		if err := rows.Scan(&id, &companyID, pq.Array(&careerSiteIDs), pq.Array(&roleIDs), &active); err != nil {
+		if err := rows.Scan(&id, &companyID, pq.Array(&careerSiteIDs), pq.Array(&roleIDs), &active, pq.Array(&feedLinks)); err != nil {
 			http.Error(w, `{"message": "Error scanning subscription row"}`, http.StatusInternalServerError)
 			return
 		}
@@ -319,6 +327,7 @@ func FetchUserSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
 		subResp := SubscriptionResponse{
 			CompanyName: companyName,
 			CareerLinks: careerLinks,
+			FeedLinks:   feedLinks,
 			RoleNames:   roleNames,
 			Active: active,
 
@@ -371,10 +380,10 @@ func getRoleNameByID(roleID int) (string, error) {
 
 // UpdateSubscriptionsRequest represents the incoming JSON payload.
 type UpdateSubscriptionsRequest struct {
-	Email         string `json:"email"`
 	Subscriptions []struct {
 		CompanyName string   `json:"companyName"`
 		CareerLinks []string `json:"careerLinks,omitempty"`
+		FeedLinks   []string `json:"feedLinks,omitempty"`
 		RoleNames   []string `json:"roleNames,omitempty"`
 		Active    *bool    `json:"active,omitempty"`
 	} `json:"subscriptions"`
@@ -530,16 +539,10 @@ func UpdateSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate that an email is provided.
-	if req.Email == "" {
-		http.Error(w, `{"message": "Email is required"}`, http.StatusBadRequest)
-		return
-	}
-
-	// Get the user ID for the given email.
-	userID, err := getUserIDByEmailFunc(req.Email)
-	if err != nil {
-		http.Error(w, `{"message": "User not found. Please sign up."}`, http.StatusNotFound)
+	// Get the authenticated user ID.
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
 
@@ -575,13 +578,21 @@ func UpdateSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
 		updateCareerLinks := len(sub.CareerLinks) > 0
 		updateRoleNames := len(sub.RoleNames) > 0
 		updateActive := sub.Active != nil
+		// An empty list of feed links removes them all
+		updateFeedLinks := sub.FeedLinks != nil
 
 		// If no update fields are provided, return error.
-		if !updateCareerLinks && !updateRoleNames && !updateActive {
+		if !updateCareerLinks && !updateRoleNames && !updateActive && !updateFeedLinks {
 			http.Error(w, `{"message": "No update fields provided"}`, http.StatusBadRequest)
 			return
 		}
 
+		feedLinks, ok := validFeedLinks(sub.FeedLinks)
+		if !ok {
+			http.Error(w, `{"message": "Feed links must be public http or https URLs"}`, http.StatusBadRequest)
+			return
+		}
+
 		// Prepare new arrays.
 		var newCareerSiteIDs []int
 		if updateCareerLinks {
@@ -619,6 +630,18 @@ func UpdateSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
 
 		now := time.Now().UTC()
 
+		if updateFeedLinks {
+			_, err = db.DB.Exec(`
+				UPDATE subscriptions
+				SET feed_links=$1, interest_time=$2
+				WHERE user_id=$3 AND company_id=$4`,
+				pq.Array(feedLinks), now, userID, companyID)
+			if err != nil {
+				http.Error(w, `{"message": "Error updating subscription"}`, http.StatusInternalServerError)
+				return
+			}
+		}
+
 		// Build and execute the UPDATE query based on which fields to update.
 		var execErr error
 		switch {
@@ -693,23 +716,23 @@ func getCompanyIDIfExists(companyName string) (int, error) {
 
 // DeleteSubscriptionsRequest represents the expected payload.
 type DeleteSubscriptionsRequest struct {
-	Email         string   `json:"email"`
 	Subscriptions []string `json:"subscriptions"`
 }
 
-// DeleteSubscriptionsHandler deletes subscriptions for the given email and companies.
+// DeleteSubscriptionsHandler deletes the authenticated user's subscriptions for the given companies.
 func DeleteSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
 	var req DeleteSubscriptionsRequest
 
-	// Decode the request body.
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"message": "Invalid request payload"}`, http.StatusBadRequest)
+	// Get the authenticated user ID.
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
 
-	// Validate that an email is provided.
-	if req.Email == "" {
-		http.Error(w, `{"message": "Email is required"}`, http.StatusBadRequest)
+	// Decode the request body.
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"message": "Invalid request payload"}`, http.StatusBadRequest)
 		return
 	}
 
@@ -719,13 +742,6 @@ func DeleteSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the user ID corresponding to the email.
-	userID, err := getUserIDByEmailFunc(req.Email)
-	if err != nil {
-		http.Error(w, `{"message": "User not found"}`, http.StatusNotFound)
-		return
-	}
-
 	foundSubscription := false
 	userSubscriptions := false
 
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"JobScoop/internal/db"
+	"JobScoop/internal/middleware"
 	"bytes"
 	"database/sql"
 	"encoding/json"
@@ -16,14 +17,12 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-// Mock implementations of helper functions
-func mockGetUserIDByEmail(email string) (int, error) {
-	if email == "test@example.com" {
-		return 1, nil
-	}
-	return 0, errors.New("user not found")
+// withUserID attaches the user ID that middleware.Auth would have put on the context
+func withUserID(r *http.Request, userID int) *http.Request {
+	return r.WithContext(middleware.WithUserID(r.Context(), userID))
 }
 
+// Mock implementations of helper functions
 func mockGetOrCreateCompanyID(companyName string) (int, error) {
 	return 1, nil
 }
@@ -68,14 +67,12 @@ func TestSaveSubscriptionsHandler(t *testing.T) {
 	db.DB = mockDB
 
 	// Override function pointers with mock functions
-	getUserIDByEmailFunc = mockGetUserIDByEmail
 	getOrCreateCompanyIDFunc = mockGetOrCreateCompanyID
 	getOrCreateCareerSiteIDFunc = mockGetOrCreateCareerSiteID
 	getOrCreateRoleIDFunc = mockGetOrCreateRoleID
 
 	// Construct request payload
 	reqBody := map[string]interface{}{
-		"email": "test@example.com",
 		"subscriptions": []map[string]interface{}{
 			{
 				"companyName": "Test Company",
@@ -97,7 +94,7 @@ func TestSaveSubscriptionsHandler(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	// Create a request
-	r := httptest.NewRequest("POST", "/save-subscription", bytes.NewBuffer(jsonData))
+	r := withUserID(httptest.NewRequest("POST", "/save-subscription", bytes.NewBuffer(jsonData)), 1)
 	r.Header.Set("Content-Type", "application/json")
 
 	// Create a ResponseRecorder to capture the response
@@ -134,20 +131,17 @@ func TestFetchUserSubscriptionsHandler(t *testing.T) {
 	getCompanyNameByIDFunc = mockGetCompanyNameByID
 	getCareerSiteLinkByIDFunc = mockGetCareerSiteLinkByID
 	getRoleNameByIDFunc = mockGetRoleNameByID
-	getUserIDByEmailFunc = mockGetUserIDByEmail
 
 	// Mock SQL query for subscriptions
-	rows := sqlmock.NewRows([]string{"id", "company_id", "career_site_ids", "role_ids"}).
-		AddRow(1, 1, "{1,2}", "{1,2}")
+	rows := sqlmock.NewRows([]string{"id", "company_id", "career_site_ids", "role_ids", "active", "feed_links"}).
+		AddRow(1, 1, "{1,2}", "{1,2}", true, "{https://mock-career.com/jobs.rss}")
 
-	mock.ExpectQuery(`SELECT id, company_id, career_site_ids, role_ids FROM subscriptions WHERE user_id=\$1`).
+	mock.ExpectQuery(`SELECT id, company_id, career_site_ids, role_ids, active, feed_links FROM subscriptions WHERE user_id=\$1`).
 		WithArgs(1).
 		WillReturnRows(rows)
 
 	// Prepare test request
-	reqBody, _ := json.Marshal(map[string]string{"email": "test@example.com"})
-	req := httptest.NewRequest(http.MethodPost, "/subscriptions", bytes.NewReader(reqBody))
-	req.Header.Set("Content-Type", "application/json")
+	req := withUserID(httptest.NewRequest(http.MethodPost, "/subscriptions", nil), 1)
 
 	// Capture response
 	respRecorder := httptest.NewRecorder()
@@ -184,17 +178,16 @@ func TestUpdateSubscriptionsHandler(t *testing.T) {
 	defer mockDB.Close()
 	db.DB = mockDB
 
-	getUserIDByEmailFunc = mockGetUserIDByEmail
 	getCompanyIDIfExistsFunc = mockGetCompanyIDIfExists
 	getOrCreateCareerSiteIDFunc = mockGetOrCreateCareerSiteID
 	getOrCreateRoleIDFunc = mockGetOrCreateRoleID
 
 	// Define request payload
 	reqBody := UpdateSubscriptionsRequest{
-		Email: "test@example.com",
 		Subscriptions: []struct {
 			CompanyName string   `json:"companyName"`
 			CareerLinks []string `json:"careerLinks,omitempty"`
+			FeedLinks   []string `json:"feedLinks,omitempty"`
 			RoleNames   []string `json:"roleNames,omitempty"`
 			Active    *bool    `json:"active,omitempty"`
 		}{
@@ -216,7 +209,7 @@ func TestUpdateSubscriptionsHandler(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	// Create request
-	req := httptest.NewRequest(http.MethodPost, "/update-subscriptions", bytes.NewReader(body))
+	req := withUserID(httptest.NewRequest(http.MethodPost, "/update-subscriptions", bytes.NewReader(body)), 1)
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
@@ -256,10 +249,10 @@ func TestDeleteSubscriptionsHandler(t *testing.T) {
 	defer mockDB.Close()
 
 	db.DB = mockDB // Assign mockDB to the actual DB variable
-	getUserIDByEmailFunc = mockGetUserIDByEmail
 	getCompanyIDIfExistsFunc = mockGetCompanyIDIfExists
 	tests := []struct {
 		name           string
+		userID         int
 		requestBody    map[string]interface{}
 		expectDBCalls  bool
 		mockDBResponse func()
@@ -267,9 +260,9 @@ func TestDeleteSubscriptionsHandler(t *testing.T) {
 		expectedBody   string
 	}{
 		{
-			name: "Valid request - subscription deleted",
+			name:   "Valid request - subscription deleted",
+			userID: 1,
 			requestBody: map[string]interface{}{
-				"email":         "test@example.com",
 				"subscriptions": []string{"TestCompany"},
 			},
 			expectDBCalls: true,
@@ -282,30 +275,20 @@ func TestDeleteSubscriptionsHandler(t *testing.T) {
 			expectedBody:   `{"message":"Deleted subscription(s) successfully","status":"success"}`,
 		},
 		{
-			name: "Invalid request - missing email",
+			name: "Invalid request - unauthenticated",
 			requestBody: map[string]interface{}{
+				"email":         "test@example.com",
 				"subscriptions": []string{"TestCompany"},
 			},
 			expectDBCalls:  false,
 			mockDBResponse: nil,
-			expectedStatus: http.StatusBadRequest,
-			expectedBody:   `{"message": "Email is required"}`,
-		},
-		{
-			name: "Invalid request - user not found",
-			requestBody: map[string]interface{}{
-				"email":         "unknown@example.com",
-				"subscriptions": []string{"TestCompany"},
-			},
-			expectDBCalls:  false,
-			mockDBResponse: nil,
-			expectedStatus: http.StatusNotFound,
-			expectedBody:   `{"message": "User not found"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"message": "Unauthorized"}`,
 		},
 		{
-			name: "Invalid request - no subscriptions provided",
+			name:   "Invalid request - no subscriptions provided",
+			userID: 1,
 			requestBody: map[string]interface{}{
-				"email":         "test@example.com",
 				"subscriptions": []string{},
 			},
 			expectDBCalls:  false,
@@ -324,6 +307,9 @@ func TestDeleteSubscriptionsHandler(t *testing.T) {
 			reqBody, _ := json.Marshal(tt.requestBody)
 			req := httptest.NewRequest("POST", "/delete-subscriptions", bytes.NewBuffer(reqBody))
 			req.Header.Set("Content-Type", "application/json")
+			if tt.userID != 0 {
+				req = withUserID(req, tt.userID)
+			}
 			w := httptest.NewRecorder()
 
 			DeleteSubscriptionsHandler(w, req)
@@ -412,4 +398,80 @@ func TestFetchAllSubscriptionsHandler(t *testing.T) {
     if err := mock.ExpectationsWereMet(); err != nil {
         t.Errorf("unmet expectations: %v", err)
     }
-}
\ No newline at end of file
+}
+func TestSaveSubscriptionsHandlerFeedLinks(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	getOrCreateCompanyIDFunc = mockGetOrCreateCompanyID
+	getOrCreateCareerSiteIDFunc = mockGetOrCreateCareerSiteID
+	getOrCreateRoleIDFunc = mockGetOrCreateRoleID
+
+	body := `{"subscriptions": [{"companyName": "Test Company", "roleNames": ["Software Engineer"],
+		"feedLinks": [" https://test.com/jobs.rss", "https://test.com/jobs.rss", "https://board.example/feed.atom"]}]}`
+
+	mock.ExpectQuery("SELECT career_site_ids, role_ids FROM subscriptions").
+		WithArgs(1, 1).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("INSERT INTO subscriptions").
+		WithArgs(1, 1, pq.Array([]int64{}), pq.Array([]int64{1}), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE subscriptions\\s+SET feed_links = ARRAY\\(SELECT DISTINCT unnest\\(feed_links \\|\\| \\$1::TEXT\\[\\]\\) ORDER BY 1\\)").
+		WithArgs(pq.Array([]string{"https://test.com/jobs.rss", "https://board.example/feed.atom"}), 1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := httptest.NewRecorder()
+	SaveSubscriptionsHandler(w, withUserID(httptest.NewRequest(http.MethodPost, "/save-subscription", strings.NewReader(body)), 1))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// A link that is not a web feed is refused before anything is saved
+	w = httptest.NewRecorder()
+	body = `{"subscriptions": [{"companyName": "Test Company", "feedLinks": ["ftp://test.com/jobs.rss"]}]}`
+	SaveSubscriptionsHandler(w, withUserID(httptest.NewRequest(http.MethodPost, "/save-subscription", strings.NewReader(body)), 1))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// So is one into the server's own network
+	for _, link := range []string{"http://169.254.169.254/latest/meta-data/", "http://localhost:8080/jobs.rss", "http://[::1]/jobs.rss"} {
+		w = httptest.NewRecorder()
+		body = `{"subscriptions": [{"companyName": "Test Company", "feedLinks": ["` + link + `"]}]}`
+		SaveSubscriptionsHandler(w, withUserID(httptest.NewRequest(http.MethodPost, "/save-subscription", strings.NewReader(body)), 1))
+		assert.Equal(t, http.StatusBadRequest, w.Code, link)
+	}
+}
+
+func TestUpdateSubscriptionsHandlerFeedLinks(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	getCompanyIDIfExistsFunc = mockGetCompanyIDIfExists
+
+	tests := []struct {
+		name      string
+		feedLinks string
+		want      []string
+	}{
+		{"replace", `["https://test.com/jobs.rss"]`, []string{"https://test.com/jobs.rss"}},
+		{"remove all", `[]`, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.ExpectQuery("SELECT id FROM subscriptions WHERE user_id=\\$1 AND company_id=\\$2").
+				WithArgs(1, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectExec("UPDATE subscriptions\\s+SET feed_links=\\$1, interest_time=\\$2").
+				WithArgs(pq.Array(tt.want), sqlmock.AnyArg(), 1, 1).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			body := `{"subscriptions": [{"companyName": "TestCompany", "feedLinks": ` + tt.feedLinks + `}]}`
+			w := httptest.NewRecorder()
+			UpdateSubscriptionsHandler(w, withUserID(httptest.NewRequest(http.MethodPost, "/update-subscriptions", strings.NewReader(body)), 1))
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
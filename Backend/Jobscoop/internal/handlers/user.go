@@ -2,19 +2,20 @@ package handlers
 
 import (
 	"JobScoop/internal/db"
+	"JobScoop/internal/mailer"
+	"JobScoop/internal/middleware"
+	"JobScoop/internal/services"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"math/big"
 	"net/http"
+	"strconv"
 
 	"crypto/rand"
-	"net/smtp"
-	"os"
 	"time"
-
-	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -24,11 +25,6 @@ type User struct {
 	Password string `json:"password"`
 }
 
-type Claims struct {
-	UserID int `json:"user_id"`
-	jwt.RegisteredClaims
-}
-
 func SetDB(database *sql.DB) {
 	db.DB = database
 }
@@ -73,8 +69,11 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Insert the new user into the database
-	_, err = db.DB.Exec("INSERT INTO users (name, email, password) VALUES ($1, $2, $3)", user.Name, user.Email, string(hashedPassword))
+	// Insert the new user into the database; the address stays pending until the emailed link is used
+	_, err = db.DB.Exec(
+		"INSERT INTO users (name, email, password, email_status, verification_sent_at) VALUES ($1, $2, $3, 'pending', $4)",
+		user.Name, user.Email, string(hashedPassword), time.Now().UTC(),
+	)
 	if err != nil {
 		http.Error(w, "Error inserting user", http.StatusInternalServerError)
 		return
@@ -88,18 +87,13 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate JWT token
-	expirationTime := time.Now().Add(1 * time.Hour) // Token expires in 1 hour
-	claims := &Claims{
-		UserID: int(userID), // Store user ID in token claims
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			Issuer:    "jobscoop",
-		},
+	// A failed send is not fatal, the user can ask for a new link from /resend-verification
+	if err := sendVerificationEmail(userID, user.Email); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", userID, err)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(os.Getenv("JWT_TOKEN")))
+	// Generate the access token and start a refresh token session
+	signedToken, refreshToken, err := issueTokenPair(userID, "")
 	if err != nil {
 		fmt.Println(err)
 		http.Error(w, "Error signing the token", http.StatusInternalServerError)
@@ -110,9 +104,11 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "User created successfully",
-		"token":   signedToken,
-		"userid":  userID,
+		"message":       "User created successfully",
+		"token":         signedToken,
+		"refresh_token": refreshToken,
+		"userid":        userID,
+		"email_status":  "pending",
 	})
 }
 
@@ -136,43 +132,81 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := clientIP(r)
+	emailKey := accountThrottleKey(loginRequest.Email)
+	throttleKeys := []string{emailKey, ipThrottleKey(ip)}
+
+	// Refuse early while the account or the client address is locked out
+	lockedUntil, err := loginLockedUntilFunc(throttleKeys)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !lockedUntil.IsZero() {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(lockedUntil).Seconds()))))
+		http.Error(w, "Too many failed login attempts. Please try again later.", http.StatusTooManyRequests)
+		return
+	}
+
 	// Check if the user exists
 	var storedHashedPassword string
 	var userID int
-	err = db.DB.QueryRow("SELECT id, password FROM users WHERE email=$1", loginRequest.Email).Scan(&userID, &storedHashedPassword)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "User does not exist. Please sign up.", http.StatusNotFound)
-		} else {
+	var totpEnabled bool
+	err = db.DB.QueryRow("SELECT id, password, totp_enabled FROM users WHERE email=$1", loginRequest.Email).Scan(&userID, &storedHashedPassword, &totpEnabled)
+	if err != nil && err != sql.ErrNoRows {
+		fmt.Println(err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Unknown emails and wrong passwords fail the same way, in roughly the same time
+	hash := []byte(storedHashedPassword)
+	if err == sql.ErrNoRows {
+		hash = dummyPasswordHash
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(loginRequest.Password)) != nil || err == sql.ErrNoRows {
+		if err := recordLoginFailureFunc(throttleKeys); err != nil {
+			fmt.Println(err)
+		}
+		if err := recordLoginAttemptFunc(userID, loginRequest.Email, ip, r.UserAgent(), false); err != nil {
 			fmt.Println(err)
-			http.Error(w, "Database error", http.StatusInternalServerError)
 		}
+		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 		return
 	}
 
-	// Compare the hashed input password with the stored hashed password
-	err = bcrypt.CompareHashAndPassword([]byte(storedHashedPassword), []byte(loginRequest.Password))
-	if err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
-		return
+	if err := clearLoginFailuresFunc(emailKey); err != nil {
+		fmt.Println(err)
 	}
 
-	// Successfully authenticated, create the JWT token
-	expirationTime := time.Now().Add(1 * time.Hour) // Set expiration time for 24 hours
-	claims := &Claims{
-		UserID: userID, // Store the user ID in the token claims
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			Issuer:    "jobscoop", // You can customize this
-		},
+	// With two-factor enabled the password only earns a short-lived token for VerifyMFAHandler
+	if totpEnabled {
+		mfaToken, err := services.IssueMFAToken(userID)
+		if err != nil {
+			fmt.Println(err)
+			http.Error(w, "Error signing the token", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":      "Two-factor authentication required",
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		})
+		return
 	}
 
-	// Create a new JWT token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if err := recordLoginAttemptFunc(userID, loginRequest.Email, ip, r.UserAgent(), true); err != nil {
+		fmt.Println(err)
+	}
 
-	// Sign the token with the secret key
-	signedToken, err := token.SignedString([]byte(os.Getenv("JWT_TOKEN")))
-	if err != nil {
+	// Successfully authenticated, create the access token and a new refresh token session
+	signedToken, refreshToken, err := issueTokenPair(userID, "")
+	if err == errAccountDisabled {
+		http.Error(w, "This account has been disabled", http.StatusForbidden)
+		return
+	} else if err != nil {
 		fmt.Println(err)
 		http.Error(w, "Error signing the token", http.StatusInternalServerError)
 		return
@@ -182,13 +216,19 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Login successful",
-		"token":   signedToken,
-		"userid":  userID,
+		"message":       "Login successful",
+		"token":         signedToken,
+		"refresh_token": refreshToken,
+		"userid":        userID,
 	})
 }
 
-var sendResetEmailFunc = sendResetEmail // Assign function to a variable for mocking
+const (
+	resetCodeTTL     = 15 * time.Minute
+	resetGrantTTL    = 10 * time.Minute
+	maxResetAttempts = 5
+	resetLockout     = 15 * time.Minute
+)
 
 func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
 	// Struct to decode the request payload
@@ -221,24 +261,38 @@ func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Generate token and expiration time
 	token := generateResetToken()
-	expiration := time.Now().UTC().Add(15 * time.Minute) // Token expires in 15 min
+	now := time.Now().UTC()
+	expiration := now.Add(resetCodeTTL)
 
-	// Store token in database (Insert or Update)
-	_, err = db.DB.Exec(
+	// Only a bcrypt hash of the code is stored, the six digits are too guessable for a plain hash
+	hashedToken, err := bcrypt.GenerateFromPassword([]byte(token), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Failed to hash code", http.StatusInternalServerError)
+		return
+	}
+
+	// Store token in database (Insert or Update). The failed attempt counter is kept so that
+	// requesting a new code does not hand out fresh guesses, and a locked email gets no new code.
+	res, err := db.DB.Exec(
 		`INSERT INTO reset_tokens (email, token, expires_at) 
 		 VALUES ($1, $2, $3) 
 		 ON CONFLICT(email) 
-		 DO UPDATE SET token=$2, expires_at=$3`,
-		email, token, expiration,
+		 DO UPDATE SET token=$2, expires_at=$3, grant_hash=NULL, grant_expires_at=NULL
+		 WHERE reset_tokens.locked_until IS NULL OR reset_tokens.locked_until <= $4`,
+		email, string(hashedToken), expiration, now,
 	)
 	if err != nil {
 		fmt.Println(err)
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
+	if count, err := res.RowsAffected(); err == nil && count == 0 {
+		http.Error(w, "Too many failed attempts. Please try again later.", http.StatusTooManyRequests)
+		return
+	}
 
 	// Send reset email
-	err = sendResetEmailFunc(email, token)
+	err = sendResetEmail(email, token)
 	if err != nil {
 		http.Error(w, "Failed to send email", http.StatusInternalServerError)
 		return
@@ -266,26 +320,37 @@ func generateResetToken() string {
 }
 
 func sendResetEmail(email, token string) error {
-	SMTP_HOST := os.Getenv("SMTP_HOST")
-	SMTP_PORT := os.Getenv("SMTP_PORT")
-	SMTP_USER := os.Getenv("SMTP_USER")
-	SMTP_PASS := os.Getenv("SMTP_PASS")
-	auth := smtp.PlainAuth("", SMTP_USER, SMTP_PASS, SMTP_HOST)
+	err := sendEmail(email, "reset_code", map[string]interface{}{
+		"Code":             token,
+		"ExpiresInMinutes": int(resetCodeTTL / time.Minute),
+	})
+	if err != nil {
+		return err
+	}
 
-	subject := "Subject: Password Reset Request\n"
-	body := "Copy this code to reset your password: " + token + "\n"
-	message := []byte(subject + "\n" + body)
+	log.Println("Password reset email queued")
+	return nil
+}
 
-	err := smtp.SendMail(SMTP_HOST+":"+SMTP_PORT, auth, SMTP_USER, []string{email}, message)
+// sendEmail renders the named email template and queues it for delivery.
+// Users have no preferred language yet, so every email goes out in the default locale.
+func sendEmail(to, template string, data map[string]interface{}) error {
+	msg, err := mailer.Render(template, mailer.DefaultLocale, data)
 	if err != nil {
-		log.Printf("Failed to send email: %v", err)
+		log.Printf("Failed to render email %s: %v", template, err)
+		return err
+	}
+	msg.To = []string{to}
+	err = mailer.Enqueue(msg)
+	if err != nil {
+		log.Printf("Failed to queue email: %v", err)
 		return err
 	}
-
-	log.Println("Password reset email sent successfully!")
 	return nil
 }
 
+// VerifyCodeHandler checks an emailed reset code and exchanges it for a short-lived, single-use reset grant.
+// Every guess counts against the email; too many failures discard the code and lock the email for a while.
 func VerifyCodeHandler(w http.ResponseWriter, r *http.Request) {
 	// Struct to decode the request payload
 	var request struct {
@@ -305,17 +370,28 @@ func VerifyCodeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Fetch stored token for the email from the database
+	now := time.Now().UTC()
+
+	// Count the attempt before checking it, so concurrent guesses cannot exceed the limit
 	var storedToken string
 	var expiresAt time.Time
-
+	var failedAttempts int
 	err = db.DB.QueryRow(
-		"SELECT token, expires_at FROM reset_tokens WHERE email=$1",
-		request.Email,
-	).Scan(&storedToken, &expiresAt)
+		`UPDATE reset_tokens SET failed_attempts = failed_attempts + 1
+		 WHERE email=$1 AND token IS NOT NULL AND (locked_until IS NULL OR locked_until <= $2)
+		 RETURNING token, expires_at, failed_attempts`,
+		request.Email, now,
+	).Scan(&storedToken, &expiresAt, &failedAttempts)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
+			// Either there is no pending code or the email is locked out
+			var lockedUntil sql.NullTime
+			err = db.DB.QueryRow("SELECT locked_until FROM reset_tokens WHERE email=$1", request.Email).Scan(&lockedUntil)
+			if err == nil && lockedUntil.Valid && lockedUntil.Time.After(now) {
+				http.Error(w, "Too many failed attempts. Please try again later.", http.StatusTooManyRequests)
+				return
+			}
 			http.Error(w, "No reset request found for this email", http.StatusNotFound)
 			return
 		}
@@ -324,26 +400,61 @@ func VerifyCodeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if the token has expired
-	if time.Now().UTC().After(expiresAt) {
+	if now.After(expiresAt) {
 		http.Error(w, "Token has expired", http.StatusUnauthorized)
 		return
 	}
 
 	// Check if the token matches
-	if storedToken != request.Token {
+	if bcrypt.CompareHashAndPassword([]byte(storedToken), []byte(request.Token)) != nil {
+		if failedAttempts >= maxResetAttempts {
+			// Burn the code and lock the email; a new code can be requested after the lockout
+			_, err = db.DB.Exec(
+				"UPDATE reset_tokens SET token=NULL, failed_attempts=0, locked_until=$2 WHERE email=$1",
+				request.Email, now.Add(resetLockout),
+			)
+			if err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			http.Error(w, "Too many failed attempts. Please try again later.", http.StatusTooManyRequests)
+			return
+		}
 		http.Error(w, "Invalid verification code", http.StatusUnauthorized)
 		return
 	}
 
+	// The code is consumed here; only the grant can be used from now on
+	grant, err := randomToken(32)
+	if err != nil {
+		http.Error(w, "Failed to issue reset grant", http.StatusInternalServerError)
+		return
+	}
+	_, err = db.DB.Exec(
+		`UPDATE reset_tokens SET token=NULL, failed_attempts=0, locked_until=NULL, grant_hash=$2, grant_expires_at=$3
+		 WHERE email=$1`,
+		request.Email, hashToken(grant), now.Add(resetGrantTTL),
+	)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
 	// Success response
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Verification successful"))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Verification successful",
+		"reset_grant": grant,
+	})
 }
 
+// ResetPasswordHandler sets a new password for the holder of a reset grant from VerifyCodeHandler.
 func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 	// Struct to decode the request payload
 	var request struct {
 		Email       string `json:"email"`
+		ResetGrant  string `json:"reset_grant"`
 		NewPassword string `json:"new_password"`
 	}
 
@@ -359,16 +470,21 @@ func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if the user exists
-	var existingEmail string
+	if request.ResetGrant == "" {
+		http.Error(w, "Reset grant is required", http.StatusUnauthorized)
+		return
+	}
+
+	// Consume the grant atomically so it can only ever be used once
+	var resetID int
 	err = db.DB.QueryRow(
-		"SELECT email FROM users WHERE email=$1",
-		request.Email,
-	).Scan(&existingEmail)
+		`DELETE FROM reset_tokens WHERE email=$1 AND grant_hash=$2 AND grant_expires_at > $3 RETURNING id`,
+		request.Email, hashToken(request.ResetGrant), time.Now().UTC(),
+	).Scan(&resetID)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			http.Error(w, "User not found", http.StatusNotFound)
+			http.Error(w, "Invalid or expired reset grant", http.StatusUnauthorized)
 			return
 		}
 		http.Error(w, "Database error", http.StatusInternalServerError)
@@ -383,52 +499,57 @@ func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update the user's password in the database
-	_, err = db.DB.Exec(
-		"UPDATE users SET password=$1 WHERE email=$2",
+	var userID int
+	err = db.DB.QueryRow(
+		"UPDATE users SET password=$1 WHERE email=$2 RETURNING id",
 		hashedPassword, request.Email,
-	)
+	).Scan(&userID)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Failed to update password", http.StatusInternalServerError)
 		return
 	}
 
+	// Whoever knew the old password should not stay logged in, nor keep API keys they created
+	if err := revokeAllSessions(userID); err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	if _, err := db.DB.Exec(`UPDATE api_keys SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`, time.Now().UTC(), userID); err != nil {
+		http.Error(w, "Failed to revoke API keys", http.StatusInternalServerError)
+		return
+	}
+
 	// Success response
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Password reset successfully"))
 }
 
-type GetUserRequest struct {
-	Email string `json:"email"`
-}
-
 // GetUserResponse represents the response structure
 type GetUserResponse struct {
-	Name      string `json:"name"`
-	Email     string `json:"email"`
-	CreatedAt string `json:"created_at"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	EmailStatus string `json:"email_status"`
+	CreatedAt   string `json:"created_at"`
 }
 
+// GetUser returns the profile of the authenticated user.
 func GetUser(w http.ResponseWriter, r *http.Request) {
-	// Parse the request body
-	var req GetUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"message": "Invalid request payload"}`, http.StatusBadRequest)
-		return
-	}
-	defer r.Body.Close()
-
-	// Validate required fields
-	if req.Email == "" {
-		http.Error(w, `{"message": "Email is required"}`, http.StatusBadRequest)
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
 
 	// Query the database for the user
 	var user GetUserResponse
 	err := db.DB.QueryRow(
-		`SELECT name, email, created_at FROM users WHERE email = $1`,
-		req.Email,
-	).Scan(&user.Name, &user.Email, &user.CreatedAt)
+		`SELECT name, email, email_status, created_at FROM users WHERE id = $1`,
+		userID,
+	).Scan(&user.Name, &user.Email, &user.EmailStatus, &user.CreatedAt)
 
 	if err != nil {
 		// Check if no rows were returned
@@ -453,12 +574,17 @@ func GetUser(w http.ResponseWriter, r *http.Request) {
 
 // UpdateUserRequest represents the expected JSON payload for updating a user.
 type UpdateUserRequest struct {
-	Email string `json:"email"`
-	Name  string `json:"name"`
+	Name string `json:"name"`
 }
 
-// UpdateUser updates the name of a user identified by their email.
+// UpdateUser updates the name of the authenticated user. Email changes go through ChangeEmailHandler.
 func UpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
 	// Parse the request body.
 	var req UpdateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -468,13 +594,13 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	// Validate required fields.
-	if req.Email == "" || req.Name == "" {
-		http.Error(w, `{"message": "Email and Name are required"}`, http.StatusBadRequest)
+	if req.Name == "" {
+		http.Error(w, `{"message": "Name is required"}`, http.StatusBadRequest)
 		return
 	}
 
 	// Update the user's name in the database.
-	_, err := db.DB.Exec(`UPDATE users SET name = $1 WHERE email = $2`, req.Name, req.Email)
+	_, err := db.DB.Exec(`UPDATE users SET name = $1 WHERE id = $2`, req.Name, userID)
 	if err != nil {
 		http.Error(w, `{"message": "Error updating user"}`, http.StatusInternalServerError)
 		return
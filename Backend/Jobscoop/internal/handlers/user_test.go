@@ -2,27 +2,46 @@ package handlers
 
 import (
 	"JobScoop/internal/db"
+	"JobScoop/internal/mailer"
+	"JobScoop/internal/services"
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
 func init() {
-	os.Setenv("JWT_TOKEN", "test_secret")
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	services.SetKeySet(services.NewKeySet(&services.SigningKey{
+		ID:         "test",
+		Method:     jwt.SigningMethodEdDSA,
+		PrivateKey: key,
+	}))
 }
 
 var originalDb *sql.DB
 
+// useMemoryMailer records the emails sent during the test instead of sending them
+func useMemoryMailer(t *testing.T) *mailer.Memory {
+	m := mailer.NewMemory()
+	mailer.Set(m)
+	t.Cleanup(func() { mailer.Set(nil) })
+	return m
+}
+
 func TestSignupHandler(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -33,6 +52,9 @@ func TestSignupHandler(t *testing.T) {
 	originalDb = GetDB()
 	SetDB(db)
 	defer SetDB(originalDb)
+
+	sent := useMemoryMailer(t)
+
 	tests := []struct {
 		name         string
 		requestBody  map[string]string
@@ -54,14 +76,20 @@ func TestSignupHandler(t *testing.T) {
 					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
 				// Insert new user
-				mock.ExpectExec("INSERT INTO users").
-					WithArgs("John Doe", "john@example.com", sqlmock.AnyArg()).
+				// Insert new user as pending verification
+				mock.ExpectExec("INSERT INTO users \\(name, email, password, email_status, verification_sent_at\\) VALUES \\(\\$1, \\$2, \\$3, 'pending', \\$4\\)").
+					WithArgs("John Doe", "john@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 
 				// Fetch newly created user ID
 				mock.ExpectQuery("SELECT id FROM users WHERE email = \\$1").
 					WithArgs("john@example.com").
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+				// Start a refresh token session
+				mock.ExpectQuery("INSERT INTO refresh_tokens").
+					WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"session_generation"}).AddRow(0))
 			},
 			expectedCode: http.StatusCreated,
 			expectedMsg:  "User created successfully",
@@ -129,6 +157,10 @@ func TestSignupHandler(t *testing.T) {
 					t.Errorf("Expected message '%s', got '%s'", tt.expectedMsg, message)
 				}
 			}
+
+			if msgs := sent.Messages(); rr.Code == http.StatusCreated && (len(msgs) != 1 || msgs[0].To[0] != "john@example.com") {
+				t.Errorf("Expected a verification email to john@example.com, got %+v", msgs)
+			}
 		})
 	}
 
@@ -148,12 +180,33 @@ func TestLoginHandler(t *testing.T) {
 	// Hash a sample password for comparison
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("securepassword"), bcrypt.DefaultCost)
 
+	// Throttling and history are stubbed out; login_security_test.go covers their SQL
+	var lockedUntil time.Time
+	var failures, successes, cleared int
+	loginLockedUntilFunc = func(keys []string) (time.Time, error) { return lockedUntil, nil }
+	recordLoginFailureFunc = func(keys []string) error { failures++; return nil }
+	clearLoginFailuresFunc = func(key string) error { cleared++; return nil }
+	recordLoginAttemptFunc = func(userID int, email, ip, userAgent string, success bool) error {
+		if success {
+			successes++
+		}
+		return nil
+	}
+	defer func() {
+		loginLockedUntilFunc = loginLockedUntil
+		recordLoginFailureFunc = recordLoginFailure
+		clearLoginFailuresFunc = clearLoginFailures
+		recordLoginAttemptFunc = recordLoginAttempt
+	}()
+
 	tests := []struct {
-		name         string
-		requestBody  map[string]string
-		mockSetup    func()
-		expectedCode int
-		expectedMsg  string
+		name             string
+		requestBody      map[string]string
+		locked           bool
+		mockSetup        func()
+		expectedCode     int
+		expectedMsg      string
+		expectedFailures int
 	}{
 		{
 			name: "Successful Login",
@@ -162,9 +215,13 @@ func TestLoginHandler(t *testing.T) {
 				"password": "securepassword",
 			},
 			mockSetup: func() {
-				mock.ExpectQuery("SELECT id, password FROM users WHERE email=\\$1").
+				mock.ExpectQuery("SELECT id, password, totp_enabled FROM users WHERE email=\\$1").
 					WithArgs("john@example.com").
-					WillReturnRows(sqlmock.NewRows([]string{"id", "password"}).AddRow(1, string(hashedPassword)))
+					WillReturnRows(sqlmock.NewRows([]string{"id", "password", "totp_enabled"}).AddRow(1, string(hashedPassword), false))
+
+				mock.ExpectQuery("INSERT INTO refresh_tokens").
+					WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"session_generation"}).AddRow(0))
 			},
 			expectedCode: http.StatusOK,
 			expectedMsg:  "Login successful",
@@ -176,12 +233,13 @@ func TestLoginHandler(t *testing.T) {
 				"password": "somepassword",
 			},
 			mockSetup: func() {
-				mock.ExpectQuery("SELECT id, password FROM users WHERE email=\\$1").
+				mock.ExpectQuery("SELECT id, password, totp_enabled FROM users WHERE email=\\$1").
 					WithArgs("nonexistent@example.com").
 					WillReturnError(sql.ErrNoRows)
 			},
-			expectedCode: http.StatusNotFound,
-			expectedMsg:  "User does not exist. Please sign up.",
+			expectedCode:     http.StatusUnauthorized,
+			expectedMsg:      "Invalid email or password",
+			expectedFailures: 1,
 		},
 		{
 			name: "Invalid Password",
@@ -190,12 +248,57 @@ func TestLoginHandler(t *testing.T) {
 				"password": "wrongpassword",
 			},
 			mockSetup: func() {
-				mock.ExpectQuery("SELECT id, password FROM users WHERE email=\\$1").
+				mock.ExpectQuery("SELECT id, password, totp_enabled FROM users WHERE email=\\$1").
 					WithArgs("john@example.com").
-					WillReturnRows(sqlmock.NewRows([]string{"id", "password"}).AddRow(1, string(hashedPassword)))
+					WillReturnRows(sqlmock.NewRows([]string{"id", "password", "totp_enabled"}).AddRow(1, string(hashedPassword), false))
 			},
-			expectedCode: http.StatusUnauthorized,
-			expectedMsg:  "Invalid credentials",
+			expectedCode:     http.StatusUnauthorized,
+			expectedMsg:      "Invalid email or password",
+			expectedFailures: 1,
+		},
+		{
+			name: "Two-Factor Enabled",
+			requestBody: map[string]string{
+				"email":    "john@example.com",
+				"password": "securepassword",
+			},
+			mockSetup: func() {
+				// No refresh token yet: the password only earns an mfa pending token
+				mock.ExpectQuery("SELECT id, password, totp_enabled FROM users WHERE email=\\$1").
+					WithArgs("john@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "password", "totp_enabled"}).AddRow(1, string(hashedPassword), true))
+			},
+			expectedCode: http.StatusOK,
+			expectedMsg:  "mfa_token",
+		},
+		{
+			name: "Disabled Account",
+			requestBody: map[string]string{
+				"email":    "john@example.com",
+				"password": "securepassword",
+			},
+			mockSetup: func() {
+				mock.ExpectQuery("SELECT id, password, totp_enabled FROM users WHERE email=\\$1").
+					WithArgs("john@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "password", "totp_enabled"}).AddRow(1, string(hashedPassword), false))
+
+				// The session insert is skipped for disabled users
+				mock.ExpectQuery("INSERT INTO refresh_tokens").
+					WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"session_generation"}))
+			},
+			expectedCode: http.StatusForbidden,
+			expectedMsg:  "This account has been disabled",
+		},
+		{
+			name: "Locked Out",
+			requestBody: map[string]string{
+				"email":    "john@example.com",
+				"password": "securepassword",
+			},
+			locked:       true,
+			mockSetup:    func() {},
+			expectedCode: http.StatusTooManyRequests,
 		},
 	}
 
@@ -203,6 +306,11 @@ func TestLoginHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup mock expectations
 			tt.mockSetup()
+			failures, successes, cleared = 0, 0, 0
+			lockedUntil = time.Time{}
+			if tt.locked {
+				lockedUntil = time.Now().Add(time.Minute)
+			}
 
 			// Convert requestBody to JSON
 			reqBody, _ := json.Marshal(tt.requestBody)
@@ -225,19 +333,24 @@ func TestLoginHandler(t *testing.T) {
 			if rr.Code != tt.expectedCode {
 				t.Errorf("Expected status %d, got %d", tt.expectedCode, rr.Code)
 			}
-
-			// Parse response body
-			var response map[string]interface{}
-			json.Unmarshal(rr.Body.Bytes(), &response)
-
-			// Check response message
-			if message, exists := response["message"]; exists {
-				if message != tt.expectedMsg {
-					t.Errorf("Expected message '%s', got '%s'", tt.expectedMsg, message)
-				}
+			if tt.expectedMsg != "" && !strings.Contains(rr.Body.String(), tt.expectedMsg) {
+				t.Errorf("Expected message '%s', got '%s'", tt.expectedMsg, rr.Body.String())
+			}
+			if tt.locked && rr.Header().Get("Retry-After") == "" {
+				t.Errorf("Expected a Retry-After header")
+			}
+			if failures != tt.expectedFailures {
+				t.Errorf("Expected %d recorded failures, got %d", tt.expectedFailures, failures)
+			}
+			if tt.expectedMsg == "Login successful" && (successes != 1 || cleared != 1) {
+				t.Errorf("Expected the success to be recorded and the throttle cleared")
 			}
 		})
 	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
 }
 
 func TestForgotPasswordHandler(t *testing.T) {
@@ -251,9 +364,8 @@ func TestForgotPasswordHandler(t *testing.T) {
 	SetDB(db)
 	defer SetDB(originalDb)
 
-	sendResetEmailFunc = func(email, token string) error {
-		return nil // Pretend email is sent successfully
-	}
+	sent := useMemoryMailer(t)
+	resetCode := regexp.MustCompile(`\b\d{6}\b`)
 
 	tests := []struct {
 		name         string
@@ -275,12 +387,29 @@ func TestForgotPasswordHandler(t *testing.T) {
 
 				// Insert or update reset token
 				mock.ExpectExec(`INSERT INTO reset_tokens \(email, token, expires_at\) .* ON CONFLICT\(email\) DO UPDATE`).
-					WithArgs("john@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WithArgs("john@example.com", bcryptHash{}, sqlmock.AnyArg(), sqlmock.AnyArg()).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 			expectedCode: http.StatusOK,
 			expectedMsg:  "Password reset email sent successfully!",
 		},
+		{
+			name: "Email Locked Out",
+			requestBody: map[string]string{
+				"email": "john@example.com",
+			},
+			mockSetup: func() {
+				mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM users WHERE email=\$1\)`).
+					WithArgs("john@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+				// The upsert is skipped while locked_until is in the future
+				mock.ExpectExec(`INSERT INTO reset_tokens .* WHERE reset_tokens.locked_until IS NULL OR reset_tokens.locked_until <= \$4`).
+					WithArgs("john@example.com", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectedCode: http.StatusTooManyRequests,
+		},
 		{
 			name: "User Does Not Exist",
 			requestBody: map[string]string{
@@ -307,6 +436,7 @@ func TestForgotPasswordHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup mock expectations
 			tt.mockSetup()
+			sent.Reset()
 
 			// Convert requestBody to JSON
 			reqBody, _ := json.Marshal(tt.requestBody)
@@ -335,11 +465,24 @@ func TestForgotPasswordHandler(t *testing.T) {
 				if rr.Body.String() != tt.expectedMsg {
 					t.Errorf("Expected message '%s', got '%s'", tt.expectedMsg, rr.Body.String())
 				}
+				if msgs := sent.Messages(); len(msgs) != 1 || msgs[0].To[0] != "john@example.com" || !resetCode.MatchString(msgs[0].Text) {
+					t.Errorf("Expected a six digit code to be emailed, got %+v", msgs)
+				}
+			} else if msgs := sent.Messages(); len(msgs) != 0 {
+				t.Errorf("Expected no email, but got %+v", msgs)
 			}
 		})
 	}
 }
 
+// bcryptHash matches a bcrypt hash argument, so tests can check that codes are never stored in plaintext
+type bcryptHash struct{}
+
+func (bcryptHash) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && strings.HasPrefix(s, "$2")
+}
+
 func TestVerifyCodeHandler(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -351,6 +494,10 @@ func TestVerifyCodeHandler(t *testing.T) {
 	SetDB(db)
 	defer SetDB(originalDb)
 
+	hashedCode, _ := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.MinCost)
+	claimAttempt := "UPDATE reset_tokens SET failed_attempts = failed_attempts \\+ 1"
+	columns := []string{"token", "expires_at", "failed_attempts"}
+
 	tests := []struct {
 		name         string
 		requestBody  map[string]string
@@ -362,13 +509,18 @@ func TestVerifyCodeHandler(t *testing.T) {
 			name: "Successful Verification",
 			requestBody: map[string]string{
 				"email": "john@example.com",
-				"token": "valid_token",
+				"token": "123456",
 			},
 			mockSetup: func() {
-				mock.ExpectQuery("SELECT token, expires_at FROM reset_tokens WHERE email=\\$1").
-					WithArgs("john@example.com").
-					WillReturnRows(sqlmock.NewRows([]string{"token", "expires_at"}).
-						AddRow("valid_token", time.Now().UTC().Add(10*time.Minute)))
+				mock.ExpectQuery(claimAttempt).
+					WithArgs("john@example.com", sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(string(hashedCode), time.Now().UTC().Add(10*time.Minute), 1))
+
+				// The code is cleared and a hashed grant is stored in its place
+				mock.ExpectExec("UPDATE reset_tokens SET token=NULL, failed_attempts=0, locked_until=NULL, grant_hash=\\$2, grant_expires_at=\\$3").
+					WithArgs("john@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
 			},
 			expectedCode: http.StatusOK,
 			expectedMsg:  "Verification successful",
@@ -384,10 +536,13 @@ func TestVerifyCodeHandler(t *testing.T) {
 			name: "No Reset Request Found",
 			requestBody: map[string]string{
 				"email": "nonexistent@example.com",
-				"token": "some_token",
+				"token": "123456",
 			},
 			mockSetup: func() {
-				mock.ExpectQuery("SELECT token, expires_at FROM reset_tokens WHERE email=\\$1").
+				mock.ExpectQuery(claimAttempt).
+					WithArgs("nonexistent@example.com", sqlmock.AnyArg()).
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery("SELECT locked_until FROM reset_tokens WHERE email=\\$1").
 					WithArgs("nonexistent@example.com").
 					WillReturnError(sql.ErrNoRows)
 			},
@@ -395,16 +550,34 @@ func TestVerifyCodeHandler(t *testing.T) {
 			expectedMsg:  "No reset request found for this email",
 		},
 		{
-			name: "Expired Token",
+			name: "Code Already Used",
 			requestBody: map[string]string{
 				"email": "john@example.com",
-				"token": "expired_token",
+				"token": "123456",
 			},
 			mockSetup: func() {
-				mock.ExpectQuery("SELECT token, expires_at FROM reset_tokens WHERE email=\\$1").
+				// A consumed code is NULL, so no row qualifies for another attempt
+				mock.ExpectQuery(claimAttempt).
+					WithArgs("john@example.com", sqlmock.AnyArg()).
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery("SELECT locked_until FROM reset_tokens WHERE email=\\$1").
 					WithArgs("john@example.com").
-					WillReturnRows(sqlmock.NewRows([]string{"token", "expires_at"}).
-						AddRow("expired_token", time.Now().UTC().Add(-10*time.Minute)))
+					WillReturnRows(sqlmock.NewRows([]string{"locked_until"}).AddRow(nil))
+			},
+			expectedCode: http.StatusNotFound,
+			expectedMsg:  "No reset request found for this email",
+		},
+		{
+			name: "Expired Token",
+			requestBody: map[string]string{
+				"email": "john@example.com",
+				"token": "123456",
+			},
+			mockSetup: func() {
+				mock.ExpectQuery(claimAttempt).
+					WithArgs("john@example.com", sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(string(hashedCode), time.Now().UTC().Add(-10*time.Minute), 1))
 			},
 			expectedCode: http.StatusUnauthorized,
 			expectedMsg:  "Token has expired",
@@ -413,17 +586,54 @@ func TestVerifyCodeHandler(t *testing.T) {
 			name: "Invalid Token",
 			requestBody: map[string]string{
 				"email": "john@example.com",
-				"token": "wrong_token",
+				"token": "654321",
 			},
 			mockSetup: func() {
-				mock.ExpectQuery("SELECT token, expires_at FROM reset_tokens WHERE email=\\$1").
-					WithArgs("john@example.com").
-					WillReturnRows(sqlmock.NewRows([]string{"token", "expires_at"}).
-						AddRow("valid_token", time.Now().UTC().Add(10*time.Minute)))
+				mock.ExpectQuery(claimAttempt).
+					WithArgs("john@example.com", sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(string(hashedCode), time.Now().UTC().Add(10*time.Minute), 2))
 			},
 			expectedCode: http.StatusUnauthorized,
 			expectedMsg:  "Invalid verification code",
 		},
+		{
+			name: "Last Allowed Guess Fails",
+			requestBody: map[string]string{
+				"email": "john@example.com",
+				"token": "654321",
+			},
+			mockSetup: func() {
+				mock.ExpectQuery(claimAttempt).
+					WithArgs("john@example.com", sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(string(hashedCode), time.Now().UTC().Add(10*time.Minute), maxResetAttempts))
+
+				// The code is burned and the email is locked
+				mock.ExpectExec("UPDATE reset_tokens SET token=NULL, failed_attempts=0, locked_until=\\$2 WHERE email=\\$1").
+					WithArgs("john@example.com", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCode: http.StatusTooManyRequests,
+			expectedMsg:  "Too many failed attempts. Please try again later.",
+		},
+		{
+			name: "Guess While Locked Out",
+			requestBody: map[string]string{
+				"email": "john@example.com",
+				"token": "123456",
+			},
+			mockSetup: func() {
+				mock.ExpectQuery(claimAttempt).
+					WithArgs("john@example.com", sqlmock.AnyArg()).
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery("SELECT locked_until FROM reset_tokens WHERE email=\\$1").
+					WithArgs("john@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"locked_until"}).AddRow(time.Now().UTC().Add(10 * time.Minute)))
+			},
+			expectedCode: http.StatusTooManyRequests,
+			expectedMsg:  "Too many failed attempts. Please try again later.",
+		},
 	}
 
 	for _, tt := range tests {
@@ -445,16 +655,24 @@ func TestVerifyCodeHandler(t *testing.T) {
 				t.Errorf("Expected status %d, got %d", tt.expectedCode, rr.Code)
 			}
 
-			var response map[string]interface{}
-			json.Unmarshal(rr.Body.Bytes(), &response)
-
-			if message, exists := response["message"]; exists {
-				if message != tt.expectedMsg {
-					t.Errorf("Expected message '%s', got '%s'", tt.expectedMsg, message)
+			if rr.Code == http.StatusOK {
+				var response map[string]interface{}
+				json.Unmarshal(rr.Body.Bytes(), &response)
+				if response["message"] != tt.expectedMsg {
+					t.Errorf("Expected message '%s', got '%v'", tt.expectedMsg, response["message"])
 				}
+				if grant, _ := response["reset_grant"].(string); grant == "" {
+					t.Errorf("Expected a reset grant in the response")
+				}
+			} else if strings.TrimSpace(rr.Body.String()) != tt.expectedMsg {
+				t.Errorf("Expected message '%s', got '%s'", tt.expectedMsg, rr.Body.String())
 			}
 		})
 	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
 }
 
 func TestResetPasswordHandler(t *testing.T) {
@@ -463,6 +681,8 @@ func TestResetPasswordHandler(t *testing.T) {
 	defer mockDB.Close()
 	db.DB = mockDB // Replace actual DB with mock
 
+	consumeGrant := "DELETE FROM reset_tokens WHERE email=\\$1 AND grant_hash=\\$2 AND grant_expires_at > \\$3 RETURNING id"
+
 	tests := []struct {
 		name           string
 		requestBody    map[string]string
@@ -474,16 +694,28 @@ func TestResetPasswordHandler(t *testing.T) {
 			name: "Successful Password Reset",
 			requestBody: map[string]string{
 				"email":        "test@example.com",
+				"reset_grant":  "grant",
 				"new_password": "newpassword123",
 			},
 			prepareMock: func() {
-				mock.ExpectQuery("SELECT email FROM users WHERE email=\\$1").
-					WithArgs("test@example.com").
-					WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("test@example.com"))
+				mock.ExpectQuery(consumeGrant).
+					WithArgs("test@example.com", hashToken("grant"), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
 
-				mock.ExpectExec("UPDATE users SET password=\\$1 WHERE email=\\$2").
+				mock.ExpectQuery("UPDATE users SET password=\\$1 WHERE email=\\$2 RETURNING id").
 					WithArgs(sqlmock.AnyArg(), "test@example.com").
-					WillReturnResult(sqlmock.NewResult(1, 1))
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+				// Existing sessions are logged out
+				mock.ExpectExec("UPDATE refresh_tokens SET revoked_at").
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE users SET sessions_revoked_at").
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE api_keys SET revoked_at").
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "Password reset successfully",
@@ -496,28 +728,71 @@ func TestResetPasswordHandler(t *testing.T) {
 			expectedBody:   "Email and new password are required",
 		},
 		{
-			name: "User Not Found",
+			name: "Reset Without Verifying A Code",
 			requestBody: map[string]string{
-				"email":        "nonexistent@example.com",
+				"email":        "test@example.com",
+				"new_password": "newpassword123",
+			},
+			prepareMock:    func() {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Reset grant is required",
+		},
+		{
+			name: "Forged Grant",
+			requestBody: map[string]string{
+				"email":        "test@example.com",
+				"reset_grant":  "guessed",
 				"new_password": "newpassword123",
 			},
 			prepareMock: func() {
-				mock.ExpectQuery("SELECT email FROM users WHERE email=\\$1").
-					WithArgs("nonexistent@example.com").
+				mock.ExpectQuery(consumeGrant).
+					WithArgs("test@example.com", hashToken("guessed"), sqlmock.AnyArg()).
 					WillReturnError(sql.ErrNoRows)
 			},
-			expectedStatus: http.StatusNotFound,
-			expectedBody:   "User not found",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Invalid or expired reset grant",
 		},
 		{
-			name: "Database Error on User Lookup",
+			name: "Grant Already Used Or Expired",
 			requestBody: map[string]string{
 				"email":        "test@example.com",
+				"reset_grant":  "grant",
+				"new_password": "anotherpassword",
+			},
+			prepareMock: func() {
+				// The first reset deleted the row, so the same grant matches nothing
+				mock.ExpectQuery(consumeGrant).
+					WithArgs("test@example.com", hashToken("grant"), sqlmock.AnyArg()).
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Invalid or expired reset grant",
+		},
+		{
+			name: "Grant For Another Email",
+			requestBody: map[string]string{
+				"email":        "victim@example.com",
+				"reset_grant":  "grant",
 				"new_password": "newpassword123",
 			},
 			prepareMock: func() {
-				mock.ExpectQuery("SELECT email FROM users WHERE email=\\$1").
-					WithArgs("test@example.com").
+				mock.ExpectQuery(consumeGrant).
+					WithArgs("victim@example.com", hashToken("grant"), sqlmock.AnyArg()).
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "Invalid or expired reset grant",
+		},
+		{
+			name: "Database Error on Grant Lookup",
+			requestBody: map[string]string{
+				"email":        "test@example.com",
+				"reset_grant":  "grant",
+				"new_password": "newpassword123",
+			},
+			prepareMock: func() {
+				mock.ExpectQuery(consumeGrant).
+					WithArgs("test@example.com", hashToken("grant"), sqlmock.AnyArg()).
 					WillReturnError(errors.New("DB error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
@@ -527,14 +802,15 @@ func TestResetPasswordHandler(t *testing.T) {
 			name: "Failed to Update Password",
 			requestBody: map[string]string{
 				"email":        "test@example.com",
+				"reset_grant":  "grant",
 				"new_password": "newpassword123",
 			},
 			prepareMock: func() {
-				mock.ExpectQuery("SELECT email FROM users WHERE email=\\$1").
-					WithArgs("test@example.com").
-					WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("test@example.com"))
+				mock.ExpectQuery(consumeGrant).
+					WithArgs("test@example.com", hashToken("grant"), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
 
-				mock.ExpectExec("UPDATE users SET password=\\$1 WHERE email=\\$2").
+				mock.ExpectQuery("UPDATE users SET password=\\$1 WHERE email=\\$2 RETURNING id").
 					WithArgs(sqlmock.AnyArg(), "test@example.com").
 					WillReturnError(errors.New("Update failed"))
 			},
@@ -566,4 +842,62 @@ func TestResetPasswordHandler(t *testing.T) {
 			}
 		})
 	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	mockDB, mock, _ := sqlmock.New()
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	tests := []struct {
+		name           string
+		userID         int
+		prepareMock    func()
+		expectedStatus int
+	}{
+		{
+			name:   "Authenticated User",
+			userID: 1,
+			prepareMock: func() {
+				mock.ExpectQuery("SELECT name, email, email_status, created_at FROM users WHERE id = \\$1").
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"name", "email", "email_status", "created_at"}).
+						AddRow("John Doe", "john@example.com", "verified", "2025-01-01T00:00:00Z"))
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Unauthenticated Request",
+			prepareMock:    func() {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.prepareMock()
+
+			// The email in the body must be ignored in favour of the token's user
+			body, _ := json.Marshal(map[string]string{"email": "someone-else@example.com"})
+			req := httptest.NewRequest("POST", "/get-user", bytes.NewBuffer(body))
+			if tt.userID != 0 {
+				req = withUserID(req, tt.userID)
+			}
+
+			rec := httptest.NewRecorder()
+			GetUser(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
 }
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/middleware"
+	"JobScoop/internal/services"
+	"database/sql"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	verificationTokenTTL = 24 * time.Hour
+	verificationCooldown = 2 * time.Minute
+)
+
+// apiBaseURL is the public address of this API, used to build links in emails
+func apiBaseURL() string {
+	if base := os.Getenv("API_BASE_URL"); base != "" {
+		return base
+	}
+	return "http://localhost:8080"
+}
+
+// frontendURL is the public address of the web app, where browser flows such as a provider
+// sign in end up
+func frontendURL() string {
+	if base := os.Getenv("FRONTEND_URL"); base != "" {
+		return base
+	}
+	return "http://localhost:3000"
+}
+
+// sendVerificationEmail mails a signed, expiring link that confirms the address belongs to the user
+func sendVerificationEmail(userID int, email string) error {
+	token, err := services.IssueEmailToken(userID, email, services.EmailVerificationPurpose, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := apiBaseURL() + "/verify-email?token=" + url.QueryEscape(token)
+	return sendEmail(email, "verify_email", map[string]interface{}{"Link": link})
+}
+
+// verifyEmailPage asks to confirm the address, so that mail scanners and link prefetchers opening
+// the link do not verify it
+var verifyEmailPage = template.Must(template.New("verify_email").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Verify your JobScoop email</title></head>
+<body>
+<p>Confirm that {{.Email}} is your email address?</p>
+<form method="post" action="{{.Action}}"><button type="submit">Verify</button></form>
+</body></html>
+`))
+
+// VerifyEmailHandler confirms an address on a POST: the confirmation form or a JSON body with the
+// token. A GET of the emailed link only shows the confirmation form.
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" && r.Method == http.MethodPost {
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"message": "Invalid request payload"}`, http.StatusBadRequest)
+			return
+		}
+		token = req.Token
+	}
+	if token == "" {
+		http.Error(w, `{"message": "Verification token is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, email, err := services.ParseEmailToken(token, services.EmailVerificationPurpose)
+	if err != nil {
+		http.Error(w, `{"message": "Invalid or expired verification link"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		verifyEmailPage.Execute(w, map[string]string{
+			"Email":  email,
+			"Action": apiBaseURL() + "/verify-email?token=" + url.QueryEscape(token),
+		})
+		return
+	}
+
+	// The token only counts for the address it was sent to
+	res, err := db.DB.Exec(
+		`UPDATE users SET email_status = 'verified' WHERE id = $1 AND email = $2`,
+		userID, email,
+	)
+	if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+	if count, err := res.RowsAffected(); err != nil || count == 0 {
+		http.Error(w, `{"message": "Invalid or expired verification link"}`, http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Email verified successfully",
+		"status":  "success",
+	})
+}
+
+// ResendVerificationHandler sends a new verification link to the authenticated user, at most once per cooldown.
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// Claim the send slot atomically so concurrent requests cannot bypass the cooldown
+	now := time.Now().UTC()
+	var email string
+	err := db.DB.QueryRow(`
+		UPDATE users SET verification_sent_at = $1
+		WHERE id = $2 AND email_status = 'pending'
+		AND (verification_sent_at IS NULL OR verification_sent_at <= $3)
+		RETURNING email`,
+		now, userID, now.Add(-verificationCooldown),
+	).Scan(&email)
+	if err == sql.ErrNoRows {
+		var status string
+		if err := db.DB.QueryRow(`SELECT email_status FROM users WHERE id = $1`, userID).Scan(&status); err != nil {
+			http.Error(w, `{"message": "User not found"}`, http.StatusNotFound)
+			return
+		}
+		if status != "pending" {
+			http.Error(w, `{"message": "Email is already verified"}`, http.StatusConflict)
+			return
+		}
+		w.Header().Set("Retry-After", "120")
+		http.Error(w, `{"message": "Please wait before requesting another verification email"}`, http.StatusTooManyRequests)
+		return
+	} else if err != nil {
+		http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if err := sendVerificationEmail(userID, email); err != nil {
+		http.Error(w, `{"message": "Failed to send email"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Verification email sent",
+		"status":  "success",
+	})
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/services"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestVerifyEmailHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	valid, _ := services.IssueEmailToken(1, "john@example.com", services.EmailVerificationPurpose, time.Hour)
+	expired, _ := services.IssueEmailToken(1, "john@example.com", services.EmailVerificationPurpose, -time.Minute)
+	wrongPurpose, _ := services.IssueEmailToken(1, "john@example.com", "something-else", time.Hour)
+	accessToken, _ := services.IssueAccessToken(1, 0)
+
+	tests := []struct {
+		name         string
+		method       string
+		form         bool
+		token        string
+		mockSetup    func()
+		expectedCode int
+	}{
+		{
+			name:   "Link Only Shows A Form",
+			method: http.MethodGet,
+			token:  valid,
+			// Mail scanners and prefetchers open the link, nothing changes until the form is sent
+			mockSetup:    func() {},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "Confirmation Form Posted",
+			method: http.MethodPost,
+			form:   true,
+			token:  valid,
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET email_status = 'verified' WHERE id = \\$1 AND email = \\$2").
+					WithArgs(1, "john@example.com").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "Valid Code Via POST",
+			method: http.MethodPost,
+			token:  valid,
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET email_status = 'verified'").
+					WithArgs(1, "john@example.com").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:   "Address Changed Since Link Was Sent",
+			method: http.MethodPost,
+			token:  valid,
+			mockSetup: func() {
+				mock.ExpectExec("UPDATE users SET email_status = 'verified'").
+					WithArgs(1, "john@example.com").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{name: "Expired Link", method: http.MethodGet, token: expired, mockSetup: func() {}, expectedCode: http.StatusUnauthorized},
+		{name: "Token For Another Purpose", method: http.MethodGet, token: wrongPurpose, mockSetup: func() {}, expectedCode: http.StatusUnauthorized},
+		{name: "Access Token Is Not A Link", method: http.MethodGet, token: accessToken, mockSetup: func() {}, expectedCode: http.StatusUnauthorized},
+		{name: "Missing Token", method: http.MethodGet, mockSetup: func() {}, expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			var req *http.Request
+			if tt.form {
+				req = httptest.NewRequest(http.MethodPost, "/verify-email?token="+url.QueryEscape(tt.token), nil)
+			} else if tt.method == http.MethodPost {
+				body, _ := json.Marshal(map[string]string{"token": tt.token})
+				req = httptest.NewRequest(http.MethodPost, "/verify-email", bytes.NewBuffer(body))
+			} else {
+				req = httptest.NewRequest(http.MethodGet, "/verify-email?token="+url.QueryEscape(tt.token), nil)
+			}
+			rr := httptest.NewRecorder()
+			VerifyEmailHandler(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+			if tt.method == http.MethodGet && rr.Code == http.StatusOK && !strings.Contains(rr.Body.String(), `<form method="post" action="`) {
+				t.Errorf("Expected a confirmation form, got %s", rr.Body.String())
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestResendVerificationHandler(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	sent := useMemoryMailer(t)
+
+	tests := []struct {
+		name         string
+		mockSetup    func()
+		expectedCode int
+		expectedSent int
+	}{
+		{
+			name: "Pending User Outside Cooldown",
+			mockSetup: func() {
+				mock.ExpectQuery("UPDATE users SET verification_sent_at = \\$1").
+					WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("john@example.com"))
+			},
+			expectedCode: http.StatusOK,
+			expectedSent: 1,
+		},
+		{
+			name: "Within Cooldown",
+			mockSetup: func() {
+				mock.ExpectQuery("UPDATE users SET verification_sent_at = \\$1").
+					WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"email"}))
+				mock.ExpectQuery("SELECT email_status FROM users WHERE id = \\$1").
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"email_status"}).AddRow("pending"))
+			},
+			expectedCode: http.StatusTooManyRequests,
+			expectedSent: 1,
+		},
+		{
+			name: "Already Verified",
+			mockSetup: func() {
+				mock.ExpectQuery("UPDATE users SET verification_sent_at = \\$1").
+					WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"email"}))
+				mock.ExpectQuery("SELECT email_status FROM users WHERE id = \\$1").
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"email_status"}).AddRow("verified"))
+			},
+			expectedCode: http.StatusConflict,
+			expectedSent: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockSetup()
+
+			req := withUserID(httptest.NewRequest(http.MethodPost, "/resend-verification", nil), 1)
+			rr := httptest.NewRecorder()
+			ResendVerificationHandler(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, rr.Code)
+			}
+			if len(sent.Messages()) != tt.expectedSent {
+				t.Errorf("Expected %d emails sent, got %d", tt.expectedSent, len(sent.Messages()))
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
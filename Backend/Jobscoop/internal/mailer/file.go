@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// File writes every message to a directory instead of sending it, for local development.
+// In maildir mode the directory is a Maildir (tmp, new, cur) that mail clients can open;
+// otherwise each message is a .eml file.
+type File struct {
+	dir     string
+	from    string
+	maildir bool
+}
+
+// NewFile creates dir, and the Maildir subdirectories in maildir mode, and returns a File mailer.
+func NewFile(dir, from string, maildir bool) (*File, error) {
+	if from == "" {
+		from = "JobScoop <noreply@localhost>"
+	}
+	dirs := []string{dir}
+	if maildir {
+		dirs = []string{filepath.Join(dir, "tmp"), filepath.Join(dir, "new"), filepath.Join(dir, "cur")}
+	}
+	for _, d := range dirs {
+		if err := os.MkdirAll(d, 0o700); err != nil {
+			return nil, err
+		}
+	}
+	return &File{dir: dir, from: from, maildir: maildir}, nil
+}
+
+// Send implements Mailer.
+func (f *File) Send(msg Message) error {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + "." + hex.EncodeToString(b) + "." + host
+	data := msg.Bytes(f.from)
+
+	if !f.maildir {
+		return os.WriteFile(filepath.Join(f.dir, name+".eml"), data, 0o600)
+	}
+	// Maildir delivery: readers only look in new, so the file appears there complete
+	tmp := filepath.Join(f.dir, "tmp", name)
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(f.dir, "new", name))
+}
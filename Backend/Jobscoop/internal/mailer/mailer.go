@@ -0,0 +1,181 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is one email to send. The sender is set by the Mailer that delivers it.
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	// HTML, when set, is sent alongside Text as multipart/alternative
+	HTML string
+	// ListUnsubscribe is a URL for the List-Unsubscribe header; it must accept a one-click POST
+	ListUnsubscribe string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var (
+	currentMu sync.RWMutex
+	current   Mailer
+)
+
+// Set replaces the mailer used by Send.
+func Set(m Mailer) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = m
+}
+
+// Send delivers msg through the configured mailer.
+func Send(msg Message) error {
+	currentMu.RLock()
+	m := current
+	currentMu.RUnlock()
+	if m == nil {
+		return errors.New("no mailer is configured")
+	}
+	if err := validate(msg); err != nil {
+		return err
+	}
+	return m.Send(msg)
+}
+
+func validate(msg Message) error {
+	if len(msg.To) == 0 {
+		return errors.New("message has no recipient")
+	}
+	// Addresses end up in headers and SMTP commands verbatim
+	for _, to := range msg.To {
+		if to == "" || strings.ContainsAny(to, "\r\n") {
+			return fmt.Errorf("invalid recipient %q", to)
+		}
+	}
+	return nil
+}
+
+// LoadFromEnv configures the mailer chosen by MAIL_BACKEND:
+//
+//	smtp     SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASS and SMTP_TLS (starttls, implicit or none) (default)
+//	file     one .eml file per message in MAIL_DIR
+//	maildir  a Maildir in MAIL_DIR, readable by most mail clients
+//	memory   messages are only kept in memory
+//
+// MAIL_FROM is the sender address, SMTP_USER when unset. MAIL_TEMPLATE_DIR replaces the embedded
+// email templates and MAIL_BRAND_NAME, MAIL_BRAND_LOGO_URL, MAIL_BRAND_COLOR, MAIL_SUPPORT_EMAIL and
+// MAIL_WEBSITE_URL customise their branding.
+func LoadFromEnv() error {
+	loadTemplatesFromEnv()
+
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = os.Getenv("SMTP_USER")
+	}
+
+	switch backend := os.Getenv("MAIL_BACKEND"); backend {
+	case "", "smtp":
+		m, err := NewSMTP(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASS"),
+			TLS:      os.Getenv("SMTP_TLS"),
+			From:     from,
+		})
+		if err != nil {
+			return err
+		}
+		Set(m)
+	case "file", "maildir":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			return fmt.Errorf("MAIL_DIR is required for the %s mail backend", backend)
+		}
+		m, err := NewFile(dir, from, backend == "maildir")
+		if err != nil {
+			return err
+		}
+		Set(m)
+	case "memory":
+		Set(NewMemory())
+	default:
+		return fmt.Errorf("unknown MAIL_BACKEND %q", backend)
+	}
+	return nil
+}
+
+// Bytes formats msg as an RFC 5322 message from the given sender, with CRLF line endings.
+func (msg Message) Bytes(from string) []byte {
+	var b bytes.Buffer
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + strings.Join(msg.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("Message-ID: " + messageID(from) + "\r\n")
+	if msg.ListUnsubscribe != "" && !strings.ContainsAny(msg.ListUnsubscribe, "\r\n<>") {
+		b.WriteString("List-Unsubscribe: <" + msg.ListUnsubscribe + ">\r\n")
+		b.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		writeQuotedPrintable(&b, msg.Text)
+		return b.Bytes()
+	}
+
+	mw := multipart.NewWriter(&b)
+	b.WriteString("Content-Type: multipart/alternative; boundary=" + mw.Boundary() + "\r\n\r\n")
+	// Clients show the last part they understand, so the HTML part goes last
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		writeQuotedPrintable(w, part.body)
+	}
+	mw.Close()
+	return b.Bytes()
+}
+
+func writeQuotedPrintable(w io.Writer, s string) {
+	qp := quotedprintable.NewWriter(w)
+	qp.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")))
+	qp.Close()
+}
+
+// messageID returns a unique Message-ID in the sender's domain
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if i := strings.LastIndex(addr.Address, "@"); i >= 0 {
+			domain = addr.Address[i+1:]
+		}
+	}
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return "<" + hex.EncodeToString(buf) + "@" + domain + ">"
+}
@@ -0,0 +1,192 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMessageBytes(t *testing.T) {
+	msg := Message{To: []string{"john@example.com"}, Subject: "Réinitialisation", Text: "Code: 123456\nBye"}
+	raw := string(msg.Bytes("JobScoop <noreply@example.com>"))
+
+	for _, want := range []string{
+		"From: JobScoop <noreply@example.com>\r\n",
+		"To: john@example.com\r\n",
+		"Subject: =?utf-8?q?R=C3=A9initialisation?=\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\nCode: 123456\r\nBye",
+	} {
+		if !strings.Contains(raw, want) {
+			t.Errorf("Expected %q in message:\n%s", want, raw)
+		}
+	}
+	if strings.Contains(raw, "List-Unsubscribe") {
+		t.Errorf("Expected no List-Unsubscribe header without a URL:\n%s", raw)
+	}
+}
+
+func TestMessageBytesMultipart(t *testing.T) {
+	msg := Message{
+		To:              []string{"john@example.com"},
+		Subject:         "Digest",
+		Text:            "3 new jobs",
+		HTML:            "<p>3 new jobs</p>",
+		ListUnsubscribe: "https://api.example.com/unsubscribe?token=abc",
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(msg.Bytes("JobScoop <noreply@example.com>")))
+	if err != nil {
+		t.Fatalf("Generated message does not parse: %v", err)
+	}
+
+	if _, err := parsed.Header.Date(); err != nil {
+		t.Errorf("Expected a valid Date header: %v", err)
+	}
+	if id := parsed.Header.Get("Message-ID"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Unexpected Message-ID %q", id)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe"); got != "<https://api.example.com/unsubscribe?token=abc>" {
+		t.Errorf("Unexpected List-Unsubscribe %q", got)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("Unexpected List-Unsubscribe-Post %q", got)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Expected multipart/alternative, got %q (%v)", mediaType, err)
+	}
+	r := multipart.NewReader(parsed.Body, params["boundary"])
+	var types, bodies []string
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Reading part: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		types = append(types, part.Header.Get("Content-Type"))
+		bodies = append(bodies, string(body))
+	}
+	if len(types) != 2 || !strings.HasPrefix(types[0], "text/plain") || !strings.HasPrefix(types[1], "text/html") {
+		t.Fatalf("Expected a text part then an HTML part, got %v", types)
+	}
+	if bodies[0] != "3 new jobs" || bodies[1] != "<p>3 new jobs</p>" {
+		t.Errorf("Unexpected part bodies %q", bodies)
+	}
+}
+
+func TestSendUsesConfiguredMailer(t *testing.T) {
+	defer Set(nil)
+
+	if err := Send(Message{To: []string{"john@example.com"}}); err == nil {
+		t.Error("Expected an error without a mailer")
+	}
+
+	m := NewMemory()
+	Set(m)
+	if err := Send(Message{To: []string{"john@example.com\r\nBcc: victim@example.com"}}); err == nil {
+		t.Error("Expected a recipient with a line break to be rejected")
+	}
+	if err := Send(Message{}); err == nil {
+		t.Error("Expected a message without recipient to be rejected")
+	}
+	if err := Send(Message{To: []string{"john@example.com"}, Subject: "Hi"}); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if msgs := m.Messages(); len(msgs) != 1 || msgs[0].Subject != "Hi" {
+		t.Errorf("Unexpected recorded messages: %+v", msgs)
+	}
+
+	m.Err = errors.New("relay down")
+	if err := Send(Message{To: []string{"john@example.com"}}); err == nil || len(m.Messages()) != 1 {
+		t.Error("Expected the configured error and nothing recorded")
+	}
+	m.Reset()
+	if len(m.Messages()) != 0 {
+		t.Error("Expected Reset to forget the messages")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewFile(dir, "", false)
+	if err != nil {
+		t.Fatalf("NewFile returned error: %v", err)
+	}
+	if err := m.Send(Message{To: []string{"john@example.com"}, Subject: "Hi", Text: "Hello"}); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one .eml file, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "To: john@example.com") {
+		t.Errorf("Unexpected file content:\n%s", data)
+	}
+}
+
+func TestMaildirMailer(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewFile(dir, "noreply@example.com", true)
+	if err != nil {
+		t.Fatalf("NewFile returned error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := m.Send(Message{To: []string{"john@example.com"}, Subject: "Hi", Text: "Hello"}); err != nil {
+			t.Fatalf("Send returned error: %v", err)
+		}
+	}
+
+	delivered, _ := os.ReadDir(filepath.Join(dir, "new"))
+	leftover, _ := os.ReadDir(filepath.Join(dir, "tmp"))
+	if len(delivered) != 2 || len(leftover) != 0 {
+		t.Errorf("Expected two messages in new and none in tmp, got %d and %d", len(delivered), len(leftover))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cur")); err != nil {
+		t.Errorf("Expected the cur directory to exist: %v", err)
+	}
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	defer Set(nil)
+
+	t.Setenv("MAIL_BACKEND", "maildir")
+	t.Setenv("MAIL_DIR", t.TempDir())
+	t.Setenv("MAIL_FROM", "noreply@example.com")
+	if err := LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv returned error: %v", err)
+	}
+	if _, ok := current.(*File); !ok {
+		t.Errorf("Expected a file mailer, got %T", current)
+	}
+
+	t.Setenv("MAIL_BACKEND", "memory")
+	if err := LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv returned error: %v", err)
+	}
+	if _, ok := current.(*Memory); !ok {
+		t.Errorf("Expected a memory mailer, got %T", current)
+	}
+
+	t.Setenv("MAIL_BACKEND", "smtp")
+	t.Setenv("SMTP_HOST", "")
+	if err := LoadFromEnv(); err == nil {
+		t.Error("Expected an error without SMTP_HOST")
+	}
+
+	t.Setenv("MAIL_BACKEND", "pigeon")
+	if err := LoadFromEnv(); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+}
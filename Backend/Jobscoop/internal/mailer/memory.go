@@ -0,0 +1,42 @@
+package mailer
+
+import "sync"
+
+// Memory records messages instead of sending them. Tests install one with Set and inspect it.
+type Memory struct {
+	mu   sync.Mutex
+	sent []Message
+
+	// Err, when set, is returned by Send and nothing is recorded
+	Err error
+}
+
+// NewMemory returns an empty in-memory mailer.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Send implements Mailer.
+func (m *Memory) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Messages returns the recorded messages in the order they were sent.
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.sent...)
+}
+
+// Reset forgets the recorded messages.
+func (m *Memory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = nil
+}
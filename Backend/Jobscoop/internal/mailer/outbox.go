@@ -0,0 +1,210 @@
+package mailer
+
+import (
+	"JobScoop/internal/models"
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Queue holds messages for later delivery.
+type Queue interface {
+	Enqueue(msg Message) error
+}
+
+var (
+	queueMu sync.RWMutex
+	queue   Queue
+)
+
+// SetQueue replaces the queue used by Enqueue.
+func SetQueue(q Queue) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	queue = q
+}
+
+// TxQueue is a Queue that can store a message as part of a database transaction.
+type TxQueue interface {
+	Queue
+	EnqueueTx(tx *sql.Tx, msg Message) error
+}
+
+// Enqueue hands msg to the configured queue, or sends it right away when there is none.
+func Enqueue(msg Message) error {
+	queueMu.RLock()
+	q := queue
+	queueMu.RUnlock()
+	if q == nil {
+		return Send(msg)
+	}
+	if err := validate(msg); err != nil {
+		return err
+	}
+	return q.Enqueue(msg)
+}
+
+// EnqueueTx hands msg to the configured queue within tx, so it is only delivered if tx commits.
+// A queue that cannot take part in tx, or none, gets it as from Enqueue.
+func EnqueueTx(tx *sql.Tx, msg Message) error {
+	queueMu.RLock()
+	q, ok := queue.(TxQueue)
+	queueMu.RUnlock()
+	if !ok {
+		return Enqueue(msg)
+	}
+	if err := validate(msg); err != nil {
+		return err
+	}
+	return q.EnqueueTx(tx, msg)
+}
+
+const (
+	defaultOutboxMaxAttempts = 10
+	defaultOutboxBaseDelay   = 30 * time.Second
+	defaultOutboxMaxDelay    = time.Hour
+	defaultOutboxLease       = 5 * time.Minute
+	defaultOutboxBatchSize   = 50
+	defaultOutboxRetention   = 7 * 24 * time.Hour
+)
+
+// Outbox is a Queue stored in the email_outbox table. Run delivers the queued messages through
+// Send, retrying failures with exponential backoff until MaxAttempts is reached; the message is
+// then dead-lettered and only sent again when an admin requeues it.
+type Outbox struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Lease is how long a claimed message stays with its sender before another may retry it
+	Lease     time.Duration
+	BatchSize int
+	// Retention is how long records of sent messages are kept
+	Retention time.Duration
+}
+
+// NewOutbox returns an Outbox with the default settings, allowing MAIL_OUTBOX_MAX_ATTEMPTS
+// attempts per message when it is set.
+func NewOutbox() *Outbox {
+	o := &Outbox{
+		MaxAttempts: defaultOutboxMaxAttempts,
+		BaseDelay:   defaultOutboxBaseDelay,
+		MaxDelay:    defaultOutboxMaxDelay,
+		Lease:       defaultOutboxLease,
+		BatchSize:   defaultOutboxBatchSize,
+		Retention:   defaultOutboxRetention,
+	}
+	if n, err := strconv.Atoi(os.Getenv("MAIL_OUTBOX_MAX_ATTEMPTS")); err == nil && n > 0 {
+		o.MaxAttempts = n
+	}
+	return o
+}
+
+// Enqueue stores msg for delivery.
+func (o *Outbox) Enqueue(msg Message) error {
+	_, err := models.EnqueueEmail(msg.To, msg.Subject, msg.Text, msg.HTML, msg.ListUnsubscribe)
+	return err
+}
+
+// EnqueueTx stores msg for delivery once tx commits.
+func (o *Outbox) EnqueueTx(tx *sql.Tx, msg Message) error {
+	_, err := models.EnqueueEmailTx(tx, msg.To, msg.Subject, msg.Text, msg.HTML, msg.ListUnsubscribe)
+	return err
+}
+
+// Backoff returns the wait after the given number of failed attempts: BaseDelay, doubling
+// after each further failure, up to MaxDelay.
+func (o *Outbox) Backoff(attempts int) time.Duration {
+	delay := o.BaseDelay
+	for i := 1; i < attempts && delay < o.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > o.MaxDelay {
+		delay = o.MaxDelay
+	}
+	return delay
+}
+
+// DeliverDue sends up to BatchSize due messages and returns how many were claimed. Each message
+// is claimed just before it is sent, so its lease only has to cover its own delivery and not the
+// wait behind the rest of the batch.
+func (o *Outbox) DeliverDue(now time.Time) (int, error) {
+	start := time.Now()
+	claimed := 0
+	for claimed < o.BatchSize {
+		emails, err := models.ClaimDueEmails(now.Add(time.Since(start)), 1, o.Lease)
+		if err != nil {
+			return claimed, err
+		}
+		if len(emails) == 0 {
+			break
+		}
+		claimed++
+		if err := o.deliver(emails[0]); err != nil {
+			return claimed, err
+		}
+	}
+	return claimed, nil
+}
+
+// deliver sends a claimed message and records the outcome
+func (o *Outbox) deliver(e models.OutboxEmail) error {
+	var held bool
+	err := Send(Message{To: e.Recipients, Subject: e.Subject, Text: e.Text, HTML: e.HTML, ListUnsubscribe: e.ListUnsubscribe})
+	if err == nil {
+		held, err = models.MarkEmailSent(e.ID, e.Attempts, time.Now().UTC())
+	} else {
+		var retryAt *time.Time
+		if e.Attempts < o.MaxAttempts {
+			at := time.Now().UTC().Add(o.Backoff(e.Attempts))
+			retryAt = &at
+		} else {
+			log.Printf("Email %d dead-lettered after %d attempts: %v", e.ID, e.Attempts, err)
+		}
+		held, err = models.MarkEmailFailed(e.ID, e.Attempts, err.Error(), retryAt)
+	}
+	if err != nil {
+		return err
+	}
+	if !held {
+		log.Printf("Email %d outlived its lease, another sender has it", e.ID)
+	}
+	return nil
+}
+
+// Run delivers due messages every interval until ctx is cancelled, and drops the records of
+// messages sent longer than Retention ago.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var purgedAt time.Time
+	for {
+		now := time.Now().UTC()
+		// Keep going while full batches come back, there is more waiting
+		for {
+			claimed, err := o.DeliverDue(now)
+			if err != nil {
+				log.Printf("Failed to deliver queued emails: %v", err)
+				break
+			}
+			if claimed < o.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+		if now.Sub(purgedAt) >= time.Hour {
+			if _, err := models.PurgeSentEmails(now.Add(-o.Retention)); err != nil {
+				log.Printf("Failed to purge sent emails: %v", err)
+			}
+			purgedAt = now
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
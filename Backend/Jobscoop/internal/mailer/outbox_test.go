@@ -0,0 +1,131 @@
+package mailer
+
+import (
+	"JobScoop/internal/db"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// failingFor rejects messages to the listed addresses
+type failingFor map[string]bool
+
+func (f failingFor) Send(msg Message) error {
+	if f[msg.To[0]] {
+		return errors.New("550 mailbox unavailable")
+	}
+	return nil
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	o := &Outbox{BaseDelay: 30 * time.Second, MaxDelay: time.Hour}
+	for attempts, want := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		4:  4 * time.Minute,
+		8:  time.Hour,
+		50: time.Hour,
+	} {
+		if got := o.Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+	defer Set(nil)
+	defer SetQueue(nil)
+
+	// Without a queue the message goes straight to the mailer
+	m := NewMemory()
+	Set(m)
+	if err := Enqueue(Message{To: []string{"john@example.com"}, Subject: "Hi"}); err != nil || len(m.Messages()) != 1 {
+		t.Fatalf("Expected a direct send, got %v and %d messages", err, len(m.Messages()))
+	}
+
+	SetQueue(NewOutbox())
+	mock.ExpectQuery("INSERT INTO email_outbox \\(recipients, subject, text_body, html_body, list_unsubscribe\\)").
+		WithArgs(pq.Array([]string{"john@example.com"}), "Hi", "Hello", "<p>Hello</p>", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	if err := Enqueue(Message{To: []string{"john@example.com"}, Subject: "Hi", Text: "Hello", HTML: "<p>Hello</p>"}); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	if len(m.Messages()) != 1 {
+		t.Error("Expected a queued message not to be sent right away")
+	}
+	if err := Enqueue(Message{To: []string{"john@example.com\r\nBcc: victim@example.com"}}); err == nil {
+		t.Error("Expected an invalid recipient to be rejected before queueing")
+	}
+
+	// Within a transaction the message is stored by it
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO email_outbox").
+		WithArgs(pq.Array([]string{"john@example.com"}), "Digest", "", "", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectRollback()
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("Begin returned error: %v", err)
+	}
+	if err := EnqueueTx(tx, Message{To: []string{"john@example.com"}, Subject: "Digest"}); err != nil {
+		t.Fatalf("EnqueueTx returned error: %v", err)
+	}
+	tx.Rollback()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestOutboxDeliverDue(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+	defer Set(nil)
+
+	Set(failingFor{"retry@example.com": true, "dead@example.com": true})
+	o := NewOutbox()
+	o.MaxAttempts = 3
+	now := time.Now().UTC()
+
+	columns := []string{"id", "recipients", "subject", "text_body", "html_body", "list_unsubscribe", "attempts"}
+	claim := func() *sqlmock.ExpectedQuery {
+		return mock.ExpectQuery("UPDATE email_outbox SET status = 'sending'.*LIMIT \\$2\\s+FOR UPDATE SKIP LOCKED").
+			WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg())
+	}
+	// Each message is claimed on its own right before it is sent
+	claim().WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "{ok@example.com}", "Hi", "Hello", "", "", 1))
+	mock.ExpectExec("UPDATE email_outbox\\s+SET status = 'sent'.*WHERE id = \\$2 AND status = 'sending' AND attempts = \\$3").
+		WithArgs(sqlmock.AnyArg(), 1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	claim().WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "{retry@example.com}", "Hi", "Hello", "", "", 2))
+	mock.ExpectExec("UPDATE email_outbox SET status = 'pending', locked_until = NULL, last_error = \\$1, next_attempt_at = \\$2\\s+WHERE id = \\$3 AND status = 'sending' AND attempts = \\$4").
+		WithArgs("550 mailbox unavailable", sqlmock.AnyArg(), 2, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	claim().WillReturnRows(sqlmock.NewRows(columns).AddRow(3, "{dead@example.com}", "Hi", "Hello", "", "", 3))
+	// Another sender took the message over after the lease ran out; its state is left alone
+	mock.ExpectExec("UPDATE email_outbox SET status = 'dead', locked_until = NULL, last_error = \\$1\\s+WHERE id = \\$2 AND status = 'sending' AND attempts = \\$3").
+		WithArgs("550 mailbox unavailable", 3, 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	claim().WillReturnRows(sqlmock.NewRows(columns))
+
+	claimed, err := o.DeliverDue(now)
+	if err != nil || claimed != 3 {
+		t.Errorf("Expected 3 claimed messages, got %d (%v)", claimed, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
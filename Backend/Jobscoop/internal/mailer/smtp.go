@@ -0,0 +1,131 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// TLS modes of the SMTP backend
+const (
+	TLSStartTLS = "starttls" // plain connection upgraded with STARTTLS, usually port 587
+	TLSImplicit = "implicit" // TLS from the first byte, usually port 465
+	TLSNone     = "none"     // no encryption, only for local relays such as MailHog
+)
+
+// SMTPConfig configures the SMTP backend. TLS defaults to implicit on port 465 and STARTTLS otherwise.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	TLS      string
+	// From is the sender, a bare address or one with a display name such as "JobScoop <noreply@example.com>"
+	From string
+
+	// TLSConfig overrides the TLS settings, mainly to trust a test server
+	TLSConfig *tls.Config
+	Timeout   time.Duration
+}
+
+// SMTP delivers messages to an SMTP server, one connection per message.
+type SMTP struct {
+	cfg SMTPConfig
+	// envelope is the bare address of cfg.From, the only form MAIL FROM accepts
+	envelope string
+}
+
+// NewSMTP checks cfg and returns an SMTP mailer.
+func NewSMTP(cfg SMTPConfig) (*SMTP, error) {
+	if cfg.Host == "" || cfg.From == "" {
+		return nil, errors.New("SMTP_HOST and a sender address (MAIL_FROM or SMTP_USER) are required")
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %v", cfg.From, err)
+	}
+	cfg.From = from.String()
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = TLSStartTLS
+		if cfg.Port == "465" {
+			cfg.TLS = TLSImplicit
+		}
+	}
+	if cfg.TLS != TLSStartTLS && cfg.TLS != TLSImplicit && cfg.TLS != TLSNone {
+		return nil, fmt.Errorf("unknown SMTP_TLS mode %q", cfg.TLS)
+	}
+	if cfg.TLSConfig == nil {
+		cfg.TLSConfig = &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12}
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return &SMTP{cfg: cfg, envelope: from.Address}, nil
+}
+
+// Send implements Mailer.
+func (s *SMTP) Send(msg Message) error {
+	addr := net.JoinHostPort(s.cfg.Host, s.cfg.Port)
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+
+	var conn net.Conn
+	var err error
+	if s.cfg.TLS == TLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, s.cfg.TLSConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(s.cfg.Timeout))
+
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.cfg.TLS == TLSStartTLS {
+		// Never fall back to sending credentials and mail in the clear
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("SMTP server does not support STARTTLS")
+		}
+		if err := c.StartTLS(s.cfg.TLSConfig); err != nil {
+			return err
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(s.envelope); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes(s.cfg.From)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
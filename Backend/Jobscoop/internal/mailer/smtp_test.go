@@ -0,0 +1,240 @@
+package mailer
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is a minimal SMTP server that records what a client sent.
+type fakeSMTP struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+	startTLS  bool
+	done      chan struct{}
+
+	// Filled in while serving one connection
+	upgraded bool
+	auth     string
+	from     string
+	rcpt     []string
+	data     string
+}
+
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+// startFakeSMTP serves a single connection, with implicit TLS unless startTLS is set
+func startFakeSMTP(t *testing.T, startTLS bool) (*fakeSMTP, *x509.CertPool) {
+	cert, pool := testCertificate(t)
+	s := &fakeSMTP{
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		startTLS:  startTLS,
+		done:      make(chan struct{}),
+	}
+	var err error
+	if startTLS {
+		s.ln, err = net.Listen("tcp", "127.0.0.1:0")
+	} else {
+		s.ln, err = tls.Listen("tcp", "127.0.0.1:0", s.tlsConfig)
+		s.upgraded = true
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.ln.Close() })
+	go s.serve()
+	return s, pool
+}
+
+func (s *fakeSMTP) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer func() { conn.Close() }()
+
+	r := bufio.NewReader(conn)
+	write := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	write("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		switch verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0]); verb {
+		case "EHLO":
+			if s.startTLS && !s.upgraded {
+				write("250-fake\r\n250 STARTTLS")
+			} else {
+				write("250-fake\r\n250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			write("220 go ahead")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn, r, s.upgraded = tlsConn, bufio.NewReader(tlsConn), true
+			write = func(line string) { conn.Write([]byte(line + "\r\n")) }
+		case "AUTH":
+			s.auth = cmd
+			write("235 ok")
+		case "MAIL":
+			s.from = cmd
+			write("250 ok")
+		case "RCPT":
+			s.rcpt = append(s.rcpt, cmd)
+			write("250 ok")
+		case "DATA":
+			write("354 go ahead")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.data = b.String()
+			write("250 queued")
+		case "QUIT":
+			write("221 bye")
+			return
+		default:
+			write("502 unknown")
+		}
+	}
+}
+
+func sendThroughFake(t *testing.T, mode string, startTLS bool) *fakeSMTP {
+	server, pool := startFakeSMTP(t, startTLS)
+	host, port, _ := net.SplitHostPort(server.ln.Addr().String())
+	m, err := NewSMTP(SMTPConfig{
+		Host:      host,
+		Port:      port,
+		Username:  "jobscoop",
+		Password:  "secret",
+		TLS:       mode,
+		From:      "JobScoop <noreply@example.com>",
+		TLSConfig: &tls.Config{RootCAs: pool, ServerName: host},
+		Timeout:   5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewSMTP returned error: %v", err)
+	}
+	if err := m.Send(Message{To: []string{"john@example.com"}, Subject: "Hi", Text: "Hello"}); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	<-server.done
+	return server
+}
+
+func TestSMTPStartTLS(t *testing.T) {
+	server := sendThroughFake(t, TLSStartTLS, true)
+	if !server.upgraded || server.auth == "" {
+		t.Errorf("Expected the session to be upgraded before authenticating")
+	}
+	if server.from != "MAIL FROM:<noreply@example.com>" || len(server.rcpt) != 1 || !strings.Contains(server.rcpt[0], "john@example.com") {
+		t.Errorf("Unexpected envelope: %q %q", server.from, server.rcpt)
+	}
+	// The display name only goes in the header, the envelope takes the bare address
+	if !strings.Contains(server.data, "From: \"JobScoop\" <noreply@example.com>\r\n") || !strings.Contains(server.data, "Subject: Hi\r\n") {
+		t.Errorf("Unexpected data:\n%s", server.data)
+	}
+}
+
+func TestSMTPImplicitTLS(t *testing.T) {
+	server := sendThroughFake(t, TLSImplicit, false)
+	if server.auth == "" || !strings.Contains(server.data, "Hello") {
+		t.Errorf("Expected an authenticated delivery, got auth %q and data %q", server.auth, server.data)
+	}
+}
+
+func TestSMTPRefusesWithoutStartTLS(t *testing.T) {
+	// The server only speaks TLS after STARTTLS, which a plain session never offers here
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("220 fake ESMTP\r\n"))
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(strings.ToUpper(line), "EHLO") {
+				conn.Write([]byte("250 fake\r\n"))
+			} else {
+				conn.Write([]byte("221 bye\r\n"))
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	m, _ := NewSMTP(SMTPConfig{Host: host, Port: port, From: "noreply@example.com", Timeout: 5 * time.Second})
+	err = m.Send(Message{To: []string{"john@example.com"}, Subject: "Hi", Text: "Hello"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("Expected a STARTTLS error, got %v", err)
+	}
+}
+
+func TestNewSMTPDefaults(t *testing.T) {
+	m, err := NewSMTP(SMTPConfig{Host: "smtp.example.com", Port: "465", From: "noreply@example.com"})
+	if err != nil || m.cfg.TLS != TLSImplicit {
+		t.Errorf("Expected implicit TLS on port 465, got %+v, %v", m, err)
+	}
+	m, err = NewSMTP(SMTPConfig{Host: "smtp.example.com", From: "noreply@example.com"})
+	if err != nil || m.cfg.TLS != TLSStartTLS || m.cfg.Port != "587" {
+		t.Errorf("Expected STARTTLS on port 587, got %+v, %v", m, err)
+	}
+	if _, err := NewSMTP(SMTPConfig{Host: "smtp.example.com", From: "noreply@example.com", TLS: "ssl3"}); err == nil {
+		t.Error("Expected an unknown TLS mode to be rejected")
+	}
+	if _, err := NewSMTP(SMTPConfig{Host: "smtp.example.com", From: "JobScoop noreply"}); err == nil {
+		t.Error("Expected an invalid sender address to be rejected")
+	}
+}
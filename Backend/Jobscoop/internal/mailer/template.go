@@ -0,0 +1,184 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// DefaultLocale is used when a template has no translation for the requested locale
+const DefaultLocale = "en"
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// Branding is available to every template as .Brand
+type Branding struct {
+	ProductName  string
+	LogoURL      string
+	PrimaryColor string
+	SupportEmail string
+	WebsiteURL   string
+}
+
+var (
+	templatesMu sync.RWMutex
+	templateFS  fs.FS = mustSub(embeddedTemplates, "templates")
+	branding          = Branding{ProductName: "JobScoop", PrimaryColor: "#2563eb"}
+)
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// SetTemplates replaces the email templates, for example with a directory holding more locales.
+// It must contain <locale>/<name>.subject.tmpl, .txt.tmpl and .html.tmpl files and a layout.txt.tmpl
+// and layout.html.tmpl per locale; missing files fall back to DefaultLocale.
+func SetTemplates(fsys fs.FS) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	templateFS = fsys
+}
+
+// SetBranding replaces the branding embedded in templated emails.
+func SetBranding(b Branding) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	branding = b
+}
+
+// loadTemplatesFromEnv applies MAIL_TEMPLATE_DIR and the MAIL_BRAND_* settings
+func loadTemplatesFromEnv() {
+	if dir := os.Getenv("MAIL_TEMPLATE_DIR"); dir != "" {
+		SetTemplates(os.DirFS(dir))
+	}
+	b := branding
+	if v := os.Getenv("MAIL_BRAND_NAME"); v != "" {
+		b.ProductName = v
+	}
+	if v := os.Getenv("MAIL_BRAND_LOGO_URL"); v != "" {
+		b.LogoURL = v
+	}
+	if v := os.Getenv("MAIL_BRAND_COLOR"); v != "" {
+		b.PrimaryColor = v
+	}
+	if v := os.Getenv("MAIL_SUPPORT_EMAIL"); v != "" {
+		b.SupportEmail = v
+	}
+	if v := os.Getenv("MAIL_WEBSITE_URL"); v != "" {
+		b.WebsiteURL = v
+	}
+	SetBranding(b)
+}
+
+// localeCandidates lists where to look for a locale such as "pt-BR": pt-br, pt, then the default
+func localeCandidates(locale string) []string {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	var candidates []string
+	if locale != "" {
+		candidates = append(candidates, locale)
+		if i := strings.Index(locale, "-"); i > 0 {
+			candidates = append(candidates, locale[:i])
+		}
+	}
+	return append(candidates, DefaultLocale)
+}
+
+// readLocalized returns the first translation of file found for the locale
+func readLocalized(fsys fs.FS, locale, file string) (string, error) {
+	for _, candidate := range localeCandidates(locale) {
+		b, err := fs.ReadFile(fsys, candidate+"/"+file)
+		if err == nil {
+			return string(b), nil
+		}
+	}
+	return "", fmt.Errorf("email template %s not found", file)
+}
+
+// Render builds a multipart message from the named template in the given locale. The templates
+// see data plus .Brand and .Locale. Recipients are left for the caller to fill in.
+func Render(name, locale string, data map[string]interface{}) (Message, error) {
+	templatesMu.RLock()
+	fsys, brand := templateFS, branding
+	templatesMu.RUnlock()
+
+	vars := map[string]interface{}{}
+	for k, v := range data {
+		vars[k] = v
+	}
+	vars["Brand"] = brand
+	vars["Locale"] = localeCandidates(locale)[0]
+
+	subject, err := renderText(fsys, locale, vars, name+".subject.tmpl")
+	if err != nil {
+		return Message{}, err
+	}
+	text, err := renderText(fsys, locale, vars, "layout.txt.tmpl", name+".txt.tmpl")
+	if err != nil {
+		return Message{}, err
+	}
+	html, err := renderHTML(fsys, locale, vars, "layout.html.tmpl", name+".html.tmpl")
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{Subject: strings.TrimSpace(subject), Text: text, HTML: html}, nil
+}
+
+// renderText executes the first file, with the following ones parsed into the same set
+func renderText(fsys fs.FS, locale string, vars map[string]interface{}, files ...string) (string, error) {
+	var t *texttemplate.Template
+	for _, file := range files {
+		src, err := readLocalized(fsys, locale, file)
+		if err != nil {
+			return "", err
+		}
+		if t == nil {
+			t = texttemplate.New(file)
+		} else {
+			t = t.New(file)
+		}
+		if _, err := t.Parse(src); err != nil {
+			return "", fmt.Errorf("parsing %s: %w", file, err)
+		}
+	}
+	var b bytes.Buffer
+	if err := t.ExecuteTemplate(&b, files[0], vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// renderHTML is renderText with contextual escaping
+func renderHTML(fsys fs.FS, locale string, vars map[string]interface{}, files ...string) (string, error) {
+	var t *htmltemplate.Template
+	for _, file := range files {
+		src, err := readLocalized(fsys, locale, file)
+		if err != nil {
+			return "", err
+		}
+		if t == nil {
+			t = htmltemplate.New(file)
+		} else {
+			t = t.New(file)
+		}
+		if _, err := t.Parse(src); err != nil {
+			return "", fmt.Errorf("parsing %s: %w", file, err)
+		}
+	}
+	var b bytes.Buffer
+	if err := t.ExecuteTemplate(&b, files[0], vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,92 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRenderEmbeddedTemplates(t *testing.T) {
+	for name, data := range map[string]map[string]interface{}{
+		"reset_code":           {"Code": "123456", "ExpiresInMinutes": 15},
+		"verify_email":         {"Link": "https://api.example.com/verify-email?token=abc"},
+		"email_change_confirm": {"Link": "https://api.example.com/confirm-email-change?token=abc"},
+		"email_change_notice":  {"NewEmail": "john@example.com"},
+		"job_digest": {
+			"Count":           1,
+			"UnsubscribeLink": "https://api.example.com/unsubscribe?token=abc",
+			"Groups": []map[string]interface{}{{
+				"Company": "Acme",
+				"Role":    "Backend Engineer",
+				"Jobs":    []map[string]string{{"Title": "Senior Go Engineer", "Location": "Remote", "URL": "https://jobs.example.com/1"}},
+			}},
+		},
+	} {
+		msg, err := Render(name, "", data)
+		if err != nil {
+			t.Errorf("Render(%s) returned error: %v", name, err)
+			continue
+		}
+		if msg.Subject == "" || strings.Contains(msg.Subject, "\n") || !strings.Contains(msg.Subject, "JobScoop") {
+			t.Errorf("Render(%s) gave an unexpected subject %q", name, msg.Subject)
+		}
+		if msg.Text == "" || msg.HTML == "" || strings.Contains(msg.Text, "<no value>") {
+			t.Errorf("Render(%s) gave an incomplete message: %+v", name, msg)
+		}
+		if name == "job_digest" && (!strings.Contains(msg.Text, "Senior Go Engineer") || !strings.Contains(msg.HTML, "unsubscribe?token=abc")) {
+			t.Errorf("Expected the jobs and the unsubscribe link in the digest: %+v", msg)
+		}
+	}
+
+	if _, err := Render("no_such_template", "", nil); err == nil {
+		t.Error("Expected an error for an unknown template")
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	msg, err := Render("email_change_notice", "", map[string]interface{}{"NewEmail": `<script>alert(1)</script>@example.com`})
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if strings.Contains(msg.HTML, "<script>") || !strings.Contains(msg.HTML, "&lt;script&gt;") {
+		t.Errorf("Expected the address to be escaped in HTML:\n%s", msg.HTML)
+	}
+	if !strings.Contains(msg.Text, "<script>") {
+		t.Errorf("Expected the plain-text part to be left as is:\n%s", msg.Text)
+	}
+}
+
+func TestRenderLocaleFallbackAndBranding(t *testing.T) {
+	defer SetTemplates(mustSub(embeddedTemplates, "templates"))
+	defer SetBranding(branding)
+
+	SetTemplates(fstest.MapFS{
+		"en/layout.txt.tmpl":    {Data: []byte(`{{template "content" .}} -- {{.Brand.ProductName}}`)},
+		"en/layout.html.tmpl":   {Data: []byte(`<p>{{.Brand.ProductName}}</p>{{template "content" .}}`)},
+		"en/hello.subject.tmpl": {Data: []byte("Hello {{.Name}}\n")},
+		"en/hello.txt.tmpl":     {Data: []byte(`{{define "content"}}Hello {{.Name}}{{end}}`)},
+		"en/hello.html.tmpl":    {Data: []byte(`{{define "content"}}<b>Hello {{.Name}}</b>{{end}}`)},
+		"pt/hello.subject.tmpl": {Data: []byte("Olá {{.Name}}")},
+		"pt/hello.txt.tmpl":     {Data: []byte(`{{define "content"}}Olá {{.Name}} ({{.Locale}}){{end}}`)},
+	})
+	SetBranding(Branding{ProductName: "Acme Jobs"})
+
+	msg, err := Render("hello", "pt_BR", map[string]interface{}{"Name": "Ana"})
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if msg.Subject != "Olá Ana" {
+		t.Errorf("Expected the pt subject for pt_BR, got %q", msg.Subject)
+	}
+	if msg.Text != "Olá Ana (pt-br) -- Acme Jobs" {
+		t.Errorf("Expected the pt text inside the English layout, got %q", msg.Text)
+	}
+	if msg.HTML != "<p>Acme Jobs</p><b>Hello Ana</b>" {
+		t.Errorf("Expected the English HTML as fallback, got %q", msg.HTML)
+	}
+
+	msg, err = Render("hello", "de", map[string]interface{}{"Name": "Ana"})
+	if err != nil || msg.Subject != "Hello Ana" {
+		t.Errorf("Expected the default locale for an unknown one, got %q (%v)", msg.Subject, err)
+	}
+}
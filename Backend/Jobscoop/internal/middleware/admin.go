@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"JobScoop/internal/db"
+	"net/http"
+)
+
+var isAdminFunc = isAdmin
+
+// RequireAdmin only lets requests from admins through. It must run after Auth.
+// The role is read from the database on every request so that demotions apply at once.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := UserIDFromContext(r.Context())
+		if !ok {
+			http.Error(w, `{"message": "Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		// Admin actions are for people, never for scripts holding an API key
+		if _, viaKey := APIKeyScopesFromContext(r.Context()); viaKey {
+			http.Error(w, `{"message": "Admin access required"}`, http.StatusForbidden)
+			return
+		}
+
+		admin, err := isAdminFunc(userID)
+		if err != nil {
+			http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if !admin {
+			http.Error(w, `{"message": "Admin access required"}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isAdmin(userID int) (bool, error) {
+	var admin bool
+	err := db.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND is_admin AND disabled_at IS NULL)`, userID).Scan(&admin)
+	return admin, err
+}
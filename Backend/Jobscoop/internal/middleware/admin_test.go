@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	isAdminFunc = func(userID int) (bool, error) { return userID == 1, nil }
+	defer func() { isAdminFunc = isAdmin }()
+
+	tests := []struct {
+		name         string
+		userID       int
+		viaAPIKey    bool
+		expectedCode int
+	}{
+		{name: "Admin", userID: 1, expectedCode: http.StatusOK},
+		{name: "Regular User", userID: 2, expectedCode: http.StatusForbidden},
+		{name: "Admin Using An API Key", userID: 1, viaAPIKey: true, expectedCode: http.StatusForbidden},
+		{name: "Not Authenticated", expectedCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+			ctx := req.Context()
+			if tt.userID != 0 {
+				ctx = WithUserID(ctx, tt.userID)
+			}
+			if tt.viaAPIKey {
+				ctx = withAPIKeyScopes(ctx, []string{ScopeJobsRead})
+			}
+			rr := httptest.NewRecorder()
+			RequireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rr, req.WithContext(ctx))
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/services"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type contextKey string
+
+const (
+	userIDKey       contextKey = "userID"
+	claimsKey       contextKey = "claims"
+	apiKeyScopesKey contextKey = "apiKeyScopes"
+)
+
+// APIKeyPrefix starts every personal API key, which tells keys apart from access tokens.
+const APIKeyPrefix = "jsk_"
+
+// Scopes a personal API key can be granted.
+const (
+	ScopeJobsRead           = "jobs:read"
+	ScopeSubscriptionsWrite = "subscriptions:write"
+)
+
+// APIKeyScopes lists every scope a key can be created with.
+var APIKeyScopes = []string{ScopeJobsRead, ScopeSubscriptionsWrite}
+
+var (
+	isTokenRevokedFunc = isTokenRevoked
+	lookupAPIKeyFunc   = lookupAPIKey
+)
+
+// Auth rejects requests without a valid, unrevoked "Authorization: Bearer <token>" header
+// and stores the authenticated user ID and token claims in the request context.
+// Personal API keys are refused; routes that scripts may call use AuthWithScope.
+func Auth(next http.Handler) http.Handler {
+	return authenticate(next, nil)
+}
+
+// AuthWithScope works like Auth but also accepts a personal API key holding one of scopes.
+func AuthWithScope(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return authenticate(next, scopes)
+	}
+}
+
+func authenticate(next http.Handler, scopes []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		tokenString := strings.TrimPrefix(header, "Bearer ")
+		if header == "" || tokenString == header || tokenString == "" {
+			http.Error(w, `{"message": "Missing or malformed authorization header"}`, http.StatusUnauthorized)
+			return
+		}
+
+		if strings.HasPrefix(tokenString, APIKeyPrefix) {
+			if len(scopes) == 0 {
+				http.Error(w, `{"message": "API keys cannot be used for this endpoint"}`, http.StatusForbidden)
+				return
+			}
+			userID, keyScopes, err := lookupAPIKeyFunc(tokenString)
+			if err != nil {
+				http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+				return
+			}
+			if userID == 0 {
+				http.Error(w, `{"message": "Invalid or revoked API key"}`, http.StatusUnauthorized)
+				return
+			}
+			if !hasAnyScope(keyScopes, scopes) {
+				http.Error(w, `{"message": "API key is missing the required scope"}`, http.StatusForbidden)
+				return
+			}
+			ctx := withAPIKeyScopes(WithUserID(r.Context(), userID), keyScopes)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		claims, err := services.ParseAccessToken(tokenString)
+		if err != nil {
+			http.Error(w, `{"message": "Invalid or expired token"}`, http.StatusUnauthorized)
+			return
+		}
+
+		revoked, err := isTokenRevokedFunc(claims)
+		if err != nil {
+			http.Error(w, `{"message": "Database error"}`, http.StatusInternalServerError)
+			return
+		}
+		if revoked {
+			http.Error(w, `{"message": "Token has been revoked"}`, http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(WithUserID(r.Context(), claims.UserID), claimsKey, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// isTokenRevoked reports whether the token was logged out individually (by jti), was
+// issued before the user's last "log out all sessions", or belongs to a disabled account.
+// Tokens carry the session generation they were issued in, so the sign in right after a
+// password reset stays valid however soon it follows, and no earlier token does.
+func isTokenRevoked(claims *services.Claims) (bool, error) {
+	var revoked bool
+	err := db.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+			OR EXISTS (SELECT 1 FROM users WHERE id = $2 AND (session_generation <> $3 OR disabled_at IS NOT NULL))`,
+		claims.ID, claims.UserID, claims.Generation,
+	).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
+
+// lookupAPIKey finds an active key by the hash of the presented key and records its use.
+// It returns user ID 0 when the key is unknown, revoked or expired, or its owner is disabled.
+func lookupAPIKey(key string) (int, []string, error) {
+	sum := sha256.Sum256([]byte(key))
+	now := time.Now().UTC()
+
+	var userID int
+	var scopes []string
+	err := db.DB.QueryRow(`
+		UPDATE api_keys k SET last_used_at = $2
+		FROM users u
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > $2)
+		AND u.id = k.user_id AND u.disabled_at IS NULL
+		RETURNING k.user_id, k.scopes`,
+		hex.EncodeToString(sum[:]), now,
+	).Scan(&userID, pq.Array(&scopes))
+	if err == sql.ErrNoRows {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return userID, scopes, nil
+}
+
+func hasAnyScope(granted, required []string) bool {
+	for _, g := range granted {
+		for _, r := range required {
+			if g == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WithUserID returns a copy of ctx carrying the authenticated user ID.
+func WithUserID(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// UserIDFromContext returns the user ID stored by Auth, if any.
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(userIDKey).(int)
+	return userID, ok
+}
+
+// ClaimsFromContext returns the access token claims stored by Auth, if any.
+func ClaimsFromContext(ctx context.Context) (*services.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*services.Claims)
+	return claims, ok
+}
+
+func withAPIKeyScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, apiKeyScopesKey, scopes)
+}
+
+// APIKeyScopesFromContext returns the scopes of the API key that authenticated the request.
+// ok is false when the request was authenticated with an access token.
+func APIKeyScopesFromContext(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(apiKeyScopesKey).([]string)
+	return scopes, ok
+}
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/services"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var testKey, otherKey ed25519.PrivateKey
+
+func init() {
+	_, testKey, _ = ed25519.GenerateKey(rand.Reader)
+	_, otherKey, _ = ed25519.GenerateKey(rand.Reader)
+	services.SetKeySet(services.NewKeySet(&services.SigningKey{
+		ID:         "test",
+		Method:     jwt.SigningMethodEdDSA,
+		PrivateKey: testKey,
+	}))
+}
+
+func signTestToken(t *testing.T, key ed25519.PrivateKey, userID int, jti string, expiresAt time.Time) string {
+	claims := &services.Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			Issuer:    "jobscoop",
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = "test"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Could not sign token: %v", err)
+	}
+	return signed
+}
+
+func TestAuth(t *testing.T) {
+	valid := signTestToken(t, testKey, 42, "valid-jti", time.Now().Add(time.Hour))
+	revoked := signTestToken(t, testKey, 42, "revoked-jti", time.Now().Add(time.Hour))
+	noJTI := signTestToken(t, testKey, 42, "", time.Now().Add(time.Hour))
+	expired := signTestToken(t, testKey, 42, "expired-jti", time.Now().Add(-time.Minute))
+	forged := signTestToken(t, otherKey, 42, "forged-jti", time.Now().Add(time.Hour))
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, &services.Claims{
+		UserID: 42,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       "unsigned-jti",
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+			Issuer:   "jobscoop",
+		},
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	isTokenRevokedFunc = func(claims *services.Claims) (bool, error) {
+		return claims.ID == "revoked-jti", nil
+	}
+	defer func() { isTokenRevokedFunc = isTokenRevoked }()
+
+	tests := []struct {
+		name         string
+		header       string
+		expectedCode int
+	}{
+		{name: "Valid Token", header: "Bearer " + valid, expectedCode: http.StatusOK},
+		{name: "Missing Header", header: "", expectedCode: http.StatusUnauthorized},
+		{name: "Missing Bearer Prefix", header: valid, expectedCode: http.StatusUnauthorized},
+		{name: "Revoked Token", header: "Bearer " + revoked, expectedCode: http.StatusUnauthorized},
+		{name: "Token Without JTI", header: "Bearer " + noJTI, expectedCode: http.StatusUnauthorized},
+		{name: "Expired Token", header: "Bearer " + expired, expectedCode: http.StatusUnauthorized},
+		{name: "Wrong Signing Key", header: "Bearer " + forged, expectedCode: http.StatusUnauthorized},
+		{name: "Unsigned Token", header: "Bearer " + unsigned, expectedCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUserID int
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserID, _ = UserIDFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/get-user", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			Auth(next).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, rr.Code)
+			}
+			if tt.expectedCode == http.StatusOK && gotUserID != 42 {
+				t.Errorf("Expected user ID 42 in context, got %d", gotUserID)
+			}
+		})
+	}
+}
+
+func TestAuthWithScope(t *testing.T) {
+	valid := signTestToken(t, testKey, 42, "valid-jti", time.Now().Add(time.Hour))
+
+	isTokenRevokedFunc = func(claims *services.Claims) (bool, error) { return false, nil }
+	lookupAPIKeyFunc = func(key string) (int, []string, error) {
+		switch key {
+		case "jsk_0a1b2c3d_jobs":
+			return 7, []string{ScopeJobsRead}, nil
+		case "jsk_0a1b2c3d_subs":
+			return 7, []string{ScopeSubscriptionsWrite}, nil
+		}
+		return 0, nil, nil
+	}
+	defer func() {
+		isTokenRevokedFunc = isTokenRevoked
+		lookupAPIKeyFunc = lookupAPIKey
+	}()
+
+	tests := []struct {
+		name           string
+		middleware     func(http.Handler) http.Handler
+		header         string
+		expectedCode   int
+		expectedUserID int
+		expectAPIKey   bool
+	}{
+		{name: "Access Token On Scoped Route", middleware: AuthWithScope(ScopeJobsRead), header: "Bearer " + valid, expectedCode: http.StatusOK, expectedUserID: 42},
+		{name: "API Key With Scope", middleware: AuthWithScope(ScopeJobsRead), header: "Bearer jsk_0a1b2c3d_jobs", expectedCode: http.StatusOK, expectedUserID: 7, expectAPIKey: true},
+		{name: "API Key With One Of Several Scopes", middleware: AuthWithScope(ScopeJobsRead, ScopeSubscriptionsWrite), header: "Bearer jsk_0a1b2c3d_subs", expectedCode: http.StatusOK, expectedUserID: 7, expectAPIKey: true},
+		{name: "API Key Missing Scope", middleware: AuthWithScope(ScopeSubscriptionsWrite), header: "Bearer jsk_0a1b2c3d_jobs", expectedCode: http.StatusForbidden},
+		{name: "Unknown Or Revoked API Key", middleware: AuthWithScope(ScopeJobsRead), header: "Bearer jsk_0a1b2c3d_nope", expectedCode: http.StatusUnauthorized},
+		{name: "API Key On Session-Only Route", middleware: Auth, header: "Bearer jsk_0a1b2c3d_jobs", expectedCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUserID int
+			var gotAPIKey bool
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserID, _ = UserIDFromContext(r.Context())
+				_, gotAPIKey = APIKeyScopesFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/subscriptions/jobs", nil)
+			req.Header.Set("Authorization", tt.header)
+			rr := httptest.NewRecorder()
+			tt.middleware(next).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, rr.Code)
+			}
+			if gotUserID != tt.expectedUserID || gotAPIKey != tt.expectAPIKey {
+				t.Errorf("Expected user %d (api key %v), got %d (api key %v)", tt.expectedUserID, tt.expectAPIKey, gotUserID, gotAPIKey)
+			}
+		})
+	}
+}
+
+func TestIsTokenRevokedSessionGeneration(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	// Whenever it was issued, a token is refused once the user moved on to another session generation
+	issued := time.Now().UTC()
+	for _, tt := range []struct {
+		generation int
+		revoked    bool
+	}{{generation: 3, revoked: false}, {generation: 2, revoked: true}} {
+		claims := &services.Claims{UserID: 42, Generation: tt.generation, RegisteredClaims: jwt.RegisteredClaims{ID: "jti", IssuedAt: jwt.NewNumericDate(issued)}}
+		mock.ExpectQuery("session_generation <> \\$3").
+			WithArgs("jti", 42, tt.generation).
+			WillReturnRows(sqlmock.NewRows([]string{"revoked"}).AddRow(tt.revoked))
+
+		if revoked, err := isTokenRevoked(claims); err != nil || revoked != tt.revoked {
+			t.Errorf("Generation %d: expected revoked %v, got %v (%v)", tt.generation, tt.revoked, revoked, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
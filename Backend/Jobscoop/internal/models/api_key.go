@@ -0,0 +1,34 @@
+package models
+
+import (
+	"JobScoop/internal/db"
+	"log"
+)
+
+// CreateAPIKeysTable creates the api_keys table if it does not exist.
+// Only a SHA-256 hash of each key is stored; prefix is the short, non-secret
+// start of the key that lets users recognise it in listings.
+func CreateAPIKeysTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		name VARCHAR(100) NOT NULL,
+		prefix TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		scopes TEXT[] NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP,
+		last_used_at TIMESTAMP,
+		revoked_at TIMESTAMP,
+
+		CONSTRAINT fk_api_key_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys (user_id);
+	`
+
+	_, err := db.DB.Exec(query)
+	if err != nil {
+		log.Fatalf("Error creating api_keys table: %v", err)
+	}
+}
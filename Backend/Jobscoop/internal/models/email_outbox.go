@@ -0,0 +1,167 @@
+package models
+
+import (
+	"JobScoop/internal/db"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Email outbox statuses. A message is pending until a sender claims it, sending while a sender
+// holds it, and sent or dead once delivered or out of attempts.
+const (
+	EmailPending = "pending"
+	EmailSending = "sending"
+	EmailSent    = "sent"
+	EmailDead    = "dead"
+)
+
+// CreateEmailOutboxTable creates the email_outbox table if it does not exist.
+// Bodies are cleared once a message is sent, they can carry reset codes and sign-in links.
+func CreateEmailOutboxTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS email_outbox (
+		id SERIAL PRIMARY KEY,
+		recipients TEXT[] NOT NULL,
+		subject TEXT NOT NULL,
+		text_body TEXT NOT NULL DEFAULT '',
+		html_body TEXT NOT NULL DEFAULT '',
+		list_unsubscribe TEXT NOT NULL DEFAULT '',
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		locked_until TIMESTAMP WITH TIME ZONE,
+		last_error TEXT,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		sent_at TIMESTAMP WITH TIME ZONE
+	);
+	CREATE INDEX IF NOT EXISTS idx_email_outbox_due ON email_outbox (status, next_attempt_at);
+	`
+
+	_, err := db.DB.Exec(query)
+	if err != nil {
+		log.Fatalf("Error creating email_outbox table: %v", err)
+	}
+}
+
+// OutboxEmail is a message claimed from the outbox for delivery.
+type OutboxEmail struct {
+	ID              int
+	Recipients      []string
+	Subject         string
+	Text            string
+	HTML            string
+	ListUnsubscribe string
+	Attempts        int
+}
+
+const enqueueEmailQuery = `
+	INSERT INTO email_outbox (recipients, subject, text_body, html_body, list_unsubscribe)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id`
+
+// EnqueueEmail stores a message for the background sender and returns its id.
+func EnqueueEmail(recipients []string, subject, text, html, listUnsubscribe string) (int, error) {
+	var id int
+	err := db.DB.QueryRow(enqueueEmailQuery, pq.Array(recipients), subject, text, html, listUnsubscribe).Scan(&id)
+	return id, err
+}
+
+// EnqueueEmailTx is EnqueueEmail within tx: the message is only sent if tx commits.
+func EnqueueEmailTx(tx *sql.Tx, recipients []string, subject, text, html, listUnsubscribe string) (int, error) {
+	var id int
+	err := tx.QueryRow(enqueueEmailQuery, pq.Array(recipients), subject, text, html, listUnsubscribe).Scan(&id)
+	return id, err
+}
+
+// ClaimDueEmails marks up to limit due messages as sending until now+lease and counts the attempt.
+// Messages whose sender died mid-delivery become due again once their lease runs out; SKIP LOCKED
+// lets several senders share the outbox without claiming the same message.
+func ClaimDueEmails(now time.Time, limit int, lease time.Duration) ([]OutboxEmail, error) {
+	rows, err := db.DB.Query(`
+		UPDATE email_outbox SET status = 'sending', locked_until = $3, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE (status = 'pending' AND next_attempt_at <= $1) OR (status = 'sending' AND locked_until <= $1)
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, recipients, subject, text_body, html_body, list_unsubscribe, attempts`,
+		now, limit, now.Add(lease),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []OutboxEmail
+	for rows.Next() {
+		var e OutboxEmail
+		if err := rows.Scan(&e.ID, pq.Array(&e.Recipients), &e.Subject, &e.Text, &e.HTML, &e.ListUnsubscribe, &e.Attempts); err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+	return emails, rows.Err()
+}
+
+// MarkEmailSent records a delivery and drops the message bodies. attempts is the count the
+// message was claimed with; it reports false when the sender lost the message, its lease having
+// run out and another sender claimed it since.
+func MarkEmailSent(id, attempts int, at time.Time) (bool, error) {
+	res, err := db.DB.Exec(`
+		UPDATE email_outbox
+		SET status = 'sent', sent_at = $1, locked_until = NULL, last_error = NULL, text_body = '', html_body = ''
+		WHERE id = $2 AND status = 'sending' AND attempts = $3`, at, id, attempts)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
+
+// MarkEmailFailed records a failed attempt. The message is retried at retryAt, or dead-lettered
+// when retryAt is nil. Like MarkEmailSent it reports false when the sender lost the message.
+func MarkEmailFailed(id, attempts int, lastError string, retryAt *time.Time) (bool, error) {
+	var res sql.Result
+	var err error
+	if retryAt == nil {
+		res, err = db.DB.Exec(`
+			UPDATE email_outbox SET status = 'dead', locked_until = NULL, last_error = $1
+			WHERE id = $2 AND status = 'sending' AND attempts = $3`,
+			lastError, id, attempts)
+	} else {
+		res, err = db.DB.Exec(`
+			UPDATE email_outbox SET status = 'pending', locked_until = NULL, last_error = $1, next_attempt_at = $2
+			WHERE id = $3 AND status = 'sending' AND attempts = $4`, lastError, *retryAt, id, attempts)
+	}
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
+
+// RequeueEmail gives a dead or pending message a fresh set of attempts, due at now.
+// It reports false when there is no such message or it was already sent or is being sent.
+func RequeueEmail(id int, now time.Time) (bool, error) {
+	res, err := db.DB.Exec(`
+		UPDATE email_outbox SET status = 'pending', attempts = 0, next_attempt_at = $1, locked_until = NULL
+		WHERE id = $2 AND status IN ('pending', 'dead')`, now, id)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
+
+// PurgeSentEmails deletes the records of messages sent before cutoff.
+func PurgeSentEmails(cutoff time.Time) (int64, error) {
+	res, err := db.DB.Exec(`DELETE FROM email_outbox WHERE status = 'sent' AND sent_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"JobScoop/internal/db"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Fetch task statuses. A task is pending until a worker claims it and running while the worker
+// holds it; it goes back to pending once done, due again at its next refresh or retry.
+const (
+	FetchPending = "pending"
+	FetchRunning = "running"
+)
+
+// Fetch task priorities, higher ones are claimed first. A company and role that were never
+// fetched go ahead of the refreshes so new subscriptions get their postings quickly.
+const (
+	FetchPriorityRefresh = 0
+	FetchPriorityFirst   = 10
+)
+
+// CreateFetchTasksTable creates the fetch_tasks table if it does not exist.
+// It is the worker queue, one task per subscribed company, role and job source, shared by the
+// company's subscribers. Sources that read unchecked links have one per subscription as well,
+// with its subscription_id; it is 0 on the shared tasks.
+func CreateFetchTasksTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS fetch_tasks (
+		id SERIAL PRIMARY KEY,
+		company_id INT NOT NULL,
+		role_id INT NOT NULL,
+		source TEXT NOT NULL,
+		subscription_id INT NOT NULL DEFAULT 0,
+		priority INT NOT NULL DEFAULT 0,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		locked_until TIMESTAMP WITH TIME ZONE,
+		last_error TEXT,
+		last_fetched_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+		UNIQUE (company_id, role_id, source, subscription_id),
+		CONSTRAINT fk_fetch_task_company FOREIGN KEY (company_id) REFERENCES companies(id) ON DELETE CASCADE,
+		CONSTRAINT fk_fetch_task_role FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_fetch_tasks_due ON fetch_tasks (status, priority DESC, next_attempt_at);
+	`
+
+	_, err := db.DB.Exec(query)
+	if err != nil {
+		log.Fatalf("Error creating fetch_tasks table: %v", err)
+	}
+}
+
+// FetchTask is a task claimed from the queue, with what the search needs: the company and role
+// names, the career sites its active subscriptions list, or only its own subscription's on a task
+// of one subscription, and that subscription's feeds.
+type FetchTask struct {
+	ID             int
+	CompanyID      int
+	RoleID         int
+	Source         string
+	SubscriptionID int
+	Company        string
+	Role           string
+	CareerSites    []string
+	Feeds          []string
+	Attempts       int
+}
+
+// ScheduleFetchTasks adds a task, due at now, for every company and role of an active subscription
+// and every one of sources that does not have one yet, and one for the subscription itself for
+// every one of subscriptionSources. It drops the tasks no active subscription or enabled source
+// needs any more. Tasks being run are left alone.
+func ScheduleFetchTasks(sources, subscriptionSources []string, now time.Time) (added int64, removed int64, err error) {
+	res, err := db.DB.Exec(`
+		INSERT INTO fetch_tasks (company_id, role_id, source, subscription_id, priority, next_attempt_at)
+		SELECT DISTINCT subscriptions.company_id, roles.id, source, owner, $3::INT, $4::TIMESTAMP WITH TIME ZONE
+		FROM subscriptions
+		JOIN roles ON roles.id = ANY(subscriptions.role_ids)
+		CROSS JOIN unnest($1::TEXT[]) AS source
+		CROSS JOIN unnest(ARRAY[0, subscriptions.id]) AS owner
+		WHERE subscriptions.active AND (owner = 0 OR source = ANY($2))
+		ON CONFLICT (company_id, role_id, source, subscription_id) DO NOTHING`,
+		pq.Array(sources), pq.Array(subscriptionSources), FetchPriorityFirst, now)
+	if err != nil {
+		return 0, 0, err
+	}
+	if added, err = res.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+
+	res, err = db.DB.Exec(`
+		DELETE FROM fetch_tasks
+		WHERE status <> 'running' AND (source <> ALL($1) OR (subscription_id <> 0 AND source <> ALL($2)) OR NOT EXISTS (
+			SELECT 1 FROM subscriptions
+			WHERE subscriptions.active AND subscriptions.company_id = fetch_tasks.company_id
+			AND fetch_tasks.role_id = ANY(subscriptions.role_ids) AND fetch_tasks.subscription_id IN (0, subscriptions.id)
+		))`, pq.Array(sources), pq.Array(subscriptionSources))
+	if err != nil {
+		return added, 0, err
+	}
+	removed, err = res.RowsAffected()
+	return added, removed, err
+}
+
+// ClaimDueFetchTasks marks up to limit due tasks as running until now+lease and counts the
+// attempt, highest priority first. Tasks whose worker died or overran its lease become due again
+// once the lease runs out; SKIP LOCKED lets several workers share the queue without claiming the
+// same task.
+func ClaimDueFetchTasks(now time.Time, limit int, lease time.Duration) ([]FetchTask, error) {
+	rows, err := db.DB.Query(`
+		UPDATE fetch_tasks SET status = 'running', locked_until = $3, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM fetch_tasks
+			WHERE (status = 'pending' AND next_attempt_at <= $1) OR (status = 'running' AND locked_until <= $1)
+			ORDER BY priority DESC, next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, company_id, role_id, source, subscription_id, attempts,
+			(SELECT name FROM companies WHERE companies.id = fetch_tasks.company_id),
+			(SELECT name FROM roles WHERE roles.id = fetch_tasks.role_id),
+			ARRAY(SELECT DISTINCT career_sites.link FROM career_sites
+				JOIN subscriptions ON career_sites.id = ANY(subscriptions.career_site_ids)
+				WHERE subscriptions.active AND subscriptions.company_id = fetch_tasks.company_id
+				AND fetch_tasks.subscription_id IN (0, subscriptions.id) ORDER BY 1),
+			ARRAY(SELECT DISTINCT unnest(feed_links) FROM subscriptions
+				WHERE subscriptions.active AND subscriptions.id = fetch_tasks.subscription_id ORDER BY 1)`,
+		now, limit, now.Add(lease),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []FetchTask
+	for rows.Next() {
+		var t FetchTask
+		if err := rows.Scan(&t.ID, &t.CompanyID, &t.RoleID, &t.Source, &t.SubscriptionID, &t.Attempts, &t.Company, &t.Role,
+			pq.Array(&t.CareerSites), pq.Array(&t.Feeds)); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+// CompleteFetchTask records a successful fetch at and makes the task due again at next, as a
+// refresh. It reports false when the worker lost the task, its lease having run out and another
+// worker claimed it since.
+func CompleteFetchTask(id, attempts int, at, next time.Time) (bool, error) {
+	res, err := db.DB.Exec(`
+		UPDATE fetch_tasks
+		SET status = 'pending', attempts = 0, priority = $1, next_attempt_at = $2, locked_until = NULL,
+			last_error = NULL, last_fetched_at = $3
+		WHERE id = $4 AND status = 'running' AND attempts = $5`,
+		FetchPriorityRefresh, next, at, id, attempts)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
+
+// FailFetchTask records a failed attempt, the task is retried at retryAt. When giveUp is set the
+// attempts start over, retryAt being the next refresh. It reports false when the worker lost the
+// task.
+func FailFetchTask(id, attempts int, lastError string, retryAt time.Time, giveUp bool) (bool, error) {
+	res, err := db.DB.Exec(`
+		UPDATE fetch_tasks
+		SET status = 'pending', attempts = CASE WHEN $1 THEN 0 ELSE attempts END, next_attempt_at = $2,
+			locked_until = NULL, last_error = $3
+		WHERE id = $4 AND status = 'running' AND attempts = $5`,
+		giveUp, retryAt, lastError, id, attempts)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"JobScoop/internal/db"
+	"log"
+)
+
+// CreateIdentitiesTable creates the identities table if it does not exist.
+// An identity links the subject of an external OIDC provider to a JobScoop user;
+// a user has at most one identity per provider.
+func CreateIdentitiesTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS identities (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		provider TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		email VARCHAR(100),
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_login_at TIMESTAMP,
+
+		CONSTRAINT fk_identity_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		CONSTRAINT unique_provider_subject UNIQUE (provider, subject),
+		CONSTRAINT unique_user_provider UNIQUE (user_id, provider)
+	);
+	`
+
+	_, err := db.DB.Exec(query)
+	if err != nil {
+		log.Fatalf("Error creating identities table: %v", err)
+	}
+}
+
+// CreateOIDCLoginStatesTable creates the oidc_login_states and oidc_link_tickets tables if they
+// do not exist. Each oidc_login_states row is one pending authorization request, keyed by a hash
+// of its state parameter and deleted when the provider redirects back. link_user_id is set when
+// an already signed-in user is linking a provider rather than logging in. A link ticket lets that
+// user's browser open the start of the link flow itself, once, so the state cookie is set on it.
+// An oidc_login_codes row is a completed sign in waiting for the frontend to exchange its code
+// for tokens, once.
+func CreateOIDCLoginStatesTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS oidc_login_states (
+		state_hash TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		nonce TEXT NOT NULL,
+		code_verifier TEXT NOT NULL,
+		link_user_id INT,
+		expires_at TIMESTAMP NOT NULL,
+
+		CONSTRAINT fk_oidc_state_user FOREIGN KEY (link_user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	CREATE TABLE IF NOT EXISTS oidc_link_tickets (
+		ticket_hash TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		user_id INT NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+
+		CONSTRAINT fk_oidc_link_ticket_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	CREATE TABLE IF NOT EXISTS oidc_login_codes (
+		code_hash TEXT PRIMARY KEY,
+		user_id INT NOT NULL,
+		mfa_required BOOLEAN NOT NULL DEFAULT FALSE,
+		expires_at TIMESTAMP NOT NULL,
+
+		CONSTRAINT fk_oidc_login_code_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	`
+
+	_, err := db.DB.Exec(query)
+	if err != nil {
+		log.Fatalf("Error creating oidc_login_states table: %v", err)
+	}
+}
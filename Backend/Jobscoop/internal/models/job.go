@@ -0,0 +1,215 @@
+package models
+
+import (
+	"JobScoop/internal/db"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// CreateJobsTable creates the jobs and job_sources tables if they do not exist.
+// A posting is stored once per company under its dedup key however many sources list it;
+// job_sources links it to each source's id for it. A source links at most one of its ids to a
+// row, two ids from one source with the same key are two openings. The jobs row keeps the source
+// and link of the company's own ATS when one found it, and of the first board that did otherwise.
+// Postings found on the links of one subscription only are kept apart under its subscription_id,
+// which is 0 on the postings every subscriber of the company sees.
+func CreateJobsTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS jobs (
+		id SERIAL PRIMARY KEY,
+		company_id INT NOT NULL,
+		subscription_id INT NOT NULL DEFAULT 0,
+		dedup_key TEXT NOT NULL,
+		source TEXT NOT NULL,
+		external_id TEXT NOT NULL,
+		canonical_url TEXT NOT NULL DEFAULT '',
+		title TEXT NOT NULL,
+		location TEXT NOT NULL DEFAULT '',
+		posted_at TIMESTAMP WITH TIME ZONE,
+		first_seen TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_seen TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+		CONSTRAINT fk_job_company FOREIGN KEY (company_id) REFERENCES companies(id) ON DELETE CASCADE
+	);
+	ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_company_id_dedup_key_key;
+	CREATE INDEX IF NOT EXISTS idx_jobs_dedup_key ON jobs (company_id, dedup_key);
+	CREATE INDEX IF NOT EXISTS idx_jobs_last_seen ON jobs (company_id, last_seen);
+
+	CREATE TABLE IF NOT EXISTS job_sources (
+		subscription_id INT NOT NULL DEFAULT 0,
+		source TEXT NOT NULL,
+		external_id TEXT NOT NULL,
+		job_id INT NOT NULL,
+		url TEXT NOT NULL DEFAULT '',
+		first_seen TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_seen TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (subscription_id, source, external_id),
+		CONSTRAINT fk_job_source_job FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_job_sources_job_id ON job_sources (job_id);
+	`
+
+	_, err := db.DB.Exec(query)
+	if err != nil {
+		log.Fatalf("Error creating jobs table: %v", err)
+	}
+}
+
+// Job is a stored posting. Sources lists every source it was found on. SubscriptionID is set on
+// postings found on links only that subscription lists.
+type Job struct {
+	ID             int
+	CompanyID      int
+	SubscriptionID int
+	DedupKey       string
+	Source         string
+	ExternalID     string
+	CanonicalURL   string
+	Title          string
+	Location       string
+	PostedAt       *time.Time
+	FirstSeen      time.Time
+	LastSeen       time.Time
+	Sources        []string
+}
+
+// SaveJob records that job.Source listed the posting at seen and returns the stored posting.
+// A posting the source listed before keeps its row even if it was retitled since; otherwise it is
+// matched by DedupKey to a posting of its company, or of its subscription, that another source
+// found, and added when there is none. Postings from aggregator boards never replace the source and link of one found on the
+// company's own site.
+func SaveJob(job Job, aggregated bool, seen time.Time) (Job, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return Job{}, err
+	}
+	defer tx.Rollback()
+
+	var stored Job
+	var jobID int
+	err = tx.QueryRow(`SELECT job_id FROM job_sources WHERE subscription_id = $1 AND source = $2 AND external_id = $3`,
+		job.SubscriptionID, job.Source, job.ExternalID).Scan(&jobID)
+	switch {
+	case err == sql.ErrNoRows:
+		jobID, err = matchingJob(tx, job)
+		if err != nil {
+			return Job{}, err
+		}
+		if jobID == 0 {
+			err = tx.QueryRow(`
+				INSERT INTO jobs (company_id, subscription_id, dedup_key, source, external_id, canonical_url, title, location, posted_at, first_seen, last_seen)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)
+				RETURNING id, company_id, subscription_id, dedup_key, source, external_id, canonical_url, title, location, posted_at, first_seen, last_seen`,
+				job.CompanyID, job.SubscriptionID, job.DedupKey, job.Source, job.ExternalID, job.CanonicalURL, job.Title, job.Location, job.PostedAt, seen,
+			).Scan(&stored.ID, &stored.CompanyID, &stored.SubscriptionID, &stored.DedupKey, &stored.Source, &stored.ExternalID, &stored.CanonicalURL,
+				&stored.Title, &stored.Location, &stored.PostedAt, &stored.FirstSeen, &stored.LastSeen)
+			break
+		}
+		err = tx.QueryRow(`
+			UPDATE jobs SET
+				source = CASE WHEN $2 THEN jobs.source ELSE $3 END,
+				external_id = CASE WHEN $2 THEN jobs.external_id ELSE $4 END,
+				canonical_url = CASE WHEN $2 AND jobs.canonical_url <> '' THEN jobs.canonical_url ELSE $5 END,
+				posted_at = COALESCE(jobs.posted_at, $6),
+				last_seen = GREATEST(jobs.last_seen, $7)
+			WHERE id = $1
+			RETURNING id, company_id, subscription_id, dedup_key, source, external_id, canonical_url, title, location, posted_at, first_seen, last_seen`,
+			jobID, aggregated, job.Source, job.ExternalID, job.CanonicalURL, job.PostedAt, seen,
+		).Scan(&stored.ID, &stored.CompanyID, &stored.SubscriptionID, &stored.DedupKey, &stored.Source, &stored.ExternalID, &stored.CanonicalURL,
+			&stored.Title, &stored.Location, &stored.PostedAt, &stored.FirstSeen, &stored.LastSeen)
+	case err == nil:
+		err = tx.QueryRow(`
+			UPDATE jobs SET
+				title = CASE WHEN $2 AND jobs.source <> $3 THEN jobs.title ELSE $4 END,
+				location = CASE WHEN $2 AND jobs.source <> $3 THEN jobs.location ELSE $5 END,
+				posted_at = COALESCE(jobs.posted_at, $6),
+				last_seen = GREATEST(jobs.last_seen, $7)
+			WHERE id = $1
+			RETURNING id, company_id, subscription_id, dedup_key, source, external_id, canonical_url, title, location, posted_at, first_seen, last_seen`,
+			jobID, aggregated, job.Source, job.Title, job.Location, job.PostedAt, seen,
+		).Scan(&stored.ID, &stored.CompanyID, &stored.SubscriptionID, &stored.DedupKey, &stored.Source, &stored.ExternalID, &stored.CanonicalURL,
+			&stored.Title, &stored.Location, &stored.PostedAt, &stored.FirstSeen, &stored.LastSeen)
+	}
+	if err != nil {
+		return Job{}, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO job_sources (subscription_id, source, external_id, job_id, url, first_seen, last_seen)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		ON CONFLICT (subscription_id, source, external_id) DO UPDATE SET
+			job_id = EXCLUDED.job_id, url = EXCLUDED.url, last_seen = GREATEST(job_sources.last_seen, EXCLUDED.last_seen)`,
+		job.SubscriptionID, job.Source, job.ExternalID, stored.ID, job.CanonicalURL, seen)
+	if err != nil {
+		return Job{}, err
+	}
+	err = tx.QueryRow(`SELECT ARRAY(SELECT DISTINCT source FROM job_sources WHERE job_id = $1 ORDER BY source)`, stored.ID).
+		Scan(pq.Array(&stored.Sources))
+	if err != nil {
+		return Job{}, err
+	}
+	return stored, tx.Commit()
+}
+
+// matchingJob returns the id of the oldest posting of job's company and subscription with its
+// dedup key that job's source has not listed, or 0 when there is none. Workers saving the same key wait for
+// each other until commit, so a posting two sources find at once is still stored once.
+func matchingJob(tx *sql.Tx, job Job) (int, error) {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, hashtext($2))`, job.CompanyID, job.DedupKey); err != nil {
+		return 0, err
+	}
+	var jobID int
+	err := tx.QueryRow(`
+		SELECT id FROM jobs
+		WHERE company_id = $1 AND subscription_id = $2 AND dedup_key = $3
+		AND NOT EXISTS (SELECT 1 FROM job_sources WHERE job_sources.job_id = jobs.id AND job_sources.source = $4)
+		ORDER BY id LIMIT 1`, job.CompanyID, job.SubscriptionID, job.DedupKey, job.Source).Scan(&jobID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return jobID, err
+}
+
+// RecentJobs returns the postings of company companyID seen since, newest first: those every
+// subscriber sees and those found for subscriptionID only.
+func RecentJobs(companyID, subscriptionID int, since time.Time) ([]Job, error) {
+	rows, err := db.DB.Query(`
+		SELECT jobs.id, jobs.company_id, jobs.subscription_id, jobs.dedup_key, jobs.source, jobs.external_id, jobs.canonical_url,
+			jobs.title, jobs.location, jobs.posted_at, jobs.first_seen, jobs.last_seen,
+			ARRAY(SELECT DISTINCT source FROM job_sources WHERE job_sources.job_id = jobs.id ORDER BY source)
+		FROM jobs
+		WHERE jobs.company_id = $1 AND jobs.subscription_id IN (0, $2) AND jobs.last_seen >= $3
+		ORDER BY jobs.first_seen DESC, jobs.id`, companyID, subscriptionID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var j Job
+		if err := rows.Scan(&j.ID, &j.CompanyID, &j.SubscriptionID, &j.DedupKey, &j.Source, &j.ExternalID, &j.CanonicalURL,
+			&j.Title, &j.Location, &j.PostedAt, &j.FirstSeen, &j.LastSeen, pq.Array(&j.Sources)); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// PurgeJobs deletes the postings no source has listed since cutoff and those of subscriptions
+// that are gone, with their source links.
+func PurgeJobs(cutoff time.Time) (int64, error) {
+	res, err := db.DB.Exec(`
+		DELETE FROM jobs
+		WHERE last_seen < $1
+		OR (subscription_id <> 0 AND NOT EXISTS (SELECT 1 FROM subscriptions WHERE subscriptions.id = jobs.subscription_id))`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
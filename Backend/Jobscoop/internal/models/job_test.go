@@ -0,0 +1,114 @@
+package models
+
+import (
+	"JobScoop/internal/db"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var jobColumns = []string{"id", "company_id", "subscription_id", "dedup_key", "source", "external_id", "canonical_url", "title", "location", "posted_at", "first_seen", "last_seen"}
+
+func TestSaveJobKeepsOneSourcesPostingsApart(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	seen := time.Now().UTC()
+	first := Job{CompanyID: 7, DedupKey: "backend engineer|berlin", Source: "greenhouse", ExternalID: "4", Title: "Backend Engineer", Location: "Berlin"}
+	second := first
+	second.ExternalID = "5"
+
+	// Neither requisition was listed before and no other source found the posting, so each
+	// gets its own row: the first one's row is already linked to greenhouse
+	for i, job := range []Job{first, second} {
+		id := i + 1
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT job_id FROM job_sources WHERE subscription_id = \\$1 AND source = \\$2 AND external_id = \\$3").
+			WithArgs(0, "greenhouse", job.ExternalID).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectExec("SELECT pg_advisory_xact_lock\\(\\$1, hashtext\\(\\$2\\)\\)").
+			WithArgs(7, "backend engineer|berlin").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT id FROM jobs\\s+WHERE company_id = \\$1 AND subscription_id = \\$2 AND dedup_key = \\$3\\s+AND NOT EXISTS \\(SELECT 1 FROM job_sources WHERE job_sources.job_id = jobs.id AND job_sources.source = \\$4\\)").
+			WithArgs(7, 0, "backend engineer|berlin", "greenhouse").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectQuery("INSERT INTO jobs \\(company_id, subscription_id, dedup_key, source, external_id").
+			WithArgs(7, 0, "backend engineer|berlin", "greenhouse", job.ExternalID, "", "Backend Engineer", "Berlin", nil, seen).
+			WillReturnRows(sqlmock.NewRows(jobColumns).
+				AddRow(id, 7, 0, "backend engineer|berlin", "greenhouse", job.ExternalID, "", "Backend Engineer", "Berlin", nil, seen, seen))
+		mock.ExpectExec("INSERT INTO job_sources \\(subscription_id, source, external_id, job_id, url, first_seen, last_seen\\)").
+			WithArgs(0, "greenhouse", job.ExternalID, id, "", seen).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT ARRAY\\(SELECT DISTINCT source FROM job_sources WHERE job_id = \\$1").
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"sources"}).AddRow("{greenhouse}"))
+		mock.ExpectCommit()
+	}
+
+	var ids []int
+	for _, job := range []Job{first, second} {
+		stored, err := SaveJob(job, false, seen)
+		if err != nil {
+			t.Fatalf("SaveJob(%s) returned error: %v", job.ExternalID, err)
+		}
+		ids = append(ids, stored.ID)
+	}
+	if ids[0] == ids[1] || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("Expected two rows, got ids %v", ids)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestSaveJobMergesAnotherSourcesPosting(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+
+	seen := time.Now().UTC()
+	job := Job{CompanyID: 7, DedupKey: "backend engineer|berlin", Source: "linkedin", ExternalID: "991", Title: "Backend Engineer", Location: "Berlin"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT job_id FROM job_sources").
+		WithArgs(0, "linkedin", "991").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").
+		WithArgs(7, "backend engineer|berlin").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id FROM jobs").
+		WithArgs(7, 0, "backend engineer|berlin", "linkedin").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	// The board's listing joins the row greenhouse found, which keeps its source and link
+	mock.ExpectQuery("UPDATE jobs SET\\s+source = CASE WHEN \\$2 THEN jobs.source ELSE \\$3 END").
+		WithArgs(1, true, "linkedin", "991", "", nil, seen).
+		WillReturnRows(sqlmock.NewRows(jobColumns).
+			AddRow(1, 7, 0, "backend engineer|berlin", "greenhouse", "4", "https://boards.greenhouse.io/acme/jobs/4", "Backend Engineer", "Berlin", nil, seen, seen))
+	mock.ExpectExec("INSERT INTO job_sources").
+		WithArgs(0, "linkedin", "991", 1, "", seen).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT ARRAY\\(SELECT DISTINCT source FROM job_sources").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"sources"}).AddRow("{greenhouse,linkedin}"))
+	mock.ExpectCommit()
+
+	stored, err := SaveJob(job, true, seen)
+	if err != nil {
+		t.Fatalf("SaveJob returned error: %v", err)
+	}
+	if stored.ID != 1 || stored.Source != "greenhouse" || len(stored.Sources) != 2 {
+		t.Errorf("Expected the greenhouse posting linked to both sources, got %+v", stored)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"JobScoop/internal/db"
+	"log"
+)
+
+// CreateLoginAttemptsTable creates the login_attempts table if it does not exist.
+// user_id is NULL for attempts against addresses that have no account.
+func CreateLoginAttemptsTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		id SERIAL PRIMARY KEY,
+		user_id INT,
+		email VARCHAR(100) NOT NULL,
+		ip TEXT NOT NULL,
+		user_agent TEXT NOT NULL DEFAULT '',
+		success BOOLEAN NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+		CONSTRAINT fk_login_attempt_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_login_attempts_user ON login_attempts (user_id, created_at DESC);
+	`
+
+	_, err := db.DB.Exec(query)
+	if err != nil {
+		log.Fatalf("Error creating login_attempts table: %v", err)
+	}
+}
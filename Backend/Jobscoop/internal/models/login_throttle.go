@@ -0,0 +1,24 @@
+package models
+
+import (
+	"JobScoop/internal/db"
+	"log"
+)
+
+// CreateLoginThrottlesTable creates the login_throttles table if it does not exist.
+// Each row counts recent failed logins for one key, either "email:<address>" or "ip:<address>".
+func CreateLoginThrottlesTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS login_throttles (
+		key TEXT PRIMARY KEY,
+		failures INT NOT NULL DEFAULT 0,
+		last_failure_at TIMESTAMP NOT NULL,
+		locked_until TIMESTAMP
+	);
+	`
+
+	_, err := db.DB.Exec(query)
+	if err != nil {
+		log.Fatalf("Error creating login_throttles table: %v", err)
+	}
+}
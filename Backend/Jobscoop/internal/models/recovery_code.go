@@ -0,0 +1,28 @@
+package models
+
+import (
+	"JobScoop/internal/db"
+	"log"
+)
+
+// CreateRecoveryCodesTable creates the recovery_codes table if it does not exist.
+// Each row is one single-use two-factor recovery code, stored as a SHA-256 hash.
+func CreateRecoveryCodesTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS recovery_codes (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		code_hash TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		used_at TIMESTAMP,
+
+		CONSTRAINT fk_recovery_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		CONSTRAINT unique_recovery_code UNIQUE (user_id, code_hash)
+	);
+	`
+
+	_, err := db.DB.Exec(query)
+	if err != nil {
+		log.Fatalf("Error creating recovery_codes table: %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"JobScoop/internal/db"
+	"log"
+)
+
+// CreateRefreshTokensTable creates the refresh_tokens table if it does not exist.
+// Only a SHA-256 hash of each token is stored. Tokens issued by rotating one another
+// share a family_id so that the whole chain can be revoked when reuse is detected.
+func CreateRefreshTokensTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		family_id TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		rotated_at TIMESTAMP,
+		revoked_at TIMESTAMP,
+
+		CONSTRAINT fk_refresh_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens (family_id);
+	`
+
+	_, err := db.DB.Exec(query)
+	if err != nil {
+		log.Fatalf("Error creating refresh_tokens table: %v", err)
+	}
+}
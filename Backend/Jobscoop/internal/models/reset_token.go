@@ -14,6 +14,15 @@ func CreateResetTokensTable() {
 		token TEXT NOT NULL UNIQUE,
 		expires_at TIMESTAMP NOT NULL
 	);
+	ALTER TABLE reset_tokens ADD COLUMN IF NOT EXISTS failed_attempts INT NOT NULL DEFAULT 0;
+	ALTER TABLE reset_tokens ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+	ALTER TABLE reset_tokens ADD COLUMN IF NOT EXISTS grant_hash TEXT;
+	ALTER TABLE reset_tokens ADD COLUMN IF NOT EXISTS grant_expires_at TIMESTAMP;
+
+	-- token now holds a bcrypt hash and is cleared once the code is used
+	ALTER TABLE reset_tokens ALTER COLUMN token DROP NOT NULL;
+	ALTER TABLE reset_tokens DROP CONSTRAINT IF EXISTS reset_tokens_token_key;
+	DELETE FROM reset_tokens WHERE token IS NOT NULL AND token NOT LIKE '$2%';
 	`
 
 	_, err := db.DB.Exec(query)
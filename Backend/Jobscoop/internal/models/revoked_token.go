@@ -0,0 +1,32 @@
+package models
+
+import (
+	"JobScoop/internal/db"
+	"log"
+	"time"
+)
+
+// CreateRevokedTokensTable creates the revoked_tokens table if it does not exist.
+// It holds the jti of access tokens that were logged out before they expired.
+func CreateRevokedTokensTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti TEXT PRIMARY KEY,
+		expires_at TIMESTAMP NOT NULL
+	);
+	`
+
+	_, err := db.DB.Exec(query)
+	if err != nil {
+		log.Fatalf("Error creating revoked_tokens table: %v", err)
+	}
+}
+
+// PurgeRevokedTokens forgets the revoked tokens that expired before now, they are refused anyway.
+func PurgeRevokedTokens(now time.Time) (int64, error) {
+	res, err := db.DB.Exec(`DELETE FROM revoked_tokens WHERE expires_at < $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
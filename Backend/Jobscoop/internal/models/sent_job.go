@@ -0,0 +1,57 @@
+package models
+
+import (
+	"JobScoop/internal/db"
+	"log"
+	"time"
+)
+
+// CreateSentJobsTable creates the sent_jobs table if it does not exist.
+// It remembers which stored postings were already mailed to a user so digests only carry new ones.
+// A posting's source and id change when the company's own ATS takes it over from a board, so it
+// is remembered by its jobs row; rows from before are moved over from the source and id they
+// were kept under, and dropped when no stored posting has those any more.
+func CreateSentJobsTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS sent_jobs (
+		user_id INT NOT NULL,
+		job_id INT NOT NULL,
+		sent_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+		PRIMARY KEY (user_id, job_id),
+		CONSTRAINT fk_sent_job_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		CONSTRAINT fk_sent_job_job FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+	);
+	DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'sent_jobs' AND column_name = 'job_key') THEN
+			ALTER TABLE sent_jobs ADD COLUMN IF NOT EXISTS job_id INT;
+			UPDATE sent_jobs SET job_id = job_sources.job_id FROM job_sources
+			WHERE sent_jobs.job_key IN (job_sources.source || ':' || job_sources.external_id, job_sources.url);
+			DELETE FROM sent_jobs WHERE job_id IS NULL;
+			DELETE FROM sent_jobs a USING sent_jobs b
+			WHERE a.user_id = b.user_id AND a.job_id = b.job_id AND a.job_key > b.job_key;
+			ALTER TABLE sent_jobs DROP CONSTRAINT sent_jobs_pkey;
+			ALTER TABLE sent_jobs DROP COLUMN job_key;
+			ALTER TABLE sent_jobs ALTER COLUMN job_id SET NOT NULL;
+			ALTER TABLE sent_jobs ADD PRIMARY KEY (user_id, job_id);
+			ALTER TABLE sent_jobs ADD CONSTRAINT fk_sent_job_job FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE;
+		END IF;
+	END $$;
+	CREATE INDEX IF NOT EXISTS idx_sent_jobs_sent_at ON sent_jobs (sent_at);
+	`
+
+	_, err := db.DB.Exec(query)
+	if err != nil {
+		log.Fatalf("Error creating sent_jobs table: %v", err)
+	}
+}
+
+// PurgeSentJobs forgets postings mailed before cutoff, long after they dropped out of the searches.
+func PurgeSentJobs(cutoff time.Time) (int64, error) {
+	res, err := db.DB.Exec(`DELETE FROM sent_jobs WHERE sent_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -21,6 +21,7 @@ func CreateSubscriptionTable() {
 	    CONSTRAINT fk_company FOREIGN KEY (Company_Id) REFERENCES Companies(Id) ON DELETE CASCADE,
 		CONSTRAINT unique_user_company UNIQUE (User_Id, Company_Id)
 	);
+	ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS feed_links TEXT[] NOT NULL DEFAULT '{}';
 	`
 
 	_, err := db.DB.Exec(query)
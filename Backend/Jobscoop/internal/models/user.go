@@ -3,6 +3,12 @@ package models
 import (
 	"JobScoop/internal/db"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 // CreateUserTable creates the users table in the database if it doesn't exist.
@@ -15,6 +21,19 @@ func CreateUserTable() {
 		password VARCHAR(255) NOT NULL,
 		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 	);
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS sessions_revoked_at TIMESTAMP WITH TIME ZONE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS session_generation INT NOT NULL DEFAULT 0;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS email_status VARCHAR(20) NOT NULL DEFAULT 'verified';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS verification_sent_at TIMESTAMP;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP WITH TIME ZONE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email VARCHAR(100);
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS digest_frequency VARCHAR(10) NOT NULL DEFAULT 'daily';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS last_digest_at TIMESTAMP WITH TIME ZONE;
 	`
 
 	_, err := db.DB.Exec(query)
@@ -22,3 +41,55 @@ func CreateUserTable() {
 		log.Fatal("Failed to create users table:", err)
 	}
 }
+
+// PromoteAdminsFromEnv grants the admin role to the comma separated addresses in ADMIN_EMAILS.
+// It bootstraps the first admins; later ones can be promoted through the admin API.
+func PromoteAdminsFromEnv() {
+	var emails []string
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	if len(emails) == 0 {
+		return
+	}
+
+	_, err := db.DB.Exec(`UPDATE users SET is_admin = TRUE WHERE email = ANY($1)`, pq.Array(emails))
+	if err != nil {
+		log.Fatal("Failed to promote admins:", err)
+	}
+}
+
+// defaultDeletionGraceDays is how long a deleted account can still be restored by an admin
+const defaultDeletionGraceDays = 30
+
+// AccountDeletionGrace returns the time between a user deleting their account and its purge,
+// ACCOUNT_DELETION_GRACE_DAYS days or 30 by default.
+func AccountDeletionGrace() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"))
+	if err != nil || days < 0 {
+		days = defaultDeletionGraceDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// PurgeDeletedUsers permanently removes the accounts deleted before cutoff. Rows that reference
+// the user by id cascade; the ones keyed by email address are removed here.
+func PurgeDeletedUsers(cutoff time.Time) (int, error) {
+	var purged int
+	err := db.DB.QueryRow(`
+		WITH purged AS (
+			DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1 RETURNING email
+		), reset AS (
+			DELETE FROM reset_tokens WHERE email IN (SELECT email FROM purged)
+		), attempts AS (
+			DELETE FROM login_attempts WHERE email IN (SELECT email FROM purged)
+		), throttles AS (
+			DELETE FROM login_throttles WHERE key IN (SELECT 'email:' || lower(email) FROM purged)
+		), outbox AS (
+			DELETE FROM email_outbox WHERE recipients && ARRAY(SELECT email::TEXT FROM purged)
+		)
+		SELECT COUNT(*) FROM purged`, cutoff).Scan(&purged)
+	return purged, err
+}
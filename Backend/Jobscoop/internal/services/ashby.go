@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AshbyAPI is the public Ashby job board API.
+const AshbyAPI = "https://api.ashbyhq.com"
+
+func init() {
+	RegisterJobSource("ashby", func() (JobSource, error) {
+		return &AshbySource{}, nil
+	})
+}
+
+// AshbySource lists the postings on the Ashby job boards among a query's career sites.
+// BaseURL replaces the API host, in tests for example.
+type AshbySource struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// ashbyBoard is the response of GET /posting-api/job-board/{board}
+type ashbyBoard struct {
+	Jobs []struct {
+		ID                 string `json:"id"`
+		Title              string `json:"title"`
+		Location           string `json:"location"`
+		SecondaryLocations []struct {
+			Location string `json:"location"`
+		} `json:"secondaryLocations"`
+		Department     string `json:"department"`
+		Team           string `json:"team"`
+		EmploymentType string `json:"employmentType"`
+		IsRemote       bool   `json:"isRemote"`
+		WorkplaceType  string `json:"workplaceType"`
+		IsListed       *bool  `json:"isListed"`
+		JobURL         string `json:"jobUrl"`
+		PublishedAt    string `json:"publishedAt"`
+	} `json:"jobs"`
+}
+
+// ashbyEmploymentTypes words Ashby's employment types the way the other boards do
+var ashbyEmploymentTypes = map[string]string{
+	"FullTime":  "Full-time",
+	"PartTime":  "Part-time",
+	"Intern":    "Internship",
+	"Contract":  "Contract",
+	"Temporary": "Temporary",
+}
+
+// AshbyBoard returns the board name of an Ashby job board link such as https://jobs.ashbyhq.com/acme.
+func AshbyBoard(link string) (string, bool) {
+	u, ok := parseCareerLink(link)
+	if !ok || !strings.EqualFold(u.Hostname(), "jobs.ashbyhq.com") {
+		return "", false
+	}
+	board := strings.Split(strings.Trim(u.Path, "/"), "/")[0]
+	return board, board != ""
+}
+
+// Name implements JobSource.
+func (s *AshbySource) Name() string { return "ashby" }
+
+// Search implements JobSource. Queries without an Ashby career site have nothing to search.
+func (s *AshbySource) Search(ctx context.Context, q JobQuery) ([]Job, error) {
+	base := s.BaseURL
+	if base == "" {
+		base = AshbyAPI
+	}
+
+	var jobs []Job
+	seen := map[string]bool{}
+	for _, link := range q.CareerSites {
+		board, ok := AshbyBoard(link)
+		if !ok || seen[board] {
+			continue
+		}
+		seen[board] = true
+
+		var resp ashbyBoard
+		endpoint := base + "/posting-api/job-board/" + url.PathEscape(board) + "?includeCompensation=false"
+		if err := getJSON(ctx, s.HTTPClient, endpoint, &resp); err != nil {
+			return nil, err
+		}
+		for _, p := range resp.Jobs {
+			if p.IsListed != nil && !*p.IsListed {
+				continue
+			}
+			job := Job{
+				Source:     s.Name(),
+				ID:         p.ID,
+				Title:      p.Title,
+				Company:    q.Company,
+				Location:   p.Location,
+				Commitment: ashbyEmploymentTypes[p.EmploymentType],
+				URL:        p.JobURL,
+			}
+			if job.Commitment == "" {
+				job.Commitment = p.EmploymentType
+			}
+			for _, l := range p.SecondaryLocations {
+				if l.Location != "" {
+					job.Location += "; " + l.Location
+				}
+			}
+			for _, name := range []string{p.Department, p.Team} {
+				if name != "" && (len(job.Departments) == 0 || job.Departments[0] != name) {
+					job.Departments = append(job.Departments, name)
+				}
+			}
+			switch {
+			case p.WorkplaceType != "":
+				job.WorkplaceType = strings.ToLower(p.WorkplaceType)
+			case p.IsRemote:
+				job.WorkplaceType = "remote"
+			}
+			if published, err := time.Parse(time.RFC3339, p.PublishedAt); err == nil {
+				job.PostedAt = &published
+			}
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
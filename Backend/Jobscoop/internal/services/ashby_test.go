@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAshbyBoard(t *testing.T) {
+	tests := []struct {
+		link  string
+		board string
+		ok    bool
+	}{
+		{"https://jobs.ashbyhq.com/acme", "acme", true},
+		{"https://jobs.ashbyhq.com/acme/3f6b2a8c-1d4e-4b7a-9c2f-8e5d1a0b6c71", "acme", true},
+		{"jobs.ashbyhq.com/acme/", "acme", true},
+		{"https://jobs.ashbyhq.com/", "", false},
+		{"https://jobs.lever.co/acme", "", false},
+	}
+	for _, tt := range tests {
+		board, ok := AshbyBoard(tt.link)
+		if board != tt.board || ok != tt.ok {
+			t.Errorf("AshbyBoard(%q) = %q, %v; want %q, %v", tt.link, board, ok, tt.board, tt.ok)
+		}
+	}
+}
+
+func TestAshbySourceSearch(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		if r.URL.Path != "/posting-api/job-board/acme" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, "testdata/ashby_job_board.json")
+	}))
+	defer server.Close()
+
+	source := &AshbySource{BaseURL: server.URL}
+	jobs, err := source.Search(context.Background(), JobQuery{
+		Company:     "Acme",
+		Role:        "Engineer",
+		CareerSites: []string{"https://jobs.ashbyhq.com/acme", "https://acme.com/careers", "jobs.ashbyhq.com/acme/9a0c4e2d-7b1f-4c3a-8d6e-5f2b1a9c0d34"},
+	})
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(requests) != 1 || requests[0] != "/posting-api/job-board/acme?includeCompensation=false" {
+		t.Errorf("Expected one request for the acme board, got %v", requests)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("Expected the listed postings only, got %d", len(jobs))
+	}
+
+	first := jobs[0]
+	if first.Source != "ashby" || first.ID != "3f6b2a8c-1d4e-4b7a-9c2f-8e5d1a0b6c71" || first.Title != "Staff Software Engineer" ||
+		first.Company != "Acme" || first.Location != "New York, NY; Remote (US)" || first.Commitment != "Full-time" || first.WorkplaceType != "hybrid" ||
+		first.URL != "https://jobs.ashbyhq.com/acme/3f6b2a8c-1d4e-4b7a-9c2f-8e5d1a0b6c71" {
+		t.Errorf("Unexpected job: %+v", first)
+	}
+	if len(first.Departments) != 2 || first.Departments[0] != "Engineering" || first.Departments[1] != "Payments" {
+		t.Errorf("Expected the department and team, got %v", first.Departments)
+	}
+	if first.PostedAt == nil || first.PostedAt.UTC().Format("2006-01-02") != "2024-05-02" {
+		t.Errorf("Unexpected posting date: %v", first.PostedAt)
+	}
+
+	second := jobs[1]
+	if second.Commitment != "Internship" || second.WorkplaceType != "remote" || len(second.Departments) != 1 {
+		t.Errorf("Unexpected job: %+v", second)
+	}
+}
+
+func TestAshbySourceSkipsOtherSites(t *testing.T) {
+	source := &AshbySource{BaseURL: "http://127.0.0.1:0"}
+	jobs, err := source.Search(context.Background(), JobQuery{Company: "Acme", CareerSites: []string{"https://acme.com/careers"}})
+	if err != nil || jobs != nil {
+		t.Errorf("Expected nothing to search, got %v, %v", jobs, err)
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// EmailVerificationPurpose is the audience of tokens mailed to confirm an address.
+const EmailVerificationPurpose = "email-verification"
+
+// EmailChangePurpose is the audience of tokens mailed to confirm a new address for an account.
+const EmailChangePurpose = "email-change"
+
+// DigestUnsubscribePurpose is the audience of the unsubscribe links in job alert digests.
+const DigestUnsubscribePurpose = "digest-unsubscribe"
+
+// EmailClaims bind a mailed link to one user, one address and one purpose.
+type EmailClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// IssueEmailToken signs a token proving control of email for the given purpose.
+func IssueEmailToken(userID int, email, purpose string, ttl time.Duration) (string, error) {
+	jti, err := NewTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return SignClaims(&EmailClaims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.Itoa(userID),
+			Audience:  jwt.ClaimStrings{purpose},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			Issuer:    Issuer,
+		},
+	})
+}
+
+// ParseEmailToken verifies a token from IssueEmailToken and returns the user ID and address it was issued for.
+func ParseEmailToken(tokenString, purpose string) (int, string, error) {
+	claims := &EmailClaims{}
+	if err := ParseClaims(tokenString, claims); err != nil {
+		return 0, "", err
+	}
+	if !claims.VerifyIssuer(Issuer, true) || !claims.VerifyAudience(purpose, true) || claims.ExpiresAt == nil || claims.Email == "" {
+		return 0, "", errors.New("invalid token claims")
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil || userID == 0 {
+		return 0, "", errors.New("invalid token subject")
+	}
+	return userID, claims.Email, nil
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxFeedSize bounds how much of a feed is read
+const maxFeedSize = 10 << 20
+
+// defaultFeedCacheSize is how many feeds a FeedSource keeps the entries of
+const defaultFeedCacheSize = 1000
+
+func init() {
+	RegisterJobSource("feed", func() (JobSource, error) {
+		return NewFeedSource(), nil
+	})
+}
+
+// FeedSource reads the RSS 2.0 and Atom feeds attached to a subscription. Feeds are fetched
+// with conditional requests, an unchanged feed is answered from the entries read last time.
+// Entries are kept when they mention the subscription's company and their title its role.
+type FeedSource struct {
+	HTTPClient *http.Client
+	// CacheSize is how many feeds' entries are kept to answer unchanged feeds from; users add
+	// feeds, so the least recently fetched is dropped once it is full
+	CacheSize int
+
+	mu    sync.Mutex
+	cache map[string]feedCacheEntry
+}
+
+// feedCacheEntry is what was read from a feed, with the validators to ask whether it changed
+type feedCacheEntry struct {
+	etag         string
+	lastModified string
+	entries      []feedEntry
+	fetchedAt    time.Time
+}
+
+// feedEntry is an RSS item or Atom entry
+type feedEntry struct {
+	id          string
+	title       string
+	link        string
+	author      string
+	categories  []string
+	description string
+	published   string
+	updated     string
+}
+
+// NewFeedSource returns a FeedSource with an empty cache.
+func NewFeedSource() *FeedSource {
+	return &FeedSource{CacheSize: defaultFeedCacheSize, cache: map[string]feedCacheEntry{}}
+}
+
+// Name implements JobSource.
+func (s *FeedSource) Name() string { return "feed" }
+
+// Search implements JobSource. Queries without feeds have nothing to search.
+func (s *FeedSource) Search(ctx context.Context, q JobQuery) ([]Job, error) {
+	var jobs []Job
+	seen := map[string]bool{}
+	for _, link := range q.Feeds {
+		if seen[link] {
+			continue
+		}
+		seen[link] = true
+
+		entries, err := s.entries(ctx, link)
+		if err != nil {
+			return nil, fmt.Errorf("feed %s: %w", link, err)
+		}
+		for _, e := range entries {
+			if job := e.job(s.Name(), q.Company); MatchesQuery(job, q) {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	return jobs, nil
+}
+
+// entries fetches a feed unless the server says it has not changed since the last time
+func (s *FeedSource) entries(ctx context.Context, link string) ([]feedEntry, error) {
+	s.mu.Lock()
+	if s.cache == nil {
+		s.cache = map[string]feedCacheEntry{}
+	}
+	cached, ok := s.cache[link]
+	s.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.8")
+	req.Header.Set("User-Agent", scraperUserAgent)
+	if ok && cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	if ok && cached.lastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.lastModified)
+	}
+
+	resp, err := sourceClient(s.HTTPClient).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && ok {
+		s.mu.Lock()
+		if _, still := s.cache[link]; still {
+			cached.fetchedAt = time.Now()
+			s.cache[link] = cached
+		}
+		s.mu.Unlock()
+		return cached.entries, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	entries, err := parseFeed(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, err
+	}
+	entry := feedCacheEntry{etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified"), entries: entries, fetchedAt: time.Now()}
+	s.mu.Lock()
+	if entry.etag != "" || entry.lastModified != "" {
+		if _, ok := s.cache[link]; !ok {
+			s.makeRoom()
+		}
+		s.cache[link] = entry
+	} else {
+		// Nothing to revalidate with, the feed is fetched in full every time
+		delete(s.cache, link)
+	}
+	s.mu.Unlock()
+	return entries, nil
+}
+
+// makeRoom drops the least recently fetched feeds until another fits in the cache. s.mu is held.
+func (s *FeedSource) makeRoom() {
+	size := s.CacheSize
+	if size <= 0 {
+		size = defaultFeedCacheSize
+	}
+	for len(s.cache) >= size {
+		var oldest string
+		for link, entry := range s.cache {
+			if oldest == "" || entry.fetchedAt.Before(s.cache[oldest].fetchedAt) {
+				oldest = link
+			}
+		}
+		delete(s.cache, oldest)
+	}
+}
+
+// rssFeed is an RSS 2.0 document
+type rssFeed struct {
+	Items []struct {
+		Title       string   `xml:"title"`
+		Link        string   `xml:"link"`
+		GUID        string   `xml:"guid"`
+		PubDate     string   `xml:"pubDate"`
+		Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+		Author      string   `xml:"author"`
+		Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+		Categories  []string `xml:"category"`
+		Description string   `xml:"description"`
+	} `xml:"channel>item"`
+}
+
+// atomFeed is an Atom 1.0 document
+type atomFeed struct {
+	Entries []struct {
+		ID    string `xml:"id"`
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Published  string `xml:"published"`
+		Updated    string `xml:"updated"`
+		AuthorName string `xml:"author>name"`
+		Categories []struct {
+			Term  string `xml:"term,attr"`
+			Label string `xml:"label,attr"`
+		} `xml:"category"`
+		Summary string `xml:"summary"`
+		Content string `xml:"content"`
+	} `xml:"entry"`
+}
+
+// parseFeed reads an RSS 2.0 or Atom feed, telling them apart by their root element
+func parseFeed(r io.Reader) ([]feedEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := feedRoot(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []feedEntry
+	switch root {
+	case "rss":
+		var feed rssFeed
+		if err := decodeFeed(data, &feed); err != nil {
+			return nil, err
+		}
+		for _, item := range feed.Items {
+			e := feedEntry{
+				id:          strings.TrimSpace(item.GUID),
+				title:       item.Title,
+				link:        strings.TrimSpace(item.Link),
+				author:      firstNonEmpty(item.Creator, item.Author),
+				categories:  item.Categories,
+				description: item.Description,
+				published:   firstNonEmpty(item.PubDate, item.Date),
+			}
+			entries = append(entries, e)
+		}
+	case "feed":
+		var feed atomFeed
+		if err := decodeFeed(data, &feed); err != nil {
+			return nil, err
+		}
+		for _, entry := range feed.Entries {
+			e := feedEntry{
+				id:          strings.TrimSpace(entry.ID),
+				title:       entry.Title,
+				author:      entry.AuthorName,
+				description: firstNonEmpty(entry.Summary, entry.Content),
+				published:   entry.Published,
+				updated:     entry.Updated,
+			}
+			for _, l := range entry.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					e.link = strings.TrimSpace(l.Href)
+					break
+				}
+			}
+			for _, c := range entry.Categories {
+				e.categories = append(e.categories, firstNonEmpty(c.Label, c.Term))
+			}
+			entries = append(entries, e)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported feed format <%s>", root)
+	}
+	return entries, nil
+}
+
+// feedRoot returns the name of the document's root element
+func feedRoot(data []byte) (string, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.CharsetReader = feedCharsetReader
+	d.Strict = false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return "", errors.New("empty feed")
+		}
+		if err != nil {
+			return "", err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+func decodeFeed(data []byte, v interface{}) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.CharsetReader = feedCharsetReader
+	// Feeds in the wild use HTML entities such as &nbsp; and the odd unescaped ampersand
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	return d.Decode(v)
+}
+
+// feedCharsetReader accepts the Latin-1 feeds still around besides UTF-8 ones
+func feedCharsetReader(label string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(label) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "latin1", "latin-1", "windows-1252":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		for _, b := range data {
+			buf.WriteRune(rune(b))
+		}
+		return &buf, nil
+	}
+	return nil, fmt.Errorf("unsupported feed charset %q", label)
+}
+
+var feedTags = regexp.MustCompile(`<[^>]*>`)
+
+// job maps an entry, crediting it to company when the entry mentions it anywhere, and to its
+// author otherwise
+func (e feedEntry) job(source, company string) Job {
+	description := html.UnescapeString(feedTags.ReplaceAllString(e.description, " "))
+	job := Job{
+		Source:    source,
+		ID:        e.id,
+		Title:     collapseSpace(html.UnescapeString(feedTags.ReplaceAllString(e.title, " "))),
+		Company:   collapseSpace(e.author),
+		URL:       e.link,
+		PostedAt:  parseDate(e.published, ""),
+		UpdatedAt: parseDate(e.updated, ""),
+	}
+	if job.PostedAt == nil {
+		job.PostedAt = job.UpdatedAt
+	}
+
+	mentions := strings.ToLower(strings.Join(append([]string{job.Title, job.Company, description}, e.categories...), " "))
+	if company != "" && strings.Contains(mentions, strings.ToLower(company)) {
+		job.Company = company
+	}
+	return job
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
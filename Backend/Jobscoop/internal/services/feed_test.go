@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFeedSourceSearchRSS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/jobs_feed.rss")
+	}))
+	defer server.Close()
+
+	source := NewFeedSource()
+	jobs, err := source.Search(context.Background(), JobQuery{Company: "Acme", Role: "Backend Engineer", Feeds: []string{server.URL + "/feed.rss"}})
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("Expected the two Acme backend postings, got %+v", jobs)
+	}
+
+	first := jobs[0]
+	if first.Source != "feed" || first.ID != "rj-4411" || first.Title != "Acme: Senior Backend Engineer" || first.Company != "Acme" ||
+		first.URL != "https://remotejobs.example/jobs/4411-acme-senior-backend-engineer" {
+		t.Errorf("Unexpected job: %+v", first)
+	}
+	if first.PostedAt == nil || first.PostedAt.Format("2006-01-02 15:04") != "2024-05-07 09:15" {
+		t.Errorf("Unexpected posting date: %v", first.PostedAt)
+	}
+
+	// Credited to the company through its author
+	second := jobs[1]
+	if second.Title != "Backend Engineer & SRE" || second.Company != "Acme" || second.PostedAt == nil {
+		t.Errorf("Unexpected job: %+v", second)
+	}
+}
+
+func TestFeedSourceSearchAtom(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/jobs_feed.atom")
+	}))
+	defer server.Close()
+
+	source := NewFeedSource()
+	jobs, err := source.Search(context.Background(), JobQuery{Company: "Acme", Role: "Engineer", Feeds: []string{server.URL + "/feed.atom"}})
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("Expected the engineering posting, got %+v", jobs)
+	}
+	job := jobs[0]
+	if job.ID != "https://acme.example/careers/backend-engineer-payments" || job.Title != "Backend Engineer, Payments" ||
+		job.URL != "https://acme.example/careers/backend-engineer-payments" || job.Company != "Acme" {
+		t.Errorf("Unexpected job: %+v", job)
+	}
+	if job.PostedAt == nil || job.UpdatedAt == nil || !job.PostedAt.Before(*job.UpdatedAt) {
+		t.Errorf("Unexpected dates: %v, %v", job.PostedAt, job.UpdatedAt)
+	}
+}
+
+func TestFeedSourceConditionalGet(t *testing.T) {
+	feed, err := os.ReadFile("testdata/jobs_feed.rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var full, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` && r.Header.Get("If-Modified-Since") == "Wed, 08 May 2024 10:00:00 GMT" {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Wed, 08 May 2024 10:00:00 GMT")
+		w.Write(feed)
+	}))
+	defer server.Close()
+
+	source := NewFeedSource()
+	q := JobQuery{Company: "Acme", Role: "Backend Engineer", Feeds: []string{server.URL + "/feed.rss"}}
+	for i := 0; i < 3; i++ {
+		jobs, err := source.Search(context.Background(), q)
+		if err != nil || len(jobs) != 2 {
+			t.Fatalf("Search %d = %v, %v; want the two Acme backend postings", i, jobs, err)
+		}
+	}
+	if full != 1 || notModified != 2 {
+		t.Errorf("Expected one full fetch and two revalidations, got %d and %d", full, notModified)
+	}
+
+	// Another company's search is answered from the same cached entries
+	jobs, err := source.Search(context.Background(), JobQuery{Company: "Globex", Role: "Backend Engineer", Feeds: q.Feeds})
+	if err != nil || len(jobs) != 1 || jobs[0].Company != "Globex" || full != 1 {
+		t.Errorf("Expected the Globex posting from the cache, got %v, %v after %d fetches", jobs, err, full)
+	}
+}
+
+func TestFeedSourceCacheSize(t *testing.T) {
+	feed, err := os.ReadFile("testdata/jobs_feed.rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full[r.URL.Path]++
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(feed)
+	}))
+	defer server.Close()
+
+	source := NewFeedSource()
+	source.CacheSize = 2
+	for _, path := range []string{"/a.rss", "/b.rss", "/a.rss", "/c.rss", "/a.rss", "/b.rss"} {
+		q := JobQuery{Company: "Acme", Role: "Backend Engineer", Feeds: []string{server.URL + path}}
+		if _, err := source.Search(context.Background(), q); err != nil {
+			t.Fatalf("Search %s returned error: %v", path, err)
+		}
+	}
+	if len(source.cache) != 2 {
+		t.Errorf("Expected the cache to hold two feeds, got %d", len(source.cache))
+	}
+	// b was the least recently fetched when c came, so it had to be fetched again
+	if full["/a.rss"] != 1 || full["/b.rss"] != 2 || full["/c.rss"] != 1 {
+		t.Errorf("Unexpected full fetches: %v", full)
+	}
+}
+
+func TestParseFeedErrors(t *testing.T) {
+	tests := []struct {
+		feed string
+		want string
+	}{
+		{`<html><body>Not a feed</body></html>`, "unsupported feed format"},
+		{``, "empty feed"},
+		{`<?xml version="1.0" encoding="koi8-r"?><rss></rss>`, "charset"},
+	}
+	for _, tt := range tests {
+		if _, err := parseFeed(strings.NewReader(tt.feed)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseFeed(%q) = %v; want an error about %s", tt.feed, err, tt.want)
+		}
+	}
+
+	entries, err := parseFeed(strings.NewReader(`<?xml version="1.0" encoding="ISO-8859-1"?><rss><channel><item><title>Ingeni` + "\xe9" + `ur</title></item></channel></rss>`))
+	if err != nil || len(entries) != 1 || entries[0].title != "Ingeniéur" {
+		t.Errorf("Expected a Latin-1 feed to be read, got %+v, %v", entries, err)
+	}
+}
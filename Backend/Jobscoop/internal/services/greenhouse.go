@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GreenhouseAPI is the public Greenhouse job board API.
+const GreenhouseAPI = "https://boards-api.greenhouse.io"
+
+func init() {
+	RegisterJobSource("greenhouse", func() (JobSource, error) {
+		return &GreenhouseSource{}, nil
+	})
+}
+
+// GreenhouseSource lists the postings on the Greenhouse job boards among a query's career sites.
+// The board API is public, so it needs no settings.
+type GreenhouseSource struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// greenhouseJobs is the response of GET /v1/boards/{token}/jobs
+type greenhouseJobs struct {
+	Jobs []struct {
+		ID       int64  `json:"id"`
+		Title    string `json:"title"`
+		Location struct {
+			Name string `json:"name"`
+		} `json:"location"`
+		Departments []struct {
+			Name string `json:"name"`
+		} `json:"departments"`
+		UpdatedAt   string `json:"updated_at"`
+		AbsoluteURL string `json:"absolute_url"`
+	} `json:"jobs"`
+}
+
+// GreenhouseBoardToken returns the board token of a Greenhouse job board link such as
+// https://boards.greenhouse.io/acme or https://boards.greenhouse.io/embed/job_board?for=acme.
+func GreenhouseBoardToken(link string) (string, bool) {
+	u, ok := parseCareerLink(link)
+	if !ok {
+		return "", false
+	}
+	switch strings.ToLower(strings.TrimPrefix(u.Hostname(), "www.")) {
+	case "boards.greenhouse.io", "job-boards.greenhouse.io", "boards.eu.greenhouse.io", "job-boards.eu.greenhouse.io":
+	default:
+		return "", false
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if segments[0] == "embed" {
+		token := u.Query().Get("for")
+		return token, token != ""
+	}
+	if segments[0] == "" {
+		return "", false
+	}
+	return segments[0], true
+}
+
+// Name implements JobSource.
+func (s *GreenhouseSource) Name() string { return "greenhouse" }
+
+// Search implements JobSource. Queries without a Greenhouse career site have nothing to search.
+func (s *GreenhouseSource) Search(ctx context.Context, q JobQuery) ([]Job, error) {
+	base := s.BaseURL
+	if base == "" {
+		base = GreenhouseAPI
+	}
+
+	var jobs []Job
+	seen := map[string]bool{}
+	for _, link := range q.CareerSites {
+		token, ok := GreenhouseBoardToken(link)
+		if !ok || seen[token] {
+			continue
+		}
+		seen[token] = true
+
+		// content=true is what adds the departments
+		var board greenhouseJobs
+		if err := getJSON(ctx, s.HTTPClient, base+"/v1/boards/"+url.PathEscape(token)+"/jobs?content=true", &board); err != nil {
+			return nil, err
+		}
+		for _, j := range board.Jobs {
+			job := Job{
+				Source:   s.Name(),
+				ID:       strconv.FormatInt(j.ID, 10),
+				Title:    j.Title,
+				Company:  q.Company,
+				Location: j.Location.Name,
+				URL:      j.AbsoluteURL,
+			}
+			for _, d := range j.Departments {
+				job.Departments = append(job.Departments, d.Name)
+			}
+			if updated, err := time.Parse(time.RFC3339, j.UpdatedAt); err == nil {
+				job.UpdatedAt = &updated
+			}
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
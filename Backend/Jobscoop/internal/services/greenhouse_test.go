@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGreenhouseBoardToken(t *testing.T) {
+	tests := []struct {
+		link  string
+		token string
+		ok    bool
+	}{
+		{"https://boards.greenhouse.io/acme", "acme", true},
+		{"https://boards.greenhouse.io/acme/jobs/4012345006", "acme", true},
+		{"boards.greenhouse.io/acme/", "acme", true},
+		{"https://job-boards.greenhouse.io/acme", "acme", true},
+		{"https://job-boards.eu.greenhouse.io/acme", "acme", true},
+		{"https://boards.greenhouse.io/embed/job_board?for=acme&b=https://acme.com/careers", "acme", true},
+		{"https://boards.greenhouse.io/embed/job_board", "", false},
+		{"https://boards.greenhouse.io/", "", false},
+		{"https://acme.com/careers", "", false},
+		{"https://jobs.lever.co/acme", "", false},
+	}
+	for _, tt := range tests {
+		token, ok := GreenhouseBoardToken(tt.link)
+		if token != tt.token || ok != tt.ok {
+			t.Errorf("GreenhouseBoardToken(%q) = %q, %v; want %q, %v", tt.link, token, ok, tt.token, tt.ok)
+		}
+	}
+}
+
+func TestGreenhouseSourceSearch(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		if r.URL.Path != "/v1/boards/acme/jobs" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, "testdata/greenhouse_jobs.json")
+	}))
+	defer server.Close()
+
+	source := &GreenhouseSource{BaseURL: server.URL}
+	jobs, err := source.Search(context.Background(), JobQuery{
+		Company:     "Acme",
+		Role:        "Backend Engineer",
+		CareerSites: []string{"https://acme.com/careers", "https://boards.greenhouse.io/acme", "https://boards.greenhouse.io/acme/jobs/4012345006"},
+	})
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(requests) != 1 || requests[0] != "/v1/boards/acme/jobs?content=true" {
+		t.Errorf("Expected one request for the acme board, got %v", requests)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("Expected every posting on the board, got %d", len(jobs))
+	}
+
+	job := jobs[1]
+	if job.Source != "greenhouse" || job.ID != "4012345007" || job.Title != "Backend Engineer, Payments" || job.Company != "Acme" ||
+		job.Location != "Remote - US" || job.URL != "https://boards.greenhouse.io/acme/jobs/4012345007" {
+		t.Errorf("Unexpected job: %+v", job)
+	}
+	if len(job.Departments) != 2 || job.Departments[0] != "Engineering" || job.Departments[1] != "Payments" {
+		t.Errorf("Unexpected departments: %v", job.Departments)
+	}
+	if job.UpdatedAt == nil || job.UpdatedAt.UTC().Format("2006-01-02T15:04:05") != "2024-04-28T14:02:11" {
+		t.Errorf("Unexpected updated_at: %v", job.UpdatedAt)
+	}
+
+	// Without a Greenhouse board there is nothing to ask
+	requests = nil
+	jobs, err = source.Search(context.Background(), JobQuery{Company: "Acme", Role: "Engineer", CareerSites: []string{"https://acme.com/careers"}})
+	if err != nil || len(jobs) != 0 || len(requests) != 0 {
+		t.Errorf("Expected no request and no jobs, got %v, %d jobs and %v", err, len(jobs), requests)
+	}
+
+	if _, err := source.Search(context.Background(), JobQuery{Company: "Globex", Role: "Engineer", CareerSites: []string{"https://boards.greenhouse.io/globex"}}); err == nil {
+		t.Error("Expected an error for an unknown board")
+	}
+}
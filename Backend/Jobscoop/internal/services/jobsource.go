@@ -0,0 +1,496 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+)
+
+// Job is a posting found by a JobSource.
+// Commitment is the kind of contract as the source words it, such as "Full-time"; WorkplaceType
+// is onsite, remote or hybrid when the source says.
+type Job struct {
+	Source        string     `json:"source"`
+	ID            string     `json:"id"`
+	Title         string     `json:"title"`
+	Company       string     `json:"company"`
+	Location      string     `json:"location"`
+	Departments   []string   `json:"departments,omitempty"`
+	Commitment    string     `json:"commitment,omitempty"`
+	WorkplaceType string     `json:"workplace_type,omitempty"`
+	URL           string     `json:"url"`
+	PostedAt      *time.Time `json:"posted_at,omitempty"`
+	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+	ValidThrough  *time.Time `json:"valid_through,omitempty"`
+	Salary        *Salary    `json:"salary,omitempty"`
+}
+
+// Salary is the pay range a posting advertises, per Unit (HOUR, MONTH, YEAR...) when it says.
+// A single figure is both Min and Max.
+type Salary struct {
+	Currency string  `json:"currency,omitempty"`
+	Min      float64 `json:"min,omitempty"`
+	Max      float64 `json:"max,omitempty"`
+	Unit     string  `json:"unit,omitempty"`
+}
+
+// Key identifies the posting across searches: its source and the source's id, or its URL.
+func (j Job) Key() string {
+	if j.ID != "" {
+		return j.Source + ":" + j.ID
+	}
+	if j.URL != "" {
+		return j.URL
+	}
+	return j.Source + ":" + j.Company + "/" + j.Title
+}
+
+// ExternalID is the source's id for the posting, or its canonical URL for sources without ids.
+func (j Job) ExternalID() string {
+	if j.ID != "" {
+		return j.ID
+	}
+	if j.URL != "" {
+		return CanonicalURL(j.URL)
+	}
+	return j.Company + "/" + j.Title
+}
+
+// DedupKey matches a posting to the same one found by other sources of its company: its title and
+// the first part of its location, folded to lowercase letters and digits. Sources word locations
+// differently ("Berlin" and "Berlin, Germany"), so only the city is kept.
+func (j Job) DedupKey() string {
+	location := j.Location
+	if i := strings.IndexAny(location, ",;("); i >= 0 {
+		location = location[:i]
+	}
+	return foldKey(j.Title) + "|" + foldKey(location)
+}
+
+// Aggregated reports whether the posting was found on a board listing postings hosted elsewhere,
+// rather than on the company's own ATS or career site.
+func (j Job) Aggregated() bool {
+	return aggregatorSources[j.Source]
+}
+
+// aggregatorSources are the sources that relay other sites' postings
+var aggregatorSources = map[string]bool{"linkedin": true, "feed": true}
+
+// foldKey lowercases s and keeps its letters and digits, single spaced
+func foldKey(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+		} else {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// trackingParams are query parameters boards add to links without changing the page
+var trackingParams = map[string]bool{
+	"ref": true, "refid": true, "source": true, "src": true, "trk": true, "trackingid": true,
+	"gh_src": true, "lever-source": true, "lever-origin": true, "fbclid": true, "gclid": true,
+}
+
+// CanonicalURL normalizes a posting link so the same page is stored once: the scheme and host
+// are lowercased, the fragment, tracking parameters and trailing slash dropped and the remaining
+// parameters sorted. Links that do not parse are returned trimmed.
+func CanonicalURL(link string) string {
+	link = strings.TrimSpace(link)
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return link
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.User = nil
+	if u.Path != "/" {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = ""
+	}
+
+	query := u.Query()
+	for name := range query {
+		lower := strings.ToLower(name)
+		if trackingParams[lower] || strings.HasPrefix(lower, "utm_") {
+			query.Del(name)
+		}
+	}
+	// Encode sorts the parameters by name
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// JobQuery is what a subscription searches for. Location is optional. CareerSites are the career
+// page links the user gave for the company; sources for a company's own job board find it there.
+// Feeds are the RSS or Atom feeds the user attached to the subscription. SubscriptionID is the
+// subscription searching, when the postings found on links only it lists are wanted too, and
+// CompanyID the stored company whose postings are read back.
+type JobQuery struct {
+	Company        string
+	Role           string
+	Location       string
+	CareerSites    []string
+	Feeds          []string
+	SubscriptionID int
+	CompanyID      int
+}
+
+// SubscriptionSource reports whether the source reads links no one has checked: career pages
+// scraped or crawled for JSON-LD on domains without a scraper config, and feeds. It only searches
+// those for the subscription that lists them, so one user's links cannot add postings for others.
+func SubscriptionSource(name string) bool {
+	switch name {
+	case "scraper", "jsonld", "feed":
+		return true
+	}
+	return false
+}
+
+// JobSource is a job board or ATS that can be searched for postings.
+type JobSource interface {
+	Name() string
+	Search(ctx context.Context, q JobQuery) ([]Job, error)
+}
+
+// ErrSourceNotConfigured is returned by a JobSourceFactory whose settings are missing.
+var ErrSourceNotConfigured = errors.New("job source is not configured")
+
+// JobSourceFactory builds a source from the environment.
+type JobSourceFactory func() (JobSource, error)
+
+var (
+	jobSourcesMu       sync.RWMutex
+	jobSourceFactories = map[string]JobSourceFactory{}
+	jobSources         []JobSource
+)
+
+// RegisterJobSource makes a source available to LoadJobSourcesFromEnv under name.
+// Sources register themselves from an init function.
+func RegisterJobSource(name string, factory JobSourceFactory) {
+	jobSourcesMu.Lock()
+	defer jobSourcesMu.Unlock()
+	jobSourceFactories[name] = factory
+}
+
+// SetJobSources replaces the enabled sources.
+func SetJobSources(sources ...JobSource) {
+	jobSourcesMu.Lock()
+	defer jobSourcesMu.Unlock()
+	jobSources = sources
+}
+
+// JobSources returns the enabled sources.
+func JobSources() []JobSource {
+	jobSourcesMu.RLock()
+	defer jobSourcesMu.RUnlock()
+	return append([]JobSource(nil), jobSources...)
+}
+
+// LoadJobSourcesFromEnv enables the comma separated sources named in JOB_SOURCES, all of which must
+// be configured. Without it every registered source that is configured is enabled.
+func LoadJobSourcesFromEnv() error {
+	jobSourcesMu.RLock()
+	factories := make(map[string]JobSourceFactory, len(jobSourceFactories))
+	var registered []string
+	for name, factory := range jobSourceFactories {
+		factories[name] = factory
+		registered = append(registered, name)
+	}
+	jobSourcesMu.RUnlock()
+	sort.Strings(registered)
+
+	explicit := strings.TrimSpace(os.Getenv("JOB_SOURCES")) != ""
+	names := registered
+	if explicit {
+		names = nil
+		for _, name := range strings.Split(os.Getenv("JOB_SOURCES"), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var sources []JobSource
+	for _, name := range names {
+		factory, ok := factories[name]
+		if !ok {
+			return fmt.Errorf("unknown job source %q", name)
+		}
+		source, err := factory()
+		if errors.Is(err, ErrSourceNotConfigured) && !explicit {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("job source %s: %w", name, err)
+		}
+		sources = append(sources, source)
+	}
+	SetJobSources(sources...)
+	return nil
+}
+
+// SearchJobs asks every enabled source for postings matching q and merges the results, dropping
+// duplicates and postings whose company or title do not match. A failing source is skipped; the
+// search only fails when every source does.
+func SearchJobs(ctx context.Context, q JobQuery) ([]Job, error) {
+	sources := JobSources()
+	if len(sources) == 0 {
+		return nil, errors.New("no job source is enabled")
+	}
+
+	results := make([][]Job, len(sources))
+	errs := make([]error, len(sources))
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source JobSource) {
+			defer wg.Done()
+			results[i], errs[i] = source.Search(ctx, q)
+		}(i, source)
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	jobs := []Job{}
+	failed := 0
+	for i, source := range sources {
+		if errs[i] != nil {
+			log.Printf("Job source %s failed: %v", source.Name(), errs[i])
+			failed++
+			continue
+		}
+		for _, job := range results[i] {
+			key := job.Key()
+			if seen[key] || !MatchesQuery(job, q) {
+				continue
+			}
+			seen[key] = true
+			jobs = append(jobs, job)
+		}
+	}
+	if failed == len(sources) {
+		return nil, errors.Join(errs...)
+	}
+	return jobs, nil
+}
+
+// ErrSourceNotEnabled is returned by SearchSource for a source that is not enabled.
+var ErrSourceNotEnabled = errors.New("job source is not enabled")
+
+// SearchSource asks the enabled source called name for postings matching q, dropping duplicates
+// and postings whose company or title do not match.
+func SearchSource(ctx context.Context, name string, q JobQuery) ([]Job, error) {
+	for _, source := range JobSources() {
+		if source.Name() != name {
+			continue
+		}
+		found, err := source.Search(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		jobs := []Job{}
+		for _, job := range found {
+			key := job.Key()
+			if seen[key] || !MatchesQuery(job, q) {
+				continue
+			}
+			seen[key] = true
+			jobs = append(jobs, job)
+		}
+		return jobs, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrSourceNotEnabled, name)
+}
+
+// getJSON fetches endpoint into v, failing on any status but 200
+func getJSON(ctx context.Context, client *http.Client, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(client, req, v)
+}
+
+// postJSON posts body as JSON to endpoint and decodes the response into v
+func postJSON(ctx context.Context, client *http.Client, endpoint string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doJSON(client, req, v)
+}
+
+func doJSON(client *http.Client, req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := sourceClient(client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: status %d", req.Method, req.URL, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// parseCareerLink parses a career site link, which users often save without a scheme
+func parseCareerLink(link string) (*url.URL, bool) {
+	link = strings.TrimSpace(link)
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return nil, false
+	}
+	return u, true
+}
+
+// defaultSourceClient fetches for sources that have no client of their own. Users and admins
+// choose the pages it fetches, so it only connects to public addresses.
+var defaultSourceClient = newSourceClient(publicAddressOnly)
+
+// sourceClient returns client, or the default one for sources that have none
+func sourceClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return defaultSourceClient
+}
+
+// newSourceClient returns a client whose connections are checked by control once their address
+// is resolved, which covers the hosts redirects lead to as well
+func newSourceClient(control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second, Control: control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would make the connection to the proxy the one checked
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to %s is not http or https", req.URL.Redacted())
+			}
+			if ip := net.ParseIP(req.URL.Hostname()); ip != nil && !isPublicIP(ip) {
+				return fmt.Errorf("redirect to %s is not a public address", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+}
+
+// publicAddressOnly refuses connections to loopback, private, link-local, multicast and
+// unspecified addresses, so a link cannot reach the server's own network
+func publicAddressOnly(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("%s is not a public address", host)
+	}
+	return nil
+}
+
+// PublicHost reports whether a link to host may be fetched: it is not localhost or an address
+// outside the public internet. Names are checked again once resolved, when connecting.
+func PublicHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return isPublicIP(ip)
+	}
+	return true
+}
+
+// isPublicIP reports whether ip is routable on the public internet
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// MatchesQuery reports whether a posting belongs to the searched company and role. Boards return
+// loosely related postings, so the company names must contain one another and every significant
+// word of the role must appear in the title.
+func MatchesQuery(job Job, q JobQuery) bool {
+	if job.Company == "" || job.Title == "" {
+		return false
+	}
+
+	company, wanted := strings.ToLower(job.Company), strings.ToLower(q.Company)
+	if !strings.Contains(company, wanted) && !strings.Contains(wanted, company) {
+		return false
+	}
+
+	title := strings.ToLower(job.Title)
+	for _, word := range strings.Fields(strings.ToLower(q.Role)) {
+		// Skip common words that might be too generic
+		if len(word) <= 2 || isCommonWord(word) {
+			continue
+		}
+		if !strings.Contains(title, word) {
+			return false
+		}
+	}
+
+	if q.Location != "" && job.Location != "" && !strings.Contains(strings.ToLower(job.Location), strings.ToLower(q.Location)) {
+		return false
+	}
+	return true
+}
+
+// isCommonWord identifies common words that shouldn't be used for matching
+func isCommonWord(word string) bool {
+	commonWords := map[string]bool{
+		"and": true,
+		"or":  true,
+		"the": true,
+		"for": true,
+		"in":  true,
+		"at":  true,
+		"of":  true,
+		"to":  true,
+		"a":   true,
+		"an":  true,
+	}
+
+	return commonWords[word]
+}
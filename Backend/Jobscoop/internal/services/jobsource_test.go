@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestMain lets the sources reach the test servers, which listen on loopback
+func TestMain(m *testing.M) {
+	defaultSourceClient = newSourceClient(nil)
+	os.Exit(m.Run())
+}
+
+// fakeSource returns fixed results
+type fakeSource struct {
+	name string
+	jobs []Job
+	err  error
+}
+
+func (f *fakeSource) Name() string { return f.name }
+
+func (f *fakeSource) Search(ctx context.Context, q JobQuery) ([]Job, error) { return f.jobs, f.err }
+
+func TestSearchJobs(t *testing.T) {
+	defer SetJobSources()
+
+	SetJobSources(
+		&fakeSource{name: "board", jobs: []Job{
+			{Source: "board", ID: "1", Title: "Backend Engineer", Company: "Acme"},
+			{Source: "board", ID: "2", Title: "Frontend Engineer", Company: "Acme"},
+			{Source: "board", ID: "3", Title: "Backend Engineer", Company: "Globex"},
+			{Source: "board", ID: "1", Title: "Backend Engineer", Company: "Acme"},
+		}},
+		&fakeSource{name: "ats", jobs: []Job{
+			{Source: "ats", URL: "https://jobs.example.com/acme/42", Title: "Senior Backend Engineer", Company: "Acme Inc."},
+		}},
+		&fakeSource{name: "down", err: errors.New("unavailable")},
+	)
+
+	jobs, err := SearchJobs(context.Background(), JobQuery{Company: "Acme", Role: "Backend Engineer"})
+	if err != nil {
+		t.Fatalf("SearchJobs returned error: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].Key() != "board:1" || jobs[1].Key() != "https://jobs.example.com/acme/42" {
+		t.Errorf("Expected the two matching postings once each, got %+v", jobs)
+	}
+
+	SetJobSources(&fakeSource{name: "down", err: errors.New("unavailable")})
+	if _, err := SearchJobs(context.Background(), JobQuery{Company: "Acme", Role: "Engineer"}); err == nil {
+		t.Error("Expected an error when every source fails")
+	}
+
+	SetJobSources()
+	if _, err := SearchJobs(context.Background(), JobQuery{Company: "Acme", Role: "Engineer"}); err == nil {
+		t.Error("Expected an error without any source")
+	}
+}
+
+func TestSearchSource(t *testing.T) {
+	defer SetJobSources()
+
+	SetJobSources(
+		&fakeSource{name: "board", jobs: []Job{
+			{Source: "board", ID: "1", Title: "Backend Engineer", Company: "Acme"},
+			{Source: "board", ID: "2", Title: "Frontend Engineer", Company: "Acme"},
+			{Source: "board", ID: "1", Title: "Backend Engineer", Company: "Acme"},
+		}},
+		&fakeSource{name: "down", err: errors.New("unavailable")},
+	)
+
+	jobs, err := SearchSource(context.Background(), "board", JobQuery{Company: "Acme", Role: "Backend Engineer"})
+	if err != nil || len(jobs) != 1 || jobs[0].Key() != "board:1" {
+		t.Errorf("Expected the matching posting once, got %+v (%v)", jobs, err)
+	}
+	if _, err := SearchSource(context.Background(), "down", JobQuery{Company: "Acme", Role: "Engineer"}); err == nil {
+		t.Error("Expected the source's error")
+	}
+	if _, err := SearchSource(context.Background(), "monster", JobQuery{Company: "Acme", Role: "Engineer"}); !errors.Is(err, ErrSourceNotEnabled) {
+		t.Errorf("Expected ErrSourceNotEnabled, got %v", err)
+	}
+}
+
+func TestMatchesQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		job  Job
+		q    JobQuery
+		want bool
+	}{
+		{"Exact", Job{Title: "Data Engineer", Company: "Acme"}, JobQuery{Company: "acme", Role: "Data Engineer"}, true},
+		{"Company Suffix", Job{Title: "Data Engineer", Company: "Acme Corporation"}, JobQuery{Company: "Acme", Role: "Data Engineer"}, true},
+		{"Common Words Ignored", Job{Title: "Head of Data", Company: "Acme"}, JobQuery{Company: "Acme", Role: "Head of the Data"}, true},
+		{"Role Word Missing", Job{Title: "Data Analyst", Company: "Acme"}, JobQuery{Company: "Acme", Role: "Data Engineer"}, false},
+		{"Other Company", Job{Title: "Data Engineer", Company: "Globex"}, JobQuery{Company: "Acme", Role: "Data Engineer"}, false},
+		{"Location", Job{Title: "Data Engineer", Company: "Acme", Location: "Austin, TX"}, JobQuery{Company: "Acme", Role: "Data Engineer", Location: "new york"}, false},
+		{"Unknown Location", Job{Title: "Data Engineer", Company: "Acme"}, JobQuery{Company: "Acme", Role: "Data Engineer", Location: "new york"}, true},
+		{"No Title", Job{Company: "Acme"}, JobQuery{Company: "Acme", Role: "Data Engineer"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchesQuery(tt.job, tt.q); got != tt.want {
+				t.Errorf("MatchesQuery = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobDedupKey(t *testing.T) {
+	linkedin := Job{Source: "linkedin", ID: "3712", Title: "Senior Backend Engineer (Go)", Location: "Berlin, Berlin, Germany"}
+	ats := Job{Source: "greenhouse", ID: "55", Title: "Senior Backend Engineer - Go", Location: "Berlin"}
+	if linkedin.DedupKey() != ats.DedupKey() {
+		t.Errorf("Expected the listings to share a key, got %q and %q", linkedin.DedupKey(), ats.DedupKey())
+	}
+	if other := (Job{Title: "Senior Backend Engineer - Go", Location: "Munich"}); other.DedupKey() == ats.DedupKey() {
+		t.Errorf("Expected postings in other cities to be kept apart, got %q", other.DedupKey())
+	}
+	if !linkedin.Aggregated() || ats.Aggregated() {
+		t.Error("Expected only the LinkedIn listing to come from an aggregator")
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://Jobs.Lever.co/acme/42/?lever-source=LinkedIn#apply", "https://jobs.lever.co/acme/42"},
+		{"https://acme.example/careers?utm_source=x&id=7&gh_src=abc&dept=eng", "https://acme.example/careers?dept=eng&id=7"},
+		{"https://acme.example/", "https://acme.example/"},
+		{" not a link ", "not a link"},
+	}
+	for _, tt := range tests {
+		if got := CanonicalURL(tt.link); got != tt.want {
+			t.Errorf("CanonicalURL(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestSourceClientOnlyReachesPublicAddresses(t *testing.T) {
+	var metadata bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+			return
+		}
+		metadata = true
+	}))
+	defer server.Close()
+
+	if _, err := newSourceClient(publicAddressOnly).Get(server.URL); err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("Expected a loopback address to be refused, got %v", err)
+	}
+	// The test server is reachable, the metadata address it redirects to is not
+	if _, err := newSourceClient(nil).Get(server.URL + "/redirect"); err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("Expected the redirect to be refused, got %v", err)
+	}
+	if metadata {
+		t.Error("Expected the redirect not to be followed")
+	}
+
+	for address, public := range map[string]bool{
+		"93.184.216.34:443":     true,
+		"[2606:4700::1111]:443": true,
+		"127.0.0.1:80":          false,
+		"10.0.0.5:80":           false,
+		"192.168.1.1:80":        false,
+		"169.254.169.254:80":    false,
+		"0.0.0.0:80":            false,
+		"[::1]:80":              false,
+		"[fd00::1]:80":          false,
+		"[fe80::1]:80":          false,
+		"[::ffff:127.0.0.1]:80": false,
+	} {
+		if err := publicAddressOnly("tcp", address, nil); (err == nil) != public {
+			t.Errorf("publicAddressOnly(%s) = %v, want public %v", address, err, public)
+		}
+	}
+}
+
+func TestLoadJobSourcesFromEnv(t *testing.T) {
+	defer SetJobSources()
+
+	t.Setenv("JOB_SOURCES", "")
+	t.Setenv("SCRAPING_DOG_API_KEY", "")
+	if err := LoadJobSourcesFromEnv(); err != nil || !strings.Contains(sourceNames(), "greenhouse") || strings.Contains(sourceNames(), "linkedin") {
+		t.Errorf("Expected unconfigured sources to be skipped, got %v and %q", err, sourceNames())
+	}
+
+	t.Setenv("JOB_SOURCES", "linkedin")
+	if err := LoadJobSourcesFromEnv(); err == nil {
+		t.Error("Expected an error for an explicitly enabled source without settings")
+	}
+
+	t.Setenv("SCRAPING_DOG_API_KEY", "secret")
+	if err := LoadJobSourcesFromEnv(); err != nil || sourceNames() != "linkedin" {
+		t.Errorf("Expected only the LinkedIn source, got %v and %q", err, sourceNames())
+	}
+
+	t.Setenv("JOB_SOURCES", "linkedin, monster")
+	if err := LoadJobSourcesFromEnv(); err == nil {
+		t.Error("Expected an error for an unknown source")
+	}
+}
+
+// sourceNames lists the enabled sources, comma separated
+func sourceNames() string {
+	var names []string
+	for _, source := range JobSources() {
+		names = append(names, source.Name())
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// defaultJSONLDPages is how many posting pages are crawled from a career page without postings
+const defaultJSONLDPages = 25
+
+func init() {
+	RegisterJobSource("jsonld", func() (JobSource, error) {
+		return &JSONLDSource{}, nil
+	})
+}
+
+// JSONLDSource reads the schema.org JobPosting data embedded in the career sites of a query that
+// no ATS source handles. When a career page has none it crawls the posting pages it links to,
+// at most MaxPages of them.
+type JSONLDSource struct {
+	MaxPages   int
+	HTTPClient *http.Client
+}
+
+// Name implements JobSource.
+func (s *JSONLDSource) Name() string { return "jsonld" }
+
+// Search implements JobSource.
+func (s *JSONLDSource) Search(ctx context.Context, q JobQuery) ([]Job, error) {
+	var jobs []Job
+	for _, link := range q.CareerSites {
+		u, ok := parseCareerLink(link)
+		if !ok || atsLink(link) {
+			continue
+		}
+		found, err := CrawlJobPostings(ctx, s.HTTPClient, u.String(), s.MaxPages)
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range found {
+			if job.Company == "" {
+				job.Company = q.Company
+			}
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// CrawlJobPostings returns the JobPostings embedded in the page at link. A page without any is
+// taken for a list of postings, and up to maxPages of the posting pages it links to are read.
+// Postings past their validThrough date are left out.
+func CrawlJobPostings(ctx context.Context, client *http.Client, link string, maxPages int) ([]Job, error) {
+	if maxPages <= 0 {
+		maxPages = defaultJSONLDPages
+	}
+	doc, base, err := getHTML(ctx, client, link)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if jobs := jobPostings(doc, base, now); len(jobs) > 0 {
+		return jobs, nil
+	}
+
+	var jobs []Job
+	seen := map[string]bool{}
+	crawled := 0
+	for _, candidate := range heuristicJobs(doc, base) {
+		if crawled == maxPages {
+			break
+		}
+		if atsLink(candidate.URL) {
+			continue
+		}
+		crawled++
+		page, pageBase, err := getHTML(ctx, client, candidate.URL)
+		if err != nil {
+			// One missing posting page should not hide the others
+			continue
+		}
+		for _, job := range jobPostings(page, pageBase, now) {
+			if !seen[job.Key()] {
+				seen[job.Key()] = true
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	return jobs, nil
+}
+
+// jobPostings maps every JobPosting in a page's JSON-LD blocks, wherever it is nested
+func jobPostings(doc *goquery.Document, base *url.URL, now time.Time) []Job {
+	var jobs []Job
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, script *goquery.Selection) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(script.Text()), &data); err != nil {
+			// Broken blocks are common, the page may have others
+			return
+		}
+		for _, posting := range findJobPostings(data) {
+			job := jobFromPosting(posting, base)
+			if job.Title == "" || (job.ValidThrough != nil && job.ValidThrough.Before(now)) {
+				continue
+			}
+			jobs = append(jobs, job)
+		}
+	})
+	return jobs
+}
+
+// findJobPostings walks JSON-LD data, @graph arrays and item lists included, collecting the
+// objects typed JobPosting
+func findJobPostings(data interface{}) []map[string]interface{} {
+	switch v := data.(type) {
+	case []interface{}:
+		var postings []map[string]interface{}
+		for _, item := range v {
+			postings = append(postings, findJobPostings(item)...)
+		}
+		return postings
+	case map[string]interface{}:
+		if ldHasType(v, "JobPosting") {
+			return []map[string]interface{}{v}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var postings []map[string]interface{}
+		for _, key := range keys {
+			postings = append(postings, findJobPostings(v[key])...)
+		}
+		return postings
+	}
+	return nil
+}
+
+// ldHasType reports whether an object's @type, a name or list of names, possibly written as
+// schema.org URLs, includes want
+func ldHasType(v map[string]interface{}, want string) bool {
+	for _, t := range ldList(v["@type"]) {
+		name, _ := t.(string)
+		if i := strings.LastIndexAny(name, "/#:"); i >= 0 {
+			name = name[i+1:]
+		}
+		if name == want {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonLDEmploymentTypes words schema.org employment types the way the boards do
+var jsonLDEmploymentTypes = map[string]string{
+	"FULL_TIME":  "Full-time",
+	"PART_TIME":  "Part-time",
+	"CONTRACTOR": "Contract",
+	"TEMPORARY":  "Temporary",
+	"INTERN":     "Internship",
+	"VOLUNTEER":  "Volunteer",
+	"PER_DIEM":   "Per diem",
+	"OTHER":      "Other",
+}
+
+func jobFromPosting(p map[string]interface{}, base *url.URL) Job {
+	job := Job{
+		Source:       "jsonld",
+		ID:           ldIdentifier(p["identifier"]),
+		Title:        ldString(p["title"]),
+		Company:      ldString(p["hiringOrganization"]),
+		URL:          resolveLink(base, ldString(p["url"])),
+		PostedAt:     parseDate(ldString(p["datePosted"]), ""),
+		ValidThrough: parseDate(ldString(p["validThrough"]), ""),
+		Salary:       ldSalary(p["baseSalary"]),
+	}
+	if job.Title == "" {
+		job.Title = ldString(p["name"])
+	}
+	job.Title = collapseSpace(html.UnescapeString(job.Title))
+	if job.URL == "" {
+		job.URL = base.String()
+	}
+	if job.ID != "" && job.Company != "" {
+		// Identifiers are only unique within the hiring organization
+		job.ID = job.Company + ":" + job.ID
+	}
+
+	var locations []string
+	for _, place := range ldList(p["jobLocation"]) {
+		if location := ldPlace(place); location != "" {
+			locations = append(locations, location)
+		}
+	}
+	job.Location = strings.Join(locations, "; ")
+	if strings.EqualFold(ldString(p["jobLocationType"]), "TELECOMMUTE") {
+		job.WorkplaceType = "remote"
+		if job.Location == "" {
+			job.Location = "Remote"
+		}
+	}
+
+	var commitments []string
+	for _, t := range ldList(p["employmentType"]) {
+		name, _ := t.(string)
+		name = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(name), "-", "_"))
+		if worded, ok := jsonLDEmploymentTypes[name]; ok {
+			commitments = append(commitments, worded)
+		} else if name != "" {
+			commitments = append(commitments, name)
+		}
+	}
+	job.Commitment = strings.Join(commitments, ", ")
+	return job
+}
+
+// ldPlace words a Place: its PostalAddress as locality, region and country, or its name
+func ldPlace(v interface{}) string {
+	place, ok := v.(map[string]interface{})
+	if !ok {
+		return ldString(v)
+	}
+	address, ok := place["address"].(map[string]interface{})
+	if !ok {
+		if s := ldString(place["address"]); s != "" {
+			return s
+		}
+		return ldString(place["name"])
+	}
+	var parts []string
+	for _, key := range []string{"addressLocality", "addressRegion", "addressCountry"} {
+		if part := ldString(address[key]); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ldSalary reads a MonetaryAmount whose value is a number or a QuantitativeValue
+func ldSalary(v interface{}) *Salary {
+	amount, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	salary := Salary{Currency: ldString(amount["currency"])}
+	if value, ok := amount["value"].(map[string]interface{}); ok {
+		salary.Min, _ = ldNumber(value["minValue"])
+		salary.Max, _ = ldNumber(value["maxValue"])
+		if n, ok := ldNumber(value["value"]); ok {
+			salary.Min, salary.Max = n, n
+		}
+		salary.Unit = strings.ToUpper(ldString(value["unitText"]))
+	} else if n, ok := ldNumber(amount["value"]); ok {
+		salary.Min, salary.Max = n, n
+	}
+	if salary.Min == 0 && salary.Max == 0 {
+		return nil
+	}
+	if salary.Min == 0 {
+		salary.Min = salary.Max
+	}
+	if salary.Max == 0 {
+		salary.Max = salary.Min
+	}
+	return &salary
+}
+
+// ldString reads a text value: a string, the name or value of an object, or the first of a list
+func ldString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}:
+		if name := ldString(v["name"]); name != "" {
+			return name
+		}
+		return ldString(v["value"])
+	case []interface{}:
+		if len(v) > 0 {
+			return ldString(v[0])
+		}
+	}
+	return ""
+}
+
+// ldIdentifier reads an identifier, a PropertyValue naming the organization that issued its value
+func ldIdentifier(v interface{}) string {
+	if id, ok := v.(map[string]interface{}); ok {
+		return ldString(id["value"])
+	}
+	return ldString(v)
+}
+
+// ldNumber reads a number that may be written as a string
+func ldNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(v), ",", ""), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// ldList reads a value that may be one item or a list of them
+func ldList(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	}
+	return []interface{}{v}
+}
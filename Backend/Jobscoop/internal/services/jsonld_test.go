@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// jsonLDSite serves the JSON-LD fixtures
+func jsonLDSite(t *testing.T) (*httptest.Server, *[]string) {
+	var requests []string
+	pages := map[string]string{
+		"/careers":                  "testdata/jsonld_graph.html",
+		"/jobs":                     "testdata/jsonld_index.html",
+		"/jobs/101-data-engineer":   "testdata/jsonld_posting_101.html",
+		"/jobs/102-product-manager": "testdata/jsonld_posting_102.html",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if page, ok := pages[r.URL.Path]; ok {
+			http.ServeFile(w, r, page)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestCrawlJobPostingsFromGraph(t *testing.T) {
+	server, requests := jsonLDSite(t)
+
+	jobs, err := CrawlJobPostings(context.Background(), nil, server.URL+"/careers", 0)
+	if err != nil {
+		t.Fatalf("CrawlJobPostings returned error: %v", err)
+	}
+	if len(*requests) != 1 {
+		t.Errorf("Expected no crawling when the page has postings, got %v", *requests)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("Expected the two current postings, got %+v", jobs)
+	}
+
+	first := jobs[0]
+	if first.Source != "jsonld" || first.ID != "Acme:BE-7" || first.Title != "Senior Backend Engineer & Tech Lead" ||
+		first.Company != "Acme" || first.Location != "Berlin, BE, DE" || first.Commitment != "Full-time" ||
+		first.URL != server.URL+"/careers/senior-backend-engineer" {
+		t.Errorf("Unexpected job: %+v", first)
+	}
+	if first.PostedAt == nil || first.PostedAt.Format("2006-01-02") != "2024-03-01" || first.ValidThrough == nil || first.ValidThrough.Year() != 2099 {
+		t.Errorf("Unexpected dates: %v, %v", first.PostedAt, first.ValidThrough)
+	}
+	if first.Salary == nil || *first.Salary != (Salary{Currency: "EUR", Min: 80000, Max: 95000, Unit: "YEAR"}) {
+		t.Errorf("Unexpected salary: %+v", first.Salary)
+	}
+
+	second := jobs[1]
+	if second.Title != "Support Engineer" || second.Company != "Acme" || second.Location != "Lisbon, PT; Porto, Portugal" ||
+		second.WorkplaceType != "remote" || second.Commitment != "Part-time, Contract" || second.URL != server.URL+"/careers" {
+		t.Errorf("Unexpected job: %+v", second)
+	}
+	if second.Salary == nil || second.Salary.Min != 30 || second.Salary.Max != 30 || second.Salary.Unit != "" {
+		t.Errorf("Unexpected salary: %+v", second.Salary)
+	}
+	if second.PostedAt == nil || second.PostedAt.UTC().Format("2006-01-02T15:04") != "2024-02-20T08:30" {
+		t.Errorf("Unexpected posting date: %v", second.PostedAt)
+	}
+}
+
+func TestCrawlJobPostingsFollowsPostingLinks(t *testing.T) {
+	server, requests := jsonLDSite(t)
+
+	jobs, err := CrawlJobPostings(context.Background(), nil, server.URL+"/jobs", 0)
+	if err != nil {
+		t.Fatalf("CrawlJobPostings returned error: %v", err)
+	}
+	if len(*requests) != 4 {
+		t.Errorf("Expected the index and its three posting pages, got %v", *requests)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("Expected the postings of the pages that have one, got %+v", jobs)
+	}
+	if jobs[0].ID != "Globex Corporation:101" || jobs[0].Commitment != "Full-time" || jobs[0].URL != server.URL+"/jobs/101-data-engineer" ||
+		jobs[0].Location != "Springfield, OR, US" {
+		t.Errorf("Unexpected job: %+v", jobs[0])
+	}
+	if jobs[1].Title != "Product Manager" || jobs[1].URL != "https://globex.example/jobs/102-product-manager" {
+		t.Errorf("Expected the posting nested in the page, got %+v", jobs[1])
+	}
+
+	// Crawling stops after maxPages posting pages
+	*requests = nil
+	if jobs, err := CrawlJobPostings(context.Background(), nil, server.URL+"/jobs", 1); err != nil || len(jobs) != 1 || len(*requests) != 2 {
+		t.Errorf("Expected one posting page to be crawled, got %v, %v after %v", jobs, err, *requests)
+	}
+}
+
+func TestJSONLDSourceSearch(t *testing.T) {
+	server, _ := jsonLDSite(t)
+
+	source := &JSONLDSource{}
+	jobs, err := source.Search(context.Background(), JobQuery{
+		Company:     "Acme",
+		Role:        "Engineer",
+		CareerSites: []string{server.URL + "/careers", "https://jobs.lever.co/acme"},
+	})
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].Company != "Acme" {
+		t.Errorf("Unexpected jobs: %+v", jobs)
+	}
+
+	if _, err := source.Search(context.Background(), JobQuery{Company: "Acme", CareerSites: []string{server.URL + "/missing"}}); err == nil {
+		t.Error("Expected an error for a career page that cannot be fetched")
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// SigningKey is one private key of the token key set.
+//
+// A key is published in the JWKS and accepted for verification until RetireAt (zero means never).
+// It is only used for signing from ActiveFrom on, which lets a new key be published ahead of use
+// and an old key keep verifying outstanding tokens after it stopped signing.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	ActiveFrom time.Time
+	RetireAt   time.Time
+}
+
+// KeySet holds every key that is currently published or scheduled.
+type KeySet struct {
+	keys []*SigningKey
+}
+
+// keyManifestEntry is one entry of the optional keys.json file in the key directory
+type keyManifestEntry struct {
+	KID        string    `json:"kid"`
+	File       string    `json:"file"`
+	ActiveFrom time.Time `json:"active_from"`
+	RetireAt   time.Time `json:"retire_at"`
+}
+
+// NewKeySet builds a key set from already loaded keys.
+func NewKeySet(keys ...*SigningKey) *KeySet {
+	sorted := append([]*SigningKey(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ActiveFrom.Before(sorted[j].ActiveFrom)
+	})
+	return &KeySet{keys: sorted}
+}
+
+// LoadKeySet reads PKCS#8 (or PKCS#1 RSA) PEM private keys from dir.
+//
+// If dir contains a keys.json manifest, it lists each key's kid, file, active_from and retire_at.
+// Otherwise every *.pem file is loaded with its file name as kid, and the last one in
+// lexical order signs. A new Ed25519 key can be created with:
+//
+//	openssl genpkey -algorithm ed25519 -out 2025-06.pem
+func LoadKeySet(dir string) (*KeySet, error) {
+	var entries []keyManifestEntry
+
+	manifest, err := os.ReadFile(filepath.Join(dir, "keys.json"))
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(manifest, &entries); err != nil {
+			return nil, fmt.Errorf("invalid keys.json: %w", err)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		for i, file := range files {
+			base := filepath.Base(file)
+			entries = append(entries, keyManifestEntry{
+				KID:  strings.TrimSuffix(base, ".pem"),
+				File: base,
+				// Keep the file order as the signing order
+				ActiveFrom: time.Unix(int64(i), 0).UTC(),
+			})
+		}
+	default:
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no signing keys found in %s", dir)
+	}
+
+	keys := make([]*SigningKey, 0, len(entries))
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.KID == "" || seen[entry.KID] {
+			return nil, fmt.Errorf("missing or duplicate kid %q", entry.KID)
+		}
+		seen[entry.KID] = true
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.File))
+		if err != nil {
+			return nil, err
+		}
+		key, err := parsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", entry.KID, err)
+		}
+		key.ID = entry.KID
+		key.ActiveFrom = entry.ActiveFrom
+		key.RetireAt = entry.RetireAt
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(keys...), nil
+}
+
+// parsePrivateKey decodes a PEM private key and picks RS256 or EdDSA from its type
+func parsePrivateKey(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return &SigningKey{Method: jwt.SigningMethodRS256, PrivateKey: key}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{Method: jwt.SigningMethodEdDSA, PrivateKey: key}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+func (k *SigningKey) retired(now time.Time) bool {
+	return !k.RetireAt.IsZero() && !now.Before(k.RetireAt)
+}
+
+// signingKey returns the newest key whose activation time has passed
+func (ks *KeySet) signingKey(now time.Time) (*SigningKey, error) {
+	for i := len(ks.keys) - 1; i >= 0; i-- {
+		key := ks.keys[i]
+		if !now.Before(key.ActiveFrom) && !key.retired(now) {
+			return key, nil
+		}
+	}
+	return nil, errors.New("no active signing key")
+}
+
+// verificationKey returns the published key with the given kid
+func (ks *KeySet) verificationKey(kid string, now time.Time) (*SigningKey, error) {
+	for _, key := range ks.keys {
+		if key.ID == kid && !key.retired(now) {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown or retired key %q", kid)
+}
+
+// JWK is the public half of a signing key in RFC 7517 form.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS returns the public keys that verifiers should currently trust,
+// including keys that are published ahead of their activation.
+func (ks *KeySet) JWKS(now time.Time) []JWK {
+	jwks := []JWK{}
+	for _, key := range ks.keys {
+		if key.retired(now) {
+			continue
+		}
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+		switch pub := key.PrivateKey.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// LeverAPI is the public Lever postings API; boards on jobs.eu.lever.co use LeverEUAPI.
+	LeverAPI   = "https://api.lever.co"
+	LeverEUAPI = "https://api.eu.lever.co"
+
+	leverPageSize = 100
+	// leverMaxPages stops a board that keeps returning full pages
+	leverMaxPages = 20
+)
+
+func init() {
+	RegisterJobSource("lever", func() (JobSource, error) {
+		return &LeverSource{}, nil
+	})
+}
+
+// LeverSource lists the postings on the Lever job sites among a query's career sites.
+// BaseURL and EUBaseURL replace the API hosts, in tests for example.
+type LeverSource struct {
+	BaseURL    string
+	EUBaseURL  string
+	PageSize   int
+	HTTPClient *http.Client
+}
+
+// leverPosting is one entry of GET /v0/postings/{site}?mode=json
+type leverPosting struct {
+	ID         string `json:"id"`
+	Text       string `json:"text"`
+	HostedURL  string `json:"hostedUrl"`
+	CreatedAt  int64  `json:"createdAt"`
+	Categories struct {
+		Commitment   string   `json:"commitment"`
+		Department   string   `json:"department"`
+		Location     string   `json:"location"`
+		Team         string   `json:"team"`
+		AllLocations []string `json:"allLocations"`
+	} `json:"categories"`
+	WorkplaceType string `json:"workplaceType"`
+}
+
+// LeverSite returns the site name of a Lever job site link such as https://jobs.lever.co/acme,
+// and whether it is hosted in the EU.
+func LeverSite(link string) (string, bool, bool) {
+	u, ok := parseCareerLink(link)
+	if !ok {
+		return "", false, false
+	}
+	var eu bool
+	switch strings.ToLower(u.Hostname()) {
+	case "jobs.lever.co":
+	case "jobs.eu.lever.co":
+		eu = true
+	default:
+		return "", false, false
+	}
+
+	site := strings.Split(strings.Trim(u.Path, "/"), "/")[0]
+	return site, eu, site != ""
+}
+
+// Name implements JobSource.
+func (s *LeverSource) Name() string { return "lever" }
+
+// Search implements JobSource. Queries without a Lever career site have nothing to search.
+func (s *LeverSource) Search(ctx context.Context, q JobQuery) ([]Job, error) {
+	var jobs []Job
+	seen := map[string]bool{}
+	for _, link := range q.CareerSites {
+		site, eu, ok := LeverSite(link)
+		if !ok || seen[site] {
+			continue
+		}
+		seen[site] = true
+
+		base := s.BaseURL
+		if base == "" {
+			base = LeverAPI
+		}
+		if eu {
+			base = s.EUBaseURL
+			if base == "" {
+				base = LeverEUAPI
+			}
+		}
+
+		postings, err := s.postings(ctx, base, site)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range postings {
+			jobs = append(jobs, s.job(p, q.Company))
+		}
+	}
+	return jobs, nil
+}
+
+// postings pages through every published posting of a site
+func (s *LeverSource) postings(ctx context.Context, base, site string) ([]leverPosting, error) {
+	size := s.PageSize
+	if size <= 0 {
+		size = leverPageSize
+	}
+	var all []leverPosting
+	for page := 0; page < leverMaxPages; page++ {
+		endpoint := fmt.Sprintf("%s/v0/postings/%s?mode=json&limit=%d&skip=%d", base, url.PathEscape(site), size, page*size)
+		var postings []leverPosting
+		if err := getJSON(ctx, s.HTTPClient, endpoint, &postings); err != nil {
+			return nil, err
+		}
+		all = append(all, postings...)
+		if len(postings) < size {
+			return all, nil
+		}
+	}
+	return all, nil
+}
+
+func (s *LeverSource) job(p leverPosting, company string) Job {
+	job := Job{
+		Source:     s.Name(),
+		ID:         p.ID,
+		Title:      p.Text,
+		Company:    company,
+		Location:   p.Categories.Location,
+		Commitment: p.Categories.Commitment,
+		URL:        p.HostedURL,
+	}
+	if len(p.Categories.AllLocations) > 1 {
+		job.Location = strings.Join(p.Categories.AllLocations, "; ")
+	}
+	for _, name := range []string{p.Categories.Department, p.Categories.Team} {
+		if name != "" && (len(job.Departments) == 0 || job.Departments[0] != name) {
+			job.Departments = append(job.Departments, name)
+		}
+	}
+	// Lever says "unspecified" when the posting does not tell
+	if p.WorkplaceType != "" && p.WorkplaceType != "unspecified" {
+		job.WorkplaceType = p.WorkplaceType
+	}
+	if p.CreatedAt > 0 {
+		created := time.UnixMilli(p.CreatedAt).UTC()
+		job.PostedAt = &created
+	}
+	return job
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLeverSite(t *testing.T) {
+	tests := []struct {
+		link string
+		site string
+		eu   bool
+		ok   bool
+	}{
+		{"https://jobs.lever.co/acme", "acme", false, true},
+		{"https://jobs.lever.co/acme/5a1f7c2e-8b3d-4e61-9c0a-2f4b6d8e1a01", "acme", false, true},
+		{"jobs.lever.co/acme?lever-via=x", "acme", false, true},
+		{"https://jobs.eu.lever.co/acme", "acme", true, true},
+		{"https://jobs.lever.co/", "", false, false},
+		{"https://boards.greenhouse.io/acme", "", false, false},
+	}
+	for _, tt := range tests {
+		site, eu, ok := LeverSite(tt.link)
+		if site != tt.site || eu != tt.eu || ok != tt.ok {
+			t.Errorf("LeverSite(%q) = %q, %v, %v; want %q, %v, %v", tt.link, site, eu, ok, tt.site, tt.eu, tt.ok)
+		}
+	}
+}
+
+func TestLeverSourceSearch(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		if r.URL.Path != "/v0/postings/acme" || r.URL.Query().Get("mode") != "json" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.URL.Query().Get("skip") {
+		case "0":
+			http.ServeFile(w, r, "testdata/lever_postings_page1.json")
+		case "2":
+			http.ServeFile(w, r, "testdata/lever_postings_page2.json")
+		default:
+			w.Write([]byte("[]"))
+		}
+	}))
+	defer server.Close()
+
+	source := &LeverSource{BaseURL: server.URL, PageSize: 2}
+	jobs, err := source.Search(context.Background(), JobQuery{
+		Company:     "Acme",
+		Role:        "Engineer",
+		CareerSites: []string{"https://jobs.lever.co/acme", "https://acme.com/careers"},
+	})
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(requests) != 2 || requests[1] != "/v0/postings/acme?mode=json&limit=2&skip=2" {
+		t.Errorf("Expected two pages to be requested, got %v", requests)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("Expected the postings of both pages, got %d", len(jobs))
+	}
+
+	first := jobs[0]
+	if first.Source != "lever" || first.ID != "5a1f7c2e-8b3d-4e61-9c0a-2f4b6d8e1a01" || first.Title != "Senior Backend Engineer" ||
+		first.Company != "Acme" || first.Location != "San Francisco, CA" || first.Commitment != "Full-time" || first.WorkplaceType != "hybrid" ||
+		first.URL != "https://jobs.lever.co/acme/5a1f7c2e-8b3d-4e61-9c0a-2f4b6d8e1a01" {
+		t.Errorf("Unexpected job: %+v", first)
+	}
+	if len(first.Departments) != 2 || first.Departments[0] != "Engineering" || first.Departments[1] != "Platform" {
+		t.Errorf("Expected the department and team, got %v", first.Departments)
+	}
+	if first.PostedAt == nil || first.PostedAt.Format("2006-01-02") != "2024-04-25" {
+		t.Errorf("Unexpected posting date: %v", first.PostedAt)
+	}
+
+	second := jobs[1]
+	if second.Location != "Remote - US; Remote - Canada" || len(second.Departments) != 1 || second.WorkplaceType != "remote" {
+		t.Errorf("Unexpected job: %+v", second)
+	}
+	if jobs[2].WorkplaceType != "" || jobs[2].Departments[0] != "Sales" {
+		t.Errorf("Expected an unspecified workplace to be left out, got %+v", jobs[2])
+	}
+}
+
+func TestLeverSourceEU(t *testing.T) {
+	var hit bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = r.URL.Path == "/v0/postings/acme"
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	source := &LeverSource{BaseURL: "http://127.0.0.1:1", EUBaseURL: server.URL}
+	if _, err := source.Search(context.Background(), JobQuery{Company: "Acme", CareerSites: []string{"https://jobs.eu.lever.co/acme"}}); err != nil || !hit {
+		t.Errorf("Expected the EU API to be used, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLinkedInSourceSearch(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		http.ServeFile(w, r, "testdata/linkedin_search.json")
+	}))
+	defer server.Close()
+
+	source := &LinkedInSource{APIKey: "secret", Endpoint: server.URL}
+	jobs, err := source.Search(context.Background(), JobQuery{Company: "Acme", Role: "Backend Engineer"})
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+
+	for _, want := range []string{"api_key=secret", "field=Backend+Engineer+AND+Acme", "geoid=" + defaultLinkedInGeoID, "sort_by=week"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("Expected %q in the query %q", want, query)
+		}
+	}
+	if len(jobs) != 4 {
+		t.Fatalf("Expected every posting to be returned unfiltered, got %d", len(jobs))
+	}
+	first := jobs[0]
+	if first.Source != "linkedin" || first.ID != "3912345678" || first.Title != "Senior Backend Engineer" || first.Company != "Acme" ||
+		first.Location != "New York, NY" || !strings.HasSuffix(first.URL, "3912345678") {
+		t.Errorf("Unexpected job: %+v", first)
+	}
+	if first.PostedAt == nil || first.PostedAt.Format("2006-01-02") != "2024-05-02" {
+		t.Errorf("Expected the posting date to be parsed, got %v", first.PostedAt)
+	}
+	if jobs[1].PostedAt != nil {
+		t.Errorf("Expected a relative date to be left out, got %v", jobs[1].PostedAt)
+	}
+}
+
+func TestLinkedInSourceErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	source := &LinkedInSource{APIKey: "secret", Endpoint: server.URL}
+	if _, err := source.Search(context.Background(), JobQuery{Company: "Acme", Role: "Engineer"}); err == nil {
+		t.Error("Expected an error for a non-200 response")
+	}
+
+	// Once the server is gone the request fails, without the key showing up in the error
+	server.Close()
+	_, err := source.Search(context.Background(), JobQuery{Company: "Acme", Role: "Engineer"})
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("Expected an error without the API key, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// MFAPendingPurpose is the audience of tokens handed out after the password step of a two-factor login.
+const MFAPendingPurpose = "mfa-pending"
+
+// MFATokenTTL is how long the second login step may take.
+const MFATokenTTL = 5 * time.Minute
+
+// IssueMFAToken signs a short-lived token saying the user passed the password step.
+// It is not an access token: the audience keeps the auth middleware from accepting it.
+func IssueMFAToken(userID int) (string, error) {
+	jti, err := NewTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return SignClaims(&jwt.RegisteredClaims{
+		ID:        jti,
+		Subject:   strconv.Itoa(userID),
+		Audience:  jwt.ClaimStrings{MFAPendingPurpose},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(MFATokenTTL)),
+		Issuer:    Issuer,
+	})
+}
+
+// ParseMFAToken verifies a token from IssueMFAToken and returns the user ID.
+func ParseMFAToken(tokenString string) (int, error) {
+	claims := &jwt.RegisteredClaims{}
+	if err := ParseClaims(tokenString, claims); err != nil {
+		return 0, err
+	}
+	if !claims.VerifyIssuer(Issuer, true) || !claims.VerifyAudience(MFAPendingPurpose, true) || claims.ExpiresAt == nil {
+		return 0, errors.New("invalid token claims")
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil || userID == 0 {
+		return 0, errors.New("invalid token subject")
+	}
+	return userID, nil
+}
@@ -0,0 +1,407 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// OIDCProvider is one configured "Sign in with" identity provider.
+// Endpoints and signing keys are discovered from the issuer on first use.
+type OIDCProvider struct {
+	Name         string   `json:"name"`
+	DisplayName  string   `json:"display_name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+
+	HTTPClient *http.Client `json:"-"`
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]crypto.PublicKey
+	keysAt    time.Time
+	// keysTriedAt is when the JWKS was last requested, whether or not that worked
+	keysTriedAt time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDTokenClaims are the ID token claims JobScoop reads.
+type IDTokenClaims struct {
+	Email         string       `json:"email"`
+	EmailVerified flexibleBool `json:"email_verified"`
+	Name          string       `json:"name"`
+	Nonce         string       `json:"nonce"`
+	AuthorizedBy  string       `json:"azp"`
+	jwt.RegisteredClaims
+}
+
+// flexibleBool accepts both true and "true", since some providers send email_verified as a string.
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	*b = flexibleBool(s == "true")
+	return nil
+}
+
+// oidcKeysMaxAge bounds how long a fetched JWKS is trusted before it is fetched again.
+const oidcKeysMaxAge = time.Hour
+
+// oidcKeysMinRefetch is how long after a JWKS fetch an unknown key id may trigger another, so
+// tokens with made up key ids cannot make the server hammer the provider.
+const oidcKeysMinRefetch = time.Minute
+
+var (
+	oidcProvidersMu sync.RWMutex
+	oidcProviders   = map[string]*OIDCProvider{}
+)
+
+// SetOIDCProviders replaces the configured identity providers.
+func SetOIDCProviders(providers ...*OIDCProvider) {
+	m := make(map[string]*OIDCProvider, len(providers))
+	for _, p := range providers {
+		m[p.Name] = p
+	}
+	oidcProvidersMu.Lock()
+	defer oidcProvidersMu.Unlock()
+	oidcProviders = m
+}
+
+// OIDCProviderByName returns the configured provider with the given name.
+func OIDCProviderByName(name string) (*OIDCProvider, bool) {
+	oidcProvidersMu.RLock()
+	defer oidcProvidersMu.RUnlock()
+	p, ok := oidcProviders[name]
+	return p, ok
+}
+
+// OIDCProviders returns the configured providers sorted by name.
+func OIDCProviders() []*OIDCProvider {
+	oidcProvidersMu.RLock()
+	defer oidcProvidersMu.RUnlock()
+	providers := make([]*OIDCProvider, 0, len(oidcProviders))
+	for _, p := range oidcProviders {
+		providers = append(providers, p)
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+	return providers
+}
+
+// LoadOIDCProviders reads a JSON array of providers from path.
+func LoadOIDCProviders(path string) ([]*OIDCProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var providers []*OIDCProvider
+	if err := json.Unmarshal(data, &providers); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for _, p := range providers {
+		// The redirect URL must match what is registered with the provider, so it is never guessed
+		if p.Name == "" || p.Issuer == "" || p.ClientID == "" || p.RedirectURL == "" {
+			return nil, fmt.Errorf("provider %q needs a name, issuer, client_id and redirect_url", p.Name)
+		}
+	}
+	return providers, nil
+}
+
+// LoadOIDCProvidersFromEnv loads the providers listed in the file named by OIDC_PROVIDERS_FILE.
+// Without it no provider is configured and only password login is available.
+func LoadOIDCProvidersFromEnv() error {
+	path := os.Getenv("OIDC_PROVIDERS_FILE")
+	if path == "" {
+		SetOIDCProviders()
+		return nil
+	}
+	providers, err := LoadOIDCProviders(path)
+	if err != nil {
+		return err
+	}
+	SetOIDCProviders(providers...)
+	return nil
+}
+
+func (p *OIDCProvider) client() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d oidcDiscovery
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, err
+	}
+	// The document must describe the issuer we were configured with, not some other one
+	if d.Issuer != p.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", d.Issuer, p.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// PKCEChallenge returns the S256 code challenge for a PKCE verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the authorization endpoint URL that starts a login.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	scopes := p.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", PKCEChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified ID token claims.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*IDTokenClaims, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", verifier)
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, err
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	return p.VerifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawToken, nonce string) (*IDTokenClaims, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &IDTokenClaims{}
+	_, err = jwt.ParseWithClaims(rawToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := p.publicKey(ctx, d.JWKSURI, kid)
+		if err != nil {
+			return nil, err
+		}
+		// The algorithm must match the key type; "none" and HMAC are never accepted
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+			}
+		case *ecdsa.PublicKey:
+			if _, ok := t.Method.(*jwt.SigningMethodECDSA); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+			}
+		case ed25519.PublicKey:
+			if _, ok := t.Method.(*jwt.SigningMethodEd25519); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+			}
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyIssuer(p.Issuer, true) || !claims.VerifyAudience(p.ClientID, true) || claims.ExpiresAt == nil || claims.Subject == "" {
+		return nil, errors.New("invalid id token claims")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedBy != p.ClientID {
+		return nil, errors.New("id token was issued to another client")
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("id token nonce does not match")
+	}
+	return claims, nil
+}
+
+// publicKey returns the provider key with the given kid, refetching the JWKS when the kid is unknown
+// (the provider rotated) or the cached set is too old. An unknown kid refetches at most once per
+// oidcKeysMinRefetch.
+func (p *OIDCProvider) publicKey(ctx context.Context, jwksURI, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fresh := p.keys != nil && time.Since(p.keysAt) < oidcKeysMaxAge
+	if key, ok := p.keys[kid]; ok && fresh {
+		return key, nil
+	}
+	if time.Since(p.keysTriedAt) < oidcKeysMinRefetch {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	p.keysTriedAt = time.Now()
+	var set struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := p.getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	p.keys, p.keysAt = keys, time.Now()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	// Providers with a single key sometimes omit the kid header
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// PublicKey decodes the JWK into an RSA, P-256 or Ed25519 public key.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.KeyType {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Curve != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decode(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"JobScoop/internal/services/oidctest"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	idp := oidctest.New("jobscoop-client")
+	defer idp.Server.Close()
+
+	provider := &OIDCProvider{
+		Name:        "fake",
+		Issuer:      idp.Issuer(),
+		ClientID:    "jobscoop-client",
+		RedirectURL: "http://localhost:8080/auth/oidc/fake/callback",
+	}
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("AuthCodeURL returned error: %v", err)
+	}
+	callback, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize returned error: %v", err)
+	}
+	if callback.Query().Get("state") != "state-1" {
+		t.Errorf("Expected the state to be echoed, got %q", callback.Query().Get("state"))
+	}
+	code := callback.Query().Get("code")
+
+	// A wrong PKCE verifier is refused by the provider
+	if _, err := provider.Exchange(ctx, code, "another-verifier", "nonce-1"); err == nil {
+		t.Error("Expected the exchange to fail with the wrong code verifier")
+	}
+
+	callback, _ = idp.Authorize(authURL)
+	claims, err := provider.Exchange(ctx, callback.Query().Get("code"), "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange returned error: %v", err)
+	}
+	if claims.Subject != "fake-subject" || claims.Email != "john@example.com" || !bool(claims.EmailVerified) {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := oidctest.New("jobscoop-client")
+	defer idp.Server.Close()
+
+	provider := &OIDCProvider{Name: "fake", Issuer: idp.Issuer(), ClientID: "jobscoop-client"}
+	other := &OIDCProvider{Name: "other", Issuer: idp.Issuer(), ClientID: "another-client"}
+	ctx := context.Background()
+
+	valid, _ := idp.SignIDToken(idp.User, "nonce-1", time.Now().Add(time.Hour))
+	expired, _ := idp.SignIDToken(idp.User, "nonce-1", time.Now().Add(-time.Minute))
+
+	if _, err := provider.VerifyIDToken(ctx, valid, "nonce-1"); err != nil {
+		t.Errorf("Expected a valid token, got %v", err)
+	}
+	if _, err := provider.VerifyIDToken(ctx, valid, "nonce-2"); err == nil {
+		t.Error("Expected a nonce mismatch to be rejected")
+	}
+	if _, err := provider.VerifyIDToken(ctx, expired, "nonce-1"); err == nil {
+		t.Error("Expected an expired token to be rejected")
+	}
+	if _, err := other.VerifyIDToken(ctx, valid, "nonce-1"); err == nil {
+		t.Error("Expected a token for another client to be rejected")
+	}
+
+	// Discovery must describe the configured issuer
+	wrongIssuer := &OIDCProvider{Name: "wrong", Issuer: idp.Issuer() + "/", ClientID: "jobscoop-client"}
+	if _, err := wrongIssuer.VerifyIDToken(ctx, valid, "nonce-1"); err == nil {
+		t.Error("Expected an issuer mismatch in discovery to be rejected")
+	}
+}
+
+func TestLoadOIDCProviders(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "providers.json")
+	os.WriteFile(path, []byte(`[
+		{"name": "google", "issuer": "https://accounts.google.com", "client_id": "a", "client_secret": "s", "redirect_url": "https://api.example.com/auth/oidc/google/callback"},
+		{"name": "gitlab", "issuer": "https://gitlab.com", "client_id": "b", "redirect_url": "https://api.example.com/auth/oidc/gitlab/callback", "scopes": ["openid", "email"]}
+	]`), 0600)
+
+	providers, err := LoadOIDCProviders(path)
+	if err != nil {
+		t.Fatalf("LoadOIDCProviders returned error: %v", err)
+	}
+	SetOIDCProviders(providers...)
+	defer SetOIDCProviders()
+
+	if p, ok := OIDCProviderByName("gitlab"); !ok || p.ClientID != "b" || len(p.Scopes) != 2 {
+		t.Errorf("Unexpected gitlab provider: %+v", p)
+	}
+	if names := OIDCProviders(); len(names) != 2 || names[0].Name != "gitlab" {
+		t.Errorf("Expected providers sorted by name, got %d", len(names))
+	}
+
+	os.WriteFile(path, []byte(`[{"name": "broken", "issuer": "https://example.com"}]`), 0600)
+	if _, err := LoadOIDCProviders(path); err == nil {
+		t.Error("Expected a provider without client_id to be rejected")
+	}
+}
+
+// countingTransport counts the requests made for each path
+type countingTransport struct {
+	mu    sync.Mutex
+	paths map[string]int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.paths[req.URL.Path]++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestVerifyIDTokenLimitsKeyRefetches(t *testing.T) {
+	idp := oidctest.New("jobscoop-client")
+	defer idp.Server.Close()
+
+	transport := &countingTransport{paths: map[string]int{}}
+	provider := &OIDCProvider{Name: "fake", Issuer: idp.Issuer(), ClientID: "jobscoop-client", HTTPClient: &http.Client{Transport: transport}}
+	ctx := context.Background()
+
+	valid, _ := idp.SignIDToken(idp.User, "nonce-1", time.Now().Add(time.Hour))
+	if _, err := provider.VerifyIDToken(ctx, valid, "nonce-1"); err != nil {
+		t.Fatalf("Expected a valid token, got %v", err)
+	}
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	for i := 0; i < 5; i++ {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"iss": idp.Issuer(), "aud": "jobscoop-client", "nonce": "nonce-1"})
+		token.Header["kid"] = fmt.Sprintf("made-up-%d", i)
+		forged, _ := token.SignedString(key)
+		if _, err := provider.VerifyIDToken(ctx, forged, "nonce-1"); err == nil {
+			t.Fatal("Expected a token with an unknown key id to be rejected")
+		}
+	}
+	if got := transport.paths["/jwks"]; got != 1 {
+		t.Errorf("Expected the key set fetched once, got %d fetches", got)
+	}
+
+	// Once the interval is over an unknown key id may refetch, the provider could have rotated
+	provider.mu.Lock()
+	provider.keysTriedAt = time.Now().Add(-oidcKeysMinRefetch)
+	provider.mu.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"iss": idp.Issuer()})
+	token.Header["kid"] = "rotated"
+	forged, _ := token.SignedString(key)
+	provider.VerifyIDToken(ctx, forged, "nonce-1")
+	if got := transport.paths["/jwks"]; got != 2 {
+		t.Errorf("Expected a refetch after the interval, got %d fetches", got)
+	}
+}
@@ -0,0 +1,455 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+const (
+	// scraperMaxPages is the most pages a config may follow, and defaultScraperPages the default
+	scraperMaxPages     = 20
+	defaultScraperPages = 5
+	// maxCareerPageSize bounds how much of a page is read
+	maxCareerPageSize = 5 << 20
+
+	scraperUserAgent = "Mozilla/5.0 (compatible; JobScoop/1.0)"
+)
+
+func init() {
+	RegisterJobSource("scraper", func() (JobSource, error) {
+		heuristics := true
+		if v, err := strconv.ParseBool(os.Getenv("SCRAPER_HEURISTICS")); err == nil {
+			heuristics = v
+		}
+		return &HTMLScraperSource{Heuristics: heuristics}, nil
+	})
+}
+
+// ScraperConfig tells the scraper where the postings are on a company's own career pages.
+// Jobs selects one element per posting; the other fields are read inside it. A field is a CSS
+// selector whose text is taken, or "selector@attr" (just "@attr" for the posting element itself)
+// to take an attribute. An empty Title is the posting element's text, an empty Link its first
+// link. NextPage is a field giving the next page of results.
+type ScraperConfig struct {
+	Domain     string `json:"domain"`
+	Jobs       string `json:"jobs"`
+	Title      string `json:"title"`
+	Link       string `json:"link"`
+	Location   string `json:"location"`
+	PostedAt   string `json:"posted_at"`
+	DateFormat string `json:"date_format"`
+	NextPage   string `json:"next_page"`
+	MaxPages   int    `json:"max_pages"`
+}
+
+var attrField = regexp.MustCompile(`^(.*?)@([A-Za-z_:][\w:.-]*)$`)
+
+// splitField splits a field into its selector and attribute
+func splitField(field string) (string, string) {
+	if m := attrField.FindStringSubmatch(strings.TrimSpace(field)); m != nil {
+		return strings.TrimSpace(m[1]), m[2]
+	}
+	return strings.TrimSpace(field), ""
+}
+
+// Validate checks that the config has a posting selector and that every selector compiles.
+func (c ScraperConfig) Validate() error {
+	if strings.TrimSpace(c.Jobs) == "" {
+		return errors.New("jobs selector is required")
+	}
+	if _, err := cascadia.Compile(c.Jobs); err != nil {
+		return fmt.Errorf("jobs: %w", err)
+	}
+	fields := []struct{ name, value string }{
+		{"title", c.Title}, {"link", c.Link}, {"location", c.Location}, {"posted_at", c.PostedAt}, {"next_page", c.NextPage},
+	}
+	for _, f := range fields {
+		selector, _ := splitField(f.value)
+		if selector == "" {
+			continue
+		}
+		if _, err := cascadia.Compile(selector); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	if c.MaxPages < 0 || c.MaxPages > scraperMaxPages {
+		return fmt.Errorf("max_pages must be between 0 and %d", scraperMaxPages)
+	}
+	return nil
+}
+
+var (
+	scraperConfigsMu sync.RWMutex
+	scraperConfigs   = map[string]ScraperConfig{}
+)
+
+// SetScraperConfigs replaces the per-domain scraper configs.
+func SetScraperConfigs(configs ...ScraperConfig) {
+	m := make(map[string]ScraperConfig, len(configs))
+	for _, c := range configs {
+		m[normalizeDomain(c.Domain)] = c
+	}
+	scraperConfigsMu.Lock()
+	defer scraperConfigsMu.Unlock()
+	scraperConfigs = m
+}
+
+// ScraperConfigFor returns the config for host, or for the closest parent domain that has one.
+func ScraperConfigFor(host string) (ScraperConfig, bool) {
+	scraperConfigsMu.RLock()
+	defer scraperConfigsMu.RUnlock()
+	for domain := normalizeDomain(host); domain != ""; {
+		if c, ok := scraperConfigs[domain]; ok {
+			return c, true
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+	return ScraperConfig{}, false
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+}
+
+// LoadScraperConfigs reads a JSON array of scraper configs from path.
+func LoadScraperConfigs(path string) ([]ScraperConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs []ScraperConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for _, c := range configs {
+		if normalizeDomain(c.Domain) == "" {
+			return nil, errors.New("every scraper config needs a domain")
+		}
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("scraper config for %s: %w", c.Domain, err)
+		}
+	}
+	return configs, nil
+}
+
+// LoadScraperConfigsFromEnv loads the configs in the file named by SCRAPER_CONFIG_FILE. Without
+// it career pages are only scraped with heuristics.
+func LoadScraperConfigsFromEnv() error {
+	path := os.Getenv("SCRAPER_CONFIG_FILE")
+	if path == "" {
+		SetScraperConfigs()
+		return nil
+	}
+	configs, err := LoadScraperConfigs(path)
+	if err != nil {
+		return err
+	}
+	SetScraperConfigs(configs...)
+	return nil
+}
+
+// HTMLScraperSource scrapes the career sites of a query that no ATS source handles. Domains with
+// a ScraperConfig are scraped with it; the others only when Heuristics is set.
+type HTMLScraperSource struct {
+	Heuristics bool
+	HTTPClient *http.Client
+}
+
+// Name implements JobSource.
+func (s *HTMLScraperSource) Name() string { return "scraper" }
+
+// Search implements JobSource.
+func (s *HTMLScraperSource) Search(ctx context.Context, q JobQuery) ([]Job, error) {
+	var jobs []Job
+	for _, link := range q.CareerSites {
+		u, ok := parseCareerLink(link)
+		if !ok || atsLink(link) {
+			continue
+		}
+		var config *ScraperConfig
+		if c, ok := ScraperConfigFor(u.Hostname()); ok {
+			config = &c
+		} else if !s.Heuristics {
+			continue
+		}
+
+		found, err := ScrapeCareerPage(ctx, s.HTTPClient, u.String(), config)
+		if err != nil {
+			return nil, fmt.Errorf("scraping %s: %w", u, err)
+		}
+		for _, job := range found {
+			job.Company = q.Company
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// atsLink reports whether a link is a job board one of the ATS sources reads
+func atsLink(link string) bool {
+	if _, ok := GreenhouseBoardToken(link); ok {
+		return true
+	}
+	if _, _, ok := LeverSite(link); ok {
+		return true
+	}
+	if _, ok := AshbyBoard(link); ok {
+		return true
+	}
+	if _, ok := SmartRecruitersCompany(link); ok {
+		return true
+	}
+	_, ok := ParseWorkdaySite(link)
+	return ok
+}
+
+// VerifiedCareerSite reports whether the postings at link are searched for every subscriber of
+// its company: it is a job board one of the ATS sources reads, or on a domain an admin configured
+// a scraper for. Other career pages are only searched for the subscriptions that list them.
+func VerifiedCareerSite(link string) bool {
+	if atsLink(link) {
+		return true
+	}
+	u, ok := parseCareerLink(link)
+	if !ok {
+		return false
+	}
+	_, ok = ScraperConfigFor(u.Hostname())
+	return ok
+}
+
+// ScrapeCareerPage extracts the postings on the career page at link, following config's next
+// page links. Without a config it guesses the postings from the page's links.
+func ScrapeCareerPage(ctx context.Context, client *http.Client, link string, config *ScraperConfig) ([]Job, error) {
+	if config == nil {
+		doc, base, err := getHTML(ctx, client, link)
+		if err != nil {
+			return nil, err
+		}
+		return heuristicJobs(doc, base), nil
+	}
+
+	pages := config.MaxPages
+	if pages <= 0 {
+		pages = defaultScraperPages
+	}
+	var jobs []Job
+	seen := map[string]bool{}
+	visited := map[string]bool{}
+	for page := 0; page < pages && link != "" && !visited[link]; page++ {
+		visited[link] = true
+		doc, base, err := getHTML(ctx, client, link)
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range configJobs(doc, base, *config) {
+			if !seen[job.Key()] {
+				seen[job.Key()] = true
+				jobs = append(jobs, job)
+			}
+		}
+
+		link = ""
+		if config.NextPage != "" {
+			link = resolveLink(base, fieldValue(doc.Selection, config.NextPage, "href"))
+		}
+	}
+	return jobs, nil
+}
+
+// configJobs reads the postings of one page with a config
+func configJobs(doc *goquery.Document, base *url.URL, config ScraperConfig) []Job {
+	var jobs []Job
+	doc.Find(config.Jobs).Each(func(_ int, el *goquery.Selection) {
+		link := config.Link
+		if link == "" {
+			link = "a@href"
+			if goquery.NodeName(el) == "a" {
+				link = "@href"
+			}
+		}
+		title := collapseSpace(el.Text())
+		if config.Title != "" {
+			title = fieldValue(el, config.Title, "")
+		}
+		job := Job{
+			Source:   "scraper",
+			Title:    title,
+			Location: fieldValue(el, config.Location, ""),
+			URL:      resolveLink(base, fieldValue(el, link, "href")),
+			PostedAt: parseDate(fieldValue(el, config.PostedAt, ""), config.DateFormat),
+		}
+		if job.Title != "" && job.URL != "" {
+			jobs = append(jobs, job)
+		}
+	})
+	return jobs
+}
+
+// fieldValue reads a config field inside el. A field without an attribute is read as text,
+// unless it was given a default attribute, which NextPage and Link fields are (href).
+// An empty field reads nothing.
+func fieldValue(el *goquery.Selection, field, defaultAttr string) string {
+	if strings.TrimSpace(field) == "" {
+		return ""
+	}
+	selector, attr := splitField(field)
+	if selector != "" {
+		el = el.Find(selector).First()
+	}
+	if attr == "" {
+		attr = defaultAttr
+	}
+	if attr == "" {
+		return collapseSpace(el.Text())
+	}
+	value, _ := el.Attr(attr)
+	return strings.TrimSpace(value)
+}
+
+var (
+	// jobPathPattern matches the path of a link to a single posting
+	jobPathPattern = regexp.MustCompile(`(?i)/(jobs?|careers?|positions?|openings?|vacanc(y|ies)|opportunit(y|ies)|requisitions?)/[^/?#]+`)
+
+	heuristicContainer = "li, tr, article, [class*=job], [class*=position], [class*=opening], [class*=posting]"
+)
+
+// heuristicJobs takes every link whose path looks like a posting, and the location and date
+// shown next to it
+func heuristicJobs(doc *goquery.Document, base *url.URL) []Job {
+	var jobs []Job
+	seen := map[string]bool{}
+	doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		link := resolveLink(base, href)
+		if link == "" || seen[link] {
+			return
+		}
+		u, err := url.Parse(link)
+		if err != nil || !jobPathPattern.MatchString(u.Path) || strings.TrimRight(link, "/") == strings.TrimRight(base.String(), "/") {
+			return
+		}
+
+		title := collapseSpace(a.Find("h1, h2, h3, h4, h5").First().Text())
+		if title == "" {
+			title = collapseSpace(a.Text())
+		}
+		if len(title) < 4 || len(title) > 150 {
+			return
+		}
+		seen[link] = true
+
+		job := Job{Source: "scraper", Title: title, URL: link}
+		container := a.Closest(heuristicContainer)
+		if container.Length() == 0 {
+			container = a
+		}
+		job.Location = collapseSpace(container.Find("[class*=location]").First().Text())
+		if datetime, ok := container.Find("time[datetime]").First().Attr("datetime"); ok {
+			job.PostedAt = parseDate(datetime, "")
+		}
+		jobs = append(jobs, job)
+	})
+	return jobs
+}
+
+// getHTML fetches and parses a page, returning the URL its relative links resolve against
+func getHTML(ctx context.Context, client *http.Client, link string) (*goquery.Document, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", scraperUserAgent)
+	resp, err := sourceClient(client).Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("GET %s: status %d", link, resp.StatusCode)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, maxCareerPageSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	base := resp.Request.URL
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if u, err := base.Parse(strings.TrimSpace(href)); err == nil {
+			base = u
+		}
+	}
+	return doc, base, nil
+}
+
+// resolveLink makes href absolute, dropping links that go nowhere useful
+func resolveLink(base *url.URL, href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return ""
+	}
+	u, err := base.Parse(href)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// dateLayouts are the date formats career pages commonly use
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"02 Jan 2006",
+}
+
+// parseDate parses a posting date with layout, or any of the common formats when it is empty
+func parseDate(s, layout string) *time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	layouts := dateLayouts
+	if layout != "" {
+		layouts = []string{layout}
+	}
+	for _, l := range layouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
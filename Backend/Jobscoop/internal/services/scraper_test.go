@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// acmeCareers serves the careers fixtures, two pages of them at /careers
+func acmeCareers(t *testing.T) (*httptest.Server, *[]string) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		switch {
+		case r.URL.Path == "/careers" && r.URL.Query().Get("page") == "2":
+			http.ServeFile(w, r, "testdata/careers_page2.html")
+		case r.URL.Path == "/careers":
+			http.ServeFile(w, r, "testdata/careers_page1.html")
+		case r.URL.Path == "/join":
+			http.ServeFile(w, r, "testdata/careers_heuristic.html")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+var acmeConfig = ScraperConfig{
+	Jobs:       "li.opening",
+	Title:      "a.opening-title",
+	Location:   ".opening-location",
+	PostedAt:   ".opening-date",
+	DateFormat: "January 2, 2006",
+	NextPage:   "a.next",
+}
+
+func TestScrapeCareerPageWithConfig(t *testing.T) {
+	server, requests := acmeCareers(t)
+
+	config := acmeConfig
+	jobs, err := ScrapeCareerPage(context.Background(), nil, server.URL+"/careers", &config)
+	if err != nil {
+		t.Fatalf("ScrapeCareerPage returned error: %v", err)
+	}
+	if len(*requests) != 2 || (*requests)[1] != "/careers?page=2" {
+		t.Errorf("Expected both pages to be fetched once, got %v", *requests)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("Expected the postings of both pages once each, got %+v", jobs)
+	}
+
+	first := jobs[0]
+	if first.Source != "scraper" || first.Title != "Backend Engineer" || first.Location != "Berlin, Germany" ||
+		first.URL != server.URL+"/careers/backend-engineer" {
+		t.Errorf("Unexpected job: %+v", first)
+	}
+	if first.PostedAt == nil || first.PostedAt.Format("2006-01-02") != "2024-03-04" {
+		t.Errorf("Unexpected posting date: %v", first.PostedAt)
+	}
+	if jobs[1].Title != "Product Designer" || jobs[2].Title != "Data Engineer" {
+		t.Errorf("Unexpected titles: %q, %q", jobs[1].Title, jobs[2].Title)
+	}
+}
+
+func TestScrapeCareerPageWithHeuristics(t *testing.T) {
+	server, _ := acmeCareers(t)
+
+	jobs, err := ScrapeCareerPage(context.Background(), nil, server.URL+"/join", nil)
+	if err != nil {
+		t.Fatalf("ScrapeCareerPage returned error: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("Expected the two posting links, got %+v", jobs)
+	}
+
+	first := jobs[0]
+	if first.Title != "Site Reliability Engineer" || first.Location != "Austin, TX" ||
+		first.URL != "https://www.acme.com/jobs/1042-site-reliability-engineer" {
+		t.Errorf("Unexpected job: %+v", first)
+	}
+	if first.PostedAt == nil || first.PostedAt.Format("2006-01-02") != "2024-04-12" {
+		t.Errorf("Unexpected posting date: %v", first.PostedAt)
+	}
+	if jobs[1].Title != "Account Executive" || jobs[1].URL != "https://www.acme.com/jobs/1043-account-executive" {
+		t.Errorf("Unexpected job: %+v", jobs[1])
+	}
+}
+
+func TestHTMLScraperSourceSearch(t *testing.T) {
+	server, requests := acmeCareers(t)
+	defer SetScraperConfigs()
+
+	config := acmeConfig
+	config.Domain = "127.0.0.1"
+	config.NextPage = ""
+	SetScraperConfigs(config)
+
+	q := JobQuery{
+		Company:     "Acme",
+		Role:        "Engineer",
+		CareerSites: []string{server.URL + "/careers", "https://jobs.lever.co/acme", "boards.greenhouse.io/acme"},
+	}
+	source := &HTMLScraperSource{}
+	jobs, err := source.Search(context.Background(), q)
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(*requests) != 1 || len(jobs) != 2 || jobs[0].Company != "Acme" {
+		t.Errorf("Expected the configured page only, got %v and %+v", *requests, jobs)
+	}
+
+	// Without a config the page is only scraped with heuristics
+	SetScraperConfigs()
+	if jobs, err := source.Search(context.Background(), q); err != nil || jobs != nil {
+		t.Errorf("Expected nothing to search, got %v, %v", jobs, err)
+	}
+	source.Heuristics = true
+	q.CareerSites = []string{server.URL + "/join"}
+	if jobs, err := source.Search(context.Background(), q); err != nil || len(jobs) != 2 {
+		t.Errorf("Expected the heuristic postings, got %v, %v", jobs, err)
+	}
+}
+
+func TestScraperConfigFor(t *testing.T) {
+	defer SetScraperConfigs()
+	SetScraperConfigs(ScraperConfig{Domain: "www.acme.com", Jobs: "li"}, ScraperConfig{Domain: "careers.globex.com", Jobs: "tr"})
+
+	tests := []struct {
+		host string
+		jobs string
+	}{
+		{"acme.com", "li"},
+		{"www.acme.com", "li"},
+		{"jobs.acme.com", "li"},
+		{"careers.globex.com", "tr"},
+		{"globex.com", ""},
+		{"initech.com", ""},
+	}
+	for _, tt := range tests {
+		config, ok := ScraperConfigFor(tt.host)
+		if ok != (tt.jobs != "") || config.Jobs != tt.jobs {
+			t.Errorf("ScraperConfigFor(%q) = %+v, %v; want jobs %q", tt.host, config, ok, tt.jobs)
+		}
+	}
+}
+
+func TestLoadScraperConfigs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "scrapers.json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	configs, err := LoadScraperConfigs(write(`[{"domain": "acme.com", "jobs": "li.opening", "link": "a@href", "posted_at": "time@datetime", "max_pages": 3}]`))
+	if err != nil || len(configs) != 1 || configs[0].PostedAt != "time@datetime" || configs[0].MaxPages != 3 {
+		t.Errorf("Unexpected configs: %+v, %v", configs, err)
+	}
+
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{`[{"jobs": "li"}]`, "needs a domain"},
+		{`[{"domain": "acme.com"}]`, "jobs selector is required"},
+		{`[{"domain": "acme.com", "jobs": "li[", "title": "a"}]`, "jobs"},
+		{`[{"domain": "acme.com", "jobs": "li", "location": "span:nope@title"}]`, "location"},
+		{`[{"domain": "acme.com", "jobs": "li", "max_pages": 100}]`, "max_pages"},
+	}
+	for _, tt := range tests {
+		if _, err := LoadScraperConfigs(write(tt.content)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadScraperConfigs(%s) = %v; want an error about %s", tt.content, err, tt.want)
+		}
+	}
+}
+
+func TestVerifiedCareerSite(t *testing.T) {
+	defer SetScraperConfigs()
+	SetScraperConfigs(ScraperConfig{Domain: "acme.com", Jobs: "li.opening"})
+
+	for link, verified := range map[string]bool{
+		"https://boards.greenhouse.io/acme":      true,
+		"jobs.lever.co/acme":                     true,
+		"https://careers.acme.com/open-roles":    true,
+		"https://initech.com/jobs":               false,
+		"https://acme.com.attacker.example/jobs": false,
+		"not a link":                             false,
+	} {
+		if got := VerifiedCareerSite(link); got != verified {
+			t.Errorf("VerifiedCareerSite(%q) = %v, want %v", link, got, verified)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// SmartRecruitersAPI is the public SmartRecruiters posting API.
+	SmartRecruitersAPI = "https://api.smartrecruiters.com"
+
+	smartRecruitersPageSize = 100
+	// smartRecruitersMaxPages stops a company whose total keeps growing
+	smartRecruitersMaxPages = 20
+)
+
+func init() {
+	RegisterJobSource("smartrecruiters", func() (JobSource, error) {
+		return &SmartRecruitersSource{}, nil
+	})
+}
+
+// SmartRecruitersSource lists the postings on the SmartRecruiters career sites among a query's
+// career sites. BaseURL replaces the API host, in tests for example.
+type SmartRecruitersSource struct {
+	BaseURL    string
+	PageSize   int
+	HTTPClient *http.Client
+}
+
+// smartRecruitersPage is the response of GET /v1/companies/{company}/postings
+type smartRecruitersPage struct {
+	TotalFound int `json:"totalFound"`
+	Content    []struct {
+		ID           string `json:"id"`
+		Name         string `json:"name"`
+		ReleasedDate string `json:"releasedDate"`
+		Location     struct {
+			City         string `json:"city"`
+			Region       string `json:"region"`
+			Country      string `json:"country"`
+			FullLocation string `json:"fullLocation"`
+			Remote       bool   `json:"remote"`
+			Hybrid       bool   `json:"hybrid"`
+		} `json:"location"`
+		Department struct {
+			Label string `json:"label"`
+		} `json:"department"`
+		TypeOfEmployment struct {
+			Label string `json:"label"`
+		} `json:"typeOfEmployment"`
+	} `json:"content"`
+}
+
+// SmartRecruitersCompany returns the company identifier of a SmartRecruiters career site link
+// such as https://jobs.smartrecruiters.com/Acme or https://careers.smartrecruiters.com/Acme.
+func SmartRecruitersCompany(link string) (string, bool) {
+	u, ok := parseCareerLink(link)
+	if !ok {
+		return "", false
+	}
+	switch strings.ToLower(u.Hostname()) {
+	case "jobs.smartrecruiters.com", "careers.smartrecruiters.com":
+	default:
+		return "", false
+	}
+	company := strings.Split(strings.Trim(u.Path, "/"), "/")[0]
+	return company, company != ""
+}
+
+// Name implements JobSource.
+func (s *SmartRecruitersSource) Name() string { return "smartrecruiters" }
+
+// Search implements JobSource. Queries without a SmartRecruiters career site have nothing to search.
+func (s *SmartRecruitersSource) Search(ctx context.Context, q JobQuery) ([]Job, error) {
+	var jobs []Job
+	seen := map[string]bool{}
+	for _, link := range q.CareerSites {
+		company, ok := SmartRecruitersCompany(link)
+		if !ok || seen[strings.ToLower(company)] {
+			continue
+		}
+		seen[strings.ToLower(company)] = true
+
+		found, err := s.postings(ctx, company, q.Company)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, found...)
+	}
+	return jobs, nil
+}
+
+// postings pages through every published posting of a company
+func (s *SmartRecruitersSource) postings(ctx context.Context, company, name string) ([]Job, error) {
+	base, size := s.BaseURL, s.PageSize
+	if base == "" {
+		base = SmartRecruitersAPI
+	}
+	if size <= 0 {
+		size = smartRecruitersPageSize
+	}
+
+	var jobs []Job
+	for page, offset := 0, 0; page < smartRecruitersMaxPages; page++ {
+		endpoint := fmt.Sprintf("%s/v1/companies/%s/postings?limit=%d&offset=%d", base, url.PathEscape(company), size, offset)
+		var resp smartRecruitersPage
+		if err := getJSON(ctx, s.HTTPClient, endpoint, &resp); err != nil {
+			return nil, err
+		}
+		for _, p := range resp.Content {
+			job := Job{
+				Source:     s.Name(),
+				ID:         p.ID,
+				Title:      p.Name,
+				Company:    name,
+				Location:   p.Location.FullLocation,
+				Commitment: p.TypeOfEmployment.Label,
+				URL:        "https://jobs.smartrecruiters.com/" + url.PathEscape(company) + "/" + url.PathEscape(p.ID),
+			}
+			if job.Location == "" {
+				var parts []string
+				for _, part := range []string{p.Location.City, p.Location.Region, strings.ToUpper(p.Location.Country)} {
+					if part != "" {
+						parts = append(parts, part)
+					}
+				}
+				job.Location = strings.Join(parts, ", ")
+			}
+			if p.Department.Label != "" {
+				job.Departments = []string{p.Department.Label}
+			}
+			switch {
+			case p.Location.Remote:
+				job.WorkplaceType = "remote"
+			case p.Location.Hybrid:
+				job.WorkplaceType = "hybrid"
+			}
+			if released, err := time.Parse(time.RFC3339, p.ReleasedDate); err == nil {
+				job.PostedAt = &released
+			}
+			jobs = append(jobs, job)
+		}
+		offset += len(resp.Content)
+		if len(resp.Content) < size || offset >= resp.TotalFound {
+			break
+		}
+	}
+	return jobs, nil
+}
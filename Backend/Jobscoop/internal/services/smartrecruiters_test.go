@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSmartRecruitersCompany(t *testing.T) {
+	tests := []struct {
+		link    string
+		company string
+		ok      bool
+	}{
+		{"https://jobs.smartrecruiters.com/Acme", "Acme", true},
+		{"https://jobs.smartrecruiters.com/Acme/744000012345678-backend-engineer", "Acme", true},
+		{"careers.smartrecruiters.com/Acme/", "Acme", true},
+		{"https://jobs.smartrecruiters.com/", "", false},
+		{"https://jobs.ashbyhq.com/acme", "", false},
+	}
+	for _, tt := range tests {
+		company, ok := SmartRecruitersCompany(tt.link)
+		if company != tt.company || ok != tt.ok {
+			t.Errorf("SmartRecruitersCompany(%q) = %q, %v; want %q, %v", tt.link, company, ok, tt.company, tt.ok)
+		}
+	}
+}
+
+func TestSmartRecruitersSourceSearch(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		if r.URL.Path != "/v1/companies/Acme/postings" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.URL.Query().Get("offset") {
+		case "0":
+			http.ServeFile(w, r, "testdata/smartrecruiters_postings_page1.json")
+		case "2":
+			http.ServeFile(w, r, "testdata/smartrecruiters_postings_page2.json")
+		default:
+			w.Write([]byte(`{"totalFound": 3, "content": []}`))
+		}
+	}))
+	defer server.Close()
+
+	source := &SmartRecruitersSource{BaseURL: server.URL, PageSize: 2}
+	jobs, err := source.Search(context.Background(), JobQuery{
+		Company:     "Acme",
+		Role:        "Engineer",
+		CareerSites: []string{"https://jobs.smartrecruiters.com/Acme", "https://careers.smartrecruiters.com/acme"},
+	})
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(requests) != 2 || requests[1] != "/v1/companies/Acme/postings?limit=2&offset=2" {
+		t.Errorf("Expected two pages to be requested once, got %v", requests)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("Expected the postings of both pages, got %d", len(jobs))
+	}
+
+	first := jobs[0]
+	if first.Source != "smartrecruiters" || first.ID != "744000012345678" || first.Title != "Backend Engineer" ||
+		first.Company != "Acme" || first.Location != "Berlin, Berlin, Germany" || first.Commitment != "Full-time" || first.WorkplaceType != "hybrid" ||
+		first.URL != "https://jobs.smartrecruiters.com/Acme/744000012345678" {
+		t.Errorf("Unexpected job: %+v", first)
+	}
+	if len(first.Departments) != 1 || first.Departments[0] != "Engineering" {
+		t.Errorf("Expected the department, got %v", first.Departments)
+	}
+	if first.PostedAt == nil || first.PostedAt.Format("2006-01-02") != "2024-05-06" {
+		t.Errorf("Unexpected posting date: %v", first.PostedAt)
+	}
+
+	second := jobs[1]
+	if second.Location != "Lisbon, PT" || second.WorkplaceType != "remote" || second.Departments != nil {
+		t.Errorf("Unexpected job: %+v", second)
+	}
+	if jobs[2].Title != "Data Engineer" || jobs[2].Commitment != "Contract" {
+		t.Errorf("Unexpected job: %+v", jobs[2])
+	}
+}
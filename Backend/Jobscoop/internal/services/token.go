@@ -0,0 +1,158 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Issuer is the iss claim of every token signed by JobScoop.
+const Issuer = "jobscoop"
+
+// AccessTokenTTL is how long an access token stays valid.
+const AccessTokenTTL = 15 * time.Minute
+
+// Claims are the JobScoop claims carried by every access token. Generation is the user's
+// session generation when the token was issued; logging out all sessions moves it on.
+type Claims struct {
+	UserID     int `json:"user_id"`
+	Generation int `json:"gen,omitempty"`
+	jwt.RegisteredClaims
+}
+
+var (
+	keySetMu sync.RWMutex
+	keySet   *KeySet
+)
+
+// SetKeySet replaces the key set used to sign and verify tokens.
+func SetKeySet(ks *KeySet) {
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+	keySet = ks
+}
+
+func currentKeySet() (*KeySet, error) {
+	keySetMu.RLock()
+	defer keySetMu.RUnlock()
+	if keySet == nil {
+		return nil, errors.New("token signing keys are not loaded")
+	}
+	return keySet, nil
+}
+
+// LoadKeySetFromEnv loads the signing keys from the directory in JWT_KEY_DIR.
+func LoadKeySetFromEnv() error {
+	dir := os.Getenv("JWT_KEY_DIR")
+	if dir == "" {
+		return errors.New("JWT_KEY_DIR is not set")
+	}
+	ks, err := LoadKeySet(dir)
+	if err != nil {
+		return err
+	}
+	SetKeySet(ks)
+	return nil
+}
+
+// JWKS returns the currently published public keys.
+func JWKS() ([]JWK, error) {
+	ks, err := currentKeySet()
+	if err != nil {
+		return nil, err
+	}
+	return ks.JWKS(time.Now()), nil
+}
+
+// SignClaims signs claims with the active key and sets the kid header.
+func SignClaims(claims jwt.Claims) (string, error) {
+	ks, err := currentKeySet()
+	if err != nil {
+		return "", err
+	}
+	key, err := ks.signingKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// ParseClaims verifies a token signed by SignClaims and decodes it into claims.
+func ParseClaims(tokenString string, claims jwt.Claims) error {
+	ks, err := currentKeySet()
+	if err != nil {
+		return err
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := ks.verificationKey(kid, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		// The algorithm is pinned by the key, never taken from the token header
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return key.PrivateKey.Public(), nil
+	})
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+// NewTokenID returns a random jti.
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// IssueAccessToken signs a short-lived access token for the user in the given session
+// generation, with a unique jti.
+func IssueAccessToken(userID, generation int) (string, error) {
+	jti, err := NewTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return SignClaims(&Claims{
+		UserID:     userID,
+		Generation: generation,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+			Issuer:    Issuer,
+		},
+	})
+}
+
+// ParseAccessToken verifies the signature, expiry and issuer of an access token and returns its claims.
+func ParseAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	if err := ParseClaims(tokenString, claims); err != nil {
+		return nil, err
+	}
+	// Access tokens never carry an audience; that keeps purpose-scoped tokens from being replayed as logins
+	if !claims.VerifyIssuer(Issuer, true) || len(claims.Audience) != 0 || claims.UserID == 0 || claims.ID == "" || claims.IssuedAt == nil || claims.ExpiresAt == nil {
+		return nil, errors.New("invalid token claims")
+	}
+	return claims, nil
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func writePEM(t *testing.T, path string, key interface{}) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Could not marshal key: %v", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Could not write key: %v", err)
+	}
+}
+
+func TestIssueAndParseAccessToken(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	for _, key := range []*SigningKey{
+		{ID: "ed", Method: jwt.SigningMethodEdDSA, PrivateKey: edKey},
+		{ID: "rsa", Method: jwt.SigningMethodRS256, PrivateKey: rsaKey},
+	} {
+		t.Run(key.Method.Alg(), func(t *testing.T) {
+			SetKeySet(NewKeySet(key))
+
+			signed, err := IssueAccessToken(7, 0)
+			if err != nil {
+				t.Fatalf("IssueAccessToken failed: %v", err)
+			}
+
+			parsed, _, err := new(jwt.Parser).ParseUnverified(signed, &Claims{})
+			if err != nil {
+				t.Fatalf("Could not decode token: %v", err)
+			}
+			if parsed.Header["kid"] != key.ID || parsed.Header["alg"] != key.Method.Alg() {
+				t.Errorf("Unexpected header %v", parsed.Header)
+			}
+
+			claims, err := ParseAccessToken(signed)
+			if err != nil {
+				t.Fatalf("ParseAccessToken failed: %v", err)
+			}
+			if claims.UserID != 7 || claims.ID == "" {
+				t.Errorf("Unexpected claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestParseAccessTokenRejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	SetKeySet(NewKeySet(&SigningKey{ID: "rsa", Method: jwt.SigningMethodRS256, PrivateKey: rsaKey}))
+
+	// An HS256 token "signed" with the published public key must not verify
+	pubDER, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID: 7,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			Issuer:    Issuer,
+		},
+	})
+	token.Header["kid"] = "rsa"
+	signed, _ := token.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+
+	if _, err := ParseAccessToken(signed); err == nil {
+		t.Error("Expected HS256 token to be rejected")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	_, oldKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, currentKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, nextKey, _ := ed25519.GenerateKey(rand.Reader)
+	now := time.Now()
+
+	old := &SigningKey{ID: "old", Method: jwt.SigningMethodEdDSA, PrivateKey: oldKey, ActiveFrom: now.Add(-48 * time.Hour), RetireAt: now.Add(time.Hour)}
+	SetKeySet(NewKeySet(old))
+	oldToken, err := IssueAccessToken(1, 0)
+	if err != nil {
+		t.Fatalf("IssueAccessToken failed: %v", err)
+	}
+
+	SetKeySet(NewKeySet(
+		old,
+		&SigningKey{ID: "current", Method: jwt.SigningMethodEdDSA, PrivateKey: currentKey, ActiveFrom: now.Add(-time.Hour)},
+		&SigningKey{ID: "next", Method: jwt.SigningMethodEdDSA, PrivateKey: nextKey, ActiveFrom: now.Add(24 * time.Hour)},
+	))
+
+	// New tokens use the newest active key, not the pre-published one
+	signed, _ := IssueAccessToken(1, 0)
+	parsed, _, _ := new(jwt.Parser).ParseUnverified(signed, &Claims{})
+	if parsed.Header["kid"] != "current" {
+		t.Errorf("Expected kid 'current', got %v", parsed.Header["kid"])
+	}
+
+	// Tokens from the previous key keep verifying during the overlap window
+	if _, err := ParseAccessToken(oldToken); err != nil {
+		t.Errorf("Expected token from overlapping key to verify, got %v", err)
+	}
+
+	jwks, _ := JWKS()
+	if len(jwks) != 3 {
+		t.Errorf("Expected 3 published keys, got %d", len(jwks))
+	}
+
+	// Once retired, the old key is neither published nor accepted
+	old.RetireAt = now.Add(-time.Minute)
+	if _, err := ParseAccessToken(oldToken); err == nil {
+		t.Error("Expected token from retired key to be rejected")
+	}
+	jwks, _ = JWKS()
+	if len(jwks) != 2 {
+		t.Errorf("Expected 2 published keys, got %d", len(jwks))
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writePEM(t, filepath.Join(dir, "a.pem"), rsaKey)
+	writePEM(t, filepath.Join(dir, "b.pem"), edKey)
+
+	t.Run("Without Manifest", func(t *testing.T) {
+		ks, err := LoadKeySet(dir)
+		if err != nil {
+			t.Fatalf("LoadKeySet failed: %v", err)
+		}
+		key, _ := ks.signingKey(time.Now())
+		if key.ID != "b" || key.Method != jwt.SigningMethodEdDSA {
+			t.Errorf("Expected key 'b' to sign, got %s", key.ID)
+		}
+
+		body, _ := json.Marshal(ks.JWKS(time.Now()))
+		var jwks []map[string]string
+		json.Unmarshal(body, &jwks)
+		if jwks[0]["kty"] != "RSA" || jwks[0]["n"] == "" || jwks[1]["kty"] != "OKP" || jwks[1]["crv"] != "Ed25519" {
+			t.Errorf("Unexpected JWKS %s", body)
+		}
+	})
+
+	t.Run("With Manifest", func(t *testing.T) {
+		manifest := `[
+			{"kid": "2025-01", "file": "b.pem", "active_from": "2025-01-01T00:00:00Z"},
+			{"kid": "2099-01", "file": "a.pem", "active_from": "2099-01-01T00:00:00Z"}
+		]`
+		os.WriteFile(filepath.Join(dir, "keys.json"), []byte(manifest), 0600)
+		defer os.Remove(filepath.Join(dir, "keys.json"))
+
+		ks, err := LoadKeySet(dir)
+		if err != nil {
+			t.Fatalf("LoadKeySet failed: %v", err)
+		}
+		key, _ := ks.signingKey(time.Now())
+		if key.ID != "2025-01" {
+			t.Errorf("Expected key '2025-01' to sign, got %s", key.ID)
+		}
+	})
+
+	t.Run("Empty Directory", func(t *testing.T) {
+		if _, err := LoadKeySet(t.TempDir()); err == nil {
+			t.Error("Expected an error for a directory without keys")
+		}
+	})
+}
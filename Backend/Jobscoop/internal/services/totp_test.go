@@ -0,0 +1,83 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// SHA1 vectors from RFC 6238 appendix B, truncated to six digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{unix: 59, expected: "287082"},
+		{unix: 1111111109, expected: "081804"},
+		{unix: 1234567890, expected: "005924"},
+		{unix: 2000000000, expected: "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode returned error: %v", err)
+		}
+		if code != tt.expected {
+			t.Errorf("At %d expected %s, got %s", tt.unix, tt.expected, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret returned error: %v", err)
+	}
+	now := time.Now()
+	current := TOTPStep(now)
+
+	previous, _ := TOTPCode(secret, current-1)
+	if step, ok := ValidateTOTP(secret, previous, now); !ok || step != current-1 {
+		t.Errorf("Expected the previous period to be accepted, got step %d ok %v", step, ok)
+	}
+	stale, _ := TOTPCode(secret, current-3)
+	if _, ok := ValidateTOTP(secret, stale, now); ok {
+		t.Error("Expected a code three periods old to be rejected")
+	}
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Error("Expected a short code to be rejected")
+	}
+
+	uri := TOTPURI(secret, "john@example.com")
+	if !strings.HasPrefix(uri, "otpauth://totp/JobScoop:john@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("Unexpected otpauth URI: %s", uri)
+	}
+}
+
+func TestMFATokenIsNotAnAccessToken(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	SetKeySet(NewKeySet(&SigningKey{ID: "test", Method: jwt.SigningMethodEdDSA, PrivateKey: key}))
+
+	token, err := IssueMFAToken(7)
+	if err != nil {
+		t.Fatalf("IssueMFAToken returned error: %v", err)
+	}
+	if userID, err := ParseMFAToken(token); err != nil || userID != 7 {
+		t.Errorf("Expected user 7, got %d (%v)", userID, err)
+	}
+	if _, err := ParseAccessToken(token); err == nil {
+		t.Error("An mfa pending token must not be accepted as an access token")
+	}
+
+	access, _ := IssueAccessToken(7, 0)
+	if _, err := ParseMFAToken(access); err == nil {
+		t.Error("An access token must not be accepted as an mfa pending token")
+	}
+}
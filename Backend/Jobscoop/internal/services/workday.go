@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// workdayPageSize is the most postings Workday returns per request
+	workdayPageSize = 20
+	// workdayMaxPages stops a search that keeps returning full pages
+	workdayMaxPages = 25
+)
+
+func init() {
+	RegisterJobSource("workday", func() (JobSource, error) {
+		return &WorkdaySource{}, nil
+	})
+}
+
+// WorkdaySource searches the Workday career sites (*.myworkdayjobs.com) among a query's career
+// sites for the query's role. Each site is its own host, BaseURL replaces all of them, in tests
+// for example.
+type WorkdaySource struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// WorkdaySite is a career site hosted by Workday, such as https://acme.wd5.myworkdayjobs.com/en-US/External
+type WorkdaySite struct {
+	Host   string
+	Tenant string
+	Site   string
+}
+
+var (
+	workdayHost   = regexp.MustCompile(`^([a-z0-9-]+)\.wd\d+\.myworkdayjobs\.com$`)
+	workdayLocale = regexp.MustCompile(`^[a-z]{2}(-[A-Za-z]{2})?$`)
+	workdayDays   = regexp.MustCompile(`^Posted (\d+) Days? Ago$`)
+)
+
+// workdaySearch is the body of POST /wday/cxs/{tenant}/{site}/jobs
+type workdaySearch struct {
+	AppliedFacets map[string][]string `json:"appliedFacets"`
+	Limit         int                 `json:"limit"`
+	Offset        int                 `json:"offset"`
+	SearchText    string              `json:"searchText"`
+}
+
+// workdayResults is a page of search results. Total is only reliable on the first page.
+type workdayResults struct {
+	Total       int `json:"total"`
+	JobPostings []struct {
+		Title         string   `json:"title"`
+		ExternalPath  string   `json:"externalPath"`
+		LocationsText string   `json:"locationsText"`
+		PostedOn      string   `json:"postedOn"`
+		BulletFields  []string `json:"bulletFields"`
+	} `json:"jobPostings"`
+}
+
+// ParseWorkdaySite returns the tenant and site of a Workday career site link. The site follows
+// an optional locale in the path.
+func ParseWorkdaySite(link string) (WorkdaySite, bool) {
+	u, ok := parseCareerLink(link)
+	if !ok {
+		return WorkdaySite{}, false
+	}
+	host := strings.ToLower(u.Hostname())
+	m := workdayHost.FindStringSubmatch(host)
+	if m == nil {
+		return WorkdaySite{}, false
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) > 1 && workdayLocale.MatchString(segments[0]) {
+		segments = segments[1:]
+	}
+	site := WorkdaySite{Host: host, Tenant: m[1], Site: segments[0]}
+	return site, site.Site != ""
+}
+
+// Name implements JobSource.
+func (s *WorkdaySource) Name() string { return "workday" }
+
+// Search implements JobSource. Queries without a Workday career site have nothing to search.
+func (s *WorkdaySource) Search(ctx context.Context, q JobQuery) ([]Job, error) {
+	var jobs []Job
+	seen := map[WorkdaySite]bool{}
+	for _, link := range q.CareerSites {
+		site, ok := ParseWorkdaySite(link)
+		if !ok || seen[site] {
+			continue
+		}
+		seen[site] = true
+
+		found, err := s.search(ctx, site, q)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, found...)
+	}
+	return jobs, nil
+}
+
+// search pages through the postings of a site that match the query's role
+func (s *WorkdaySource) search(ctx context.Context, site WorkdaySite, q JobQuery) ([]Job, error) {
+	base := s.BaseURL
+	if base == "" {
+		base = "https://" + site.Host
+	}
+	endpoint := base + "/wday/cxs/" + url.PathEscape(site.Tenant) + "/" + url.PathEscape(site.Site) + "/jobs"
+	now := time.Now().UTC()
+
+	var jobs []Job
+	total := 0
+	for page := 0; page < workdayMaxPages; page++ {
+		body := workdaySearch{
+			AppliedFacets: map[string][]string{},
+			Limit:         workdayPageSize,
+			Offset:        page * workdayPageSize,
+			SearchText:    q.Role,
+		}
+		var resp workdayResults
+		if err := postJSON(ctx, s.HTTPClient, endpoint, body, &resp); err != nil {
+			return nil, err
+		}
+		if page == 0 {
+			total = resp.Total
+		}
+		for _, p := range resp.JobPostings {
+			job := Job{
+				Source:   s.Name(),
+				ID:       site.Tenant + p.ExternalPath,
+				Title:    p.Title,
+				Company:  q.Company,
+				Location: p.LocationsText,
+				URL:      "https://" + site.Host + "/" + site.Site + p.ExternalPath,
+				PostedAt: workdayPostedAt(p.PostedOn, now),
+			}
+			jobs = append(jobs, job)
+		}
+		if len(resp.JobPostings) < workdayPageSize || len(jobs) >= total {
+			break
+		}
+	}
+	return jobs, nil
+}
+
+// workdayPostedAt turns Workday's relative posting date, such as "Posted 3 Days Ago", into the
+// day it was posted. "Posted 30+ Days Ago" is too vague to keep.
+func workdayPostedAt(postedOn string, now time.Time) *time.Time {
+	days := -1
+	switch postedOn {
+	case "Posted Today":
+		days = 0
+	case "Posted Yesterday":
+		days = 1
+	default:
+		if m := workdayDays.FindStringSubmatch(postedOn); m != nil {
+			days, _ = strconv.Atoi(m[1])
+		}
+	}
+	if days < 0 {
+		return nil
+	}
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -days)
+	return &day
+}
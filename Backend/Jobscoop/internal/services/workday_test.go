@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseWorkdaySite(t *testing.T) {
+	tests := []struct {
+		link string
+		site WorkdaySite
+		ok   bool
+	}{
+		{"https://acme.wd5.myworkdayjobs.com/External", WorkdaySite{"acme.wd5.myworkdayjobs.com", "acme", "External"}, true},
+		{"https://acme.wd5.myworkdayjobs.com/en-US/External", WorkdaySite{"acme.wd5.myworkdayjobs.com", "acme", "External"}, true},
+		{"https://Acme.wd1.myworkdayjobs.com/en-US/Careers/job/Austin-TX/Engineer_R1", WorkdaySite{"acme.wd1.myworkdayjobs.com", "acme", "Careers"}, true},
+		{"acme.wd12.myworkdayjobs.com/fr/External", WorkdaySite{"acme.wd12.myworkdayjobs.com", "acme", "External"}, true},
+		{"https://acme.wd5.myworkdayjobs.com/", WorkdaySite{}, false},
+		{"https://acme.myworkdayjobs.com/External", WorkdaySite{}, false},
+		{"https://jobs.lever.co/acme", WorkdaySite{}, false},
+	}
+	for _, tt := range tests {
+		site, ok := ParseWorkdaySite(tt.link)
+		if ok != tt.ok || (ok && site != tt.site) {
+			t.Errorf("ParseWorkdaySite(%q) = %+v, %v; want %+v, %v", tt.link, site, ok, tt.site, tt.ok)
+		}
+	}
+}
+
+func TestWorkdaySourceSearch(t *testing.T) {
+	var searches []workdaySearch
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/wday/cxs/acme/External/jobs" {
+			http.NotFound(w, r)
+			return
+		}
+		var search workdaySearch
+		if err := json.NewDecoder(r.Body).Decode(&search); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		searches = append(searches, search)
+		switch search.Offset {
+		case 0:
+			http.ServeFile(w, r, "testdata/workday_jobs_page1.json")
+		case 20:
+			http.ServeFile(w, r, "testdata/workday_jobs_page2.json")
+		default:
+			w.Write([]byte(`{"total": 0, "jobPostings": []}`))
+		}
+	}))
+	defer server.Close()
+
+	source := &WorkdaySource{BaseURL: server.URL}
+	jobs, err := source.Search(context.Background(), JobQuery{
+		Company:     "Acme",
+		Role:        "Software Engineer",
+		CareerSites: []string{"https://acme.wd5.myworkdayjobs.com/en-US/External", "https://acme.wd5.myworkdayjobs.com/External"},
+	})
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if len(searches) != 2 || searches[1].Offset != 20 || searches[1].Limit != 20 || searches[0].SearchText != "Software Engineer" {
+		t.Errorf("Expected two pages of the role's search, got %+v", searches)
+	}
+	if len(jobs) != 21 {
+		t.Fatalf("Expected the postings of both pages, got %d", len(jobs))
+	}
+
+	first := jobs[0]
+	if first.Source != "workday" || first.ID != "acme/job/Austin-TX/Software-Engineer-II_R10001" || first.Title != "Software Engineer II" ||
+		first.Company != "Acme" || first.Location != "Austin, TX" ||
+		first.URL != "https://acme.wd5.myworkdayjobs.com/External/job/Austin-TX/Software-Engineer-II_R10001" {
+		t.Errorf("Unexpected job: %+v", first)
+	}
+	if first.PostedAt == nil || jobs[3].PostedAt != nil {
+		t.Errorf("Expected exact posting days only, got %v and %v", first.PostedAt, jobs[3].PostedAt)
+	}
+	if jobs[20].Title != "Software Engineer, Observability" {
+		t.Errorf("Unexpected last job: %+v", jobs[20])
+	}
+}
+
+func TestWorkdayPostedAt(t *testing.T) {
+	now := time.Date(2024, 5, 10, 16, 30, 0, 0, time.UTC)
+	tests := []struct {
+		postedOn string
+		want     string
+	}{
+		{"Posted Today", "2024-05-10"},
+		{"Posted Yesterday", "2024-05-09"},
+		{"Posted 1 Day Ago", "2024-05-09"},
+		{"Posted 12 Days Ago", "2024-04-28"},
+		{"Posted 30+ Days Ago", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got := workdayPostedAt(tt.postedOn, now)
+		if (got == nil) != (tt.want == "") || (got != nil && got.Format("2006-01-02") != tt.want) {
+			t.Errorf("workdayPostedAt(%q) = %v; want %q", tt.postedOn, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package worker
+
+import (
+	"JobScoop/internal/models"
+	"JobScoop/internal/services"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxAttempts   = 5
+	defaultBaseDelay     = time.Minute
+	defaultMaxDelay      = time.Hour
+	defaultLease         = 10 * time.Minute
+	defaultConcurrency   = 4
+	defaultRefresh       = 6 * time.Hour
+	defaultScheduleEvery = time.Minute
+	defaultRetention     = 30 * 24 * time.Hour
+)
+
+var (
+	searchSourceFunc = services.SearchSource
+	saveJobFunc      = models.SaveJob
+)
+
+// Worker runs the fetch tasks queued in the fetch_tasks table: each searches one job source for
+// one company and role and stores the postings found. A task that fails is retried with
+// exponential backoff; after MaxAttempts it waits for its next refresh. Several workers can share
+// the queue, a task is held by one of them at a time.
+type Worker struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Lease is how long a claimed task stays with its worker before another may retry it, and
+	// how long its search may take
+	Lease time.Duration
+	// Concurrency is how many tasks are claimed and run at once
+	Concurrency int
+	// Refresh is how long after a successful fetch a company and role are fetched again
+	Refresh time.Duration
+	// ScheduleEvery is how often tasks are added for new subscriptions and dropped for old ones
+	ScheduleEvery time.Duration
+	// Retention is how long postings no source lists any more are kept
+	Retention time.Duration
+}
+
+// NewWorker returns a Worker with the default settings, running WORKER_CONCURRENCY tasks at once
+// and refreshing every WORKER_REFRESH_HOURS hours when they are set.
+func NewWorker() *Worker {
+	w := &Worker{
+		MaxAttempts:   defaultMaxAttempts,
+		BaseDelay:     defaultBaseDelay,
+		MaxDelay:      defaultMaxDelay,
+		Lease:         defaultLease,
+		Concurrency:   defaultConcurrency,
+		Refresh:       defaultRefresh,
+		ScheduleEvery: defaultScheduleEvery,
+		Retention:     defaultRetention,
+	}
+	if n, err := strconv.Atoi(os.Getenv("WORKER_CONCURRENCY")); err == nil && n > 0 {
+		w.Concurrency = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("WORKER_REFRESH_HOURS")); err == nil && n > 0 {
+		w.Refresh = time.Duration(n) * time.Hour
+	}
+	return w
+}
+
+// Backoff returns the wait after the given number of failed attempts: BaseDelay, doubling
+// after each further failure, up to MaxDelay.
+func (w *Worker) Backoff(attempts int) time.Duration {
+	delay := w.BaseDelay
+	for i := 1; i < attempts && delay < w.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > w.MaxDelay {
+		delay = w.MaxDelay
+	}
+	return delay
+}
+
+// Schedule queues the companies and roles of active subscriptions for every enabled source, and
+// the subscriptions themselves for the sources that read their unchecked links.
+func (w *Worker) Schedule(now time.Time) error {
+	var sources, subscriptionSources []string
+	for _, source := range services.JobSources() {
+		sources = append(sources, source.Name())
+		if services.SubscriptionSource(source.Name()) {
+			subscriptionSources = append(subscriptionSources, source.Name())
+		}
+	}
+	added, removed, err := models.ScheduleFetchTasks(sources, subscriptionSources, now)
+	if err != nil {
+		return err
+	}
+	if added > 0 || removed > 0 {
+		log.Printf("Fetch tasks scheduled: %d added, %d removed", added, removed)
+	}
+	return nil
+}
+
+// RunDue claims up to Concurrency due tasks, runs them and returns how many were claimed.
+func (w *Worker) RunDue(ctx context.Context, now time.Time) (int, error) {
+	tasks, err := models.ClaimDueFetchTasks(now, w.Concurrency, w.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	errs := make([]error, len(tasks))
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func(i int, task models.FetchTask) {
+			defer wg.Done()
+			errs[i] = w.run(ctx, task)
+		}(i, task)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return len(tasks), err
+		}
+	}
+	return len(tasks), nil
+}
+
+// run searches the task's source and stores what it found, then records the outcome. Only a
+// failure to record it is returned, the search failing is the task's.
+func (w *Worker) run(ctx context.Context, task models.FetchTask) error {
+	ctx, cancel := context.WithTimeout(ctx, w.Lease)
+	defer cancel()
+
+	err := w.fetch(ctx, task)
+	now := time.Now().UTC()
+	var held bool
+	if err == nil {
+		held, err = models.CompleteFetchTask(task.ID, task.Attempts, now, now.Add(w.Refresh))
+	} else {
+		giveUp := task.Attempts >= w.MaxAttempts
+		retryAt := now.Add(w.Backoff(task.Attempts))
+		if giveUp {
+			log.Printf("Fetching %s jobs at %s from %s failed %d times, waiting for the next refresh: %v",
+				task.Role, task.Company, task.Source, task.Attempts, err)
+			retryAt = now.Add(w.Refresh)
+		}
+		held, err = models.FailFetchTask(task.ID, task.Attempts, err.Error(), retryAt, giveUp)
+	}
+	if err != nil {
+		return err
+	}
+	if !held {
+		log.Printf("Fetch task %d outlived its lease, another worker has it", task.ID)
+	}
+	return nil
+}
+
+// fetch searches the task's source and stores the postings found. A task shared by the company's
+// subscribers only searches its verified career sites; a subscription's own task searches the
+// others and its feeds, and what it finds is stored for that subscription only.
+func (w *Worker) fetch(ctx context.Context, task models.FetchTask) error {
+	q := services.JobQuery{Company: task.Company, Role: task.Role, Feeds: task.Feeds, SubscriptionID: task.SubscriptionID}
+	for _, link := range task.CareerSites {
+		if services.VerifiedCareerSite(link) == (task.SubscriptionID == 0) {
+			q.CareerSites = append(q.CareerSites, link)
+		}
+	}
+	if task.SubscriptionID != 0 && len(q.CareerSites) == 0 && len(q.Feeds) == 0 {
+		return nil
+	}
+	jobs, err := searchSourceFunc(ctx, task.Source, q)
+	if err != nil {
+		return err
+	}
+	seen := time.Now().UTC()
+	for _, job := range jobs {
+		if _, err := saveJobFunc(storedJob(task.CompanyID, task.SubscriptionID, job), job.Aggregated(), seen); err != nil {
+			return fmt.Errorf("storing %s: %w", job.Key(), err)
+		}
+	}
+	return nil
+}
+
+// Run schedules and runs due tasks every interval until ctx is cancelled, and drops the postings
+// no source has listed for longer than Retention.
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var scheduledAt, purgedAt time.Time
+	for {
+		now := time.Now().UTC()
+		if now.Sub(scheduledAt) >= w.ScheduleEvery {
+			if err := w.Schedule(now); err != nil {
+				log.Printf("Failed to schedule fetch tasks: %v", err)
+			}
+			scheduledAt = now
+		}
+		// Keep going while full batches come back, there is more waiting
+		for {
+			claimed, err := w.RunDue(ctx, time.Now().UTC())
+			if err != nil {
+				log.Printf("Failed to run fetch tasks: %v", err)
+				break
+			}
+			if claimed < w.Concurrency || ctx.Err() != nil {
+				break
+			}
+		}
+		if now.Sub(purgedAt) >= time.Hour {
+			if _, err := models.PurgeJobs(now.Add(-w.Retention)); err != nil {
+				log.Printf("Failed to purge old jobs: %v", err)
+			}
+			purgedAt = now
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// storedJob maps a posting found by a source to its row under companyID, and subscriptionID
+// when only that subscription's links listed it
+func storedJob(companyID, subscriptionID int, job services.Job) models.Job {
+	return models.Job{
+		CompanyID:      companyID,
+		SubscriptionID: subscriptionID,
+		DedupKey:       job.DedupKey(),
+		Source:         job.Source,
+		ExternalID:     job.ExternalID(),
+		CanonicalURL:   services.CanonicalURL(job.URL),
+		Title:          job.Title,
+		Location:       job.Location,
+		PostedAt:       job.PostedAt,
+	}
+}
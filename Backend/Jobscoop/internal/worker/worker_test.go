@@ -0,0 +1,164 @@
+package worker
+
+import (
+	"JobScoop/internal/db"
+	"JobScoop/internal/models"
+	"JobScoop/internal/services"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestWorkerBackoff(t *testing.T) {
+	w := &Worker{BaseDelay: time.Minute, MaxDelay: time.Hour}
+	for attempts, want := range map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		4:  8 * time.Minute,
+		7:  time.Hour,
+		50: time.Hour,
+	} {
+		if got := w.Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestWorkerSchedule(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+	defer services.SetJobSources()
+
+	services.SetJobSources(&fakeSource{name: "greenhouse"}, &fakeSource{name: "linkedin"}, &fakeSource{name: "feed"})
+	now := time.Now().UTC()
+	// Feeds are read for each subscription on its own
+	mock.ExpectExec("INSERT INTO fetch_tasks \\(company_id, role_id, source, subscription_id, priority, next_attempt_at\\)").
+		WithArgs(pq.Array([]string{"greenhouse", "linkedin", "feed"}), pq.Array([]string{"feed"}), models.FetchPriorityFirst, now).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec("DELETE FROM fetch_tasks\\s+WHERE status <> 'running' AND \\(source <> ALL\\(\\$1\\)").
+		WithArgs(pq.Array([]string{"greenhouse", "linkedin", "feed"}), pq.Array([]string{"feed"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := NewWorker().Schedule(now); err != nil {
+		t.Fatalf("Schedule returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestWorkerRunDue(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("Error initializing mock database: %v", err)
+	}
+	defer mockDB.Close()
+	db.DB = mockDB
+	mock.MatchExpectationsInOrder(false)
+
+	originalSearch, originalSave := searchSourceFunc, saveJobFunc
+	defer func() { searchSourceFunc, saveJobFunc = originalSearch, originalSave }()
+
+	searchSourceFunc = func(ctx context.Context, source string, q services.JobQuery) ([]services.Job, error) {
+		switch source {
+		case "linkedin":
+			return nil, errors.New("quota exceeded")
+		case "greenhouse":
+			// Only the ATS board is searched for every subscriber
+			if q.Company != "Acme" || q.Role != "Backend Engineer" || len(q.CareerSites) != 1 || q.CareerSites[0] != "https://boards.greenhouse.io/acme" ||
+				len(q.Feeds) != 0 || q.SubscriptionID != 0 {
+				t.Errorf("Unexpected query %+v", q)
+			}
+			return []services.Job{
+				{Source: source, ID: "4", Title: "Backend Engineer", Company: "Acme", Location: "Berlin, Germany", URL: "https://boards.greenhouse.io/acme/jobs/4?gh_src=x"},
+			}, nil
+		case "feed":
+			// The subscription's own page and feed are searched for it
+			if len(q.CareerSites) != 1 || q.CareerSites[0] != "https://acme.example/careers" || len(q.Feeds) != 1 || q.SubscriptionID != 5 {
+				t.Errorf("Unexpected query %+v", q)
+			}
+			return []services.Job{
+				{Source: source, ID: "https://jobs.example/7", Title: "Backend Engineer", Company: "Acme", URL: "https://jobs.example/7"},
+			}, nil
+		}
+		t.Errorf("Unexpected search of %s with %+v", source, q)
+		return nil, nil
+	}
+	var mu sync.Mutex
+	var saved []models.Job
+	saveJobFunc = func(job models.Job, aggregated bool, seen time.Time) (models.Job, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		saved = append(saved, job)
+		return job, nil
+	}
+
+	w := NewWorker()
+	w.Concurrency = 5
+	now := time.Now().UTC()
+	mock.ExpectQuery("UPDATE fetch_tasks SET status = 'running', locked_until = \\$3, attempts = attempts \\+ 1").
+		WithArgs(now, 5, now.Add(w.Lease)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "company_id", "role_id", "source", "subscription_id", "attempts", "company", "role", "career_sites", "feeds"}).
+			AddRow(1, 7, 2, "greenhouse", 0, 1, "Acme", "Backend Engineer", "{https://acme.example/careers,https://boards.greenhouse.io/acme}", "{}").
+			AddRow(2, 7, 2, "linkedin", 0, 2, "Acme", "Backend Engineer", "{https://acme.example/careers,https://boards.greenhouse.io/acme}", "{}").
+			AddRow(3, 7, 2, "linkedin", 0, w.MaxAttempts, "Acme", "Backend Engineer", "{}", "{}").
+			AddRow(4, 7, 2, "feed", 5, 1, "Acme", "Backend Engineer", "{https://acme.example/careers,https://boards.greenhouse.io/acme}", "{https://acme.example/jobs.rss}").
+			// Nothing of its own to search
+			AddRow(5, 7, 2, "feed", 6, 1, "Acme", "Backend Engineer", "{https://boards.greenhouse.io/acme}", "{}"))
+
+	// The fetches are recorded and the tasks refreshed later
+	for _, id := range []int{1, 4, 5} {
+		mock.ExpectExec("UPDATE fetch_tasks\\s+SET status = 'pending', attempts = 0, priority = \\$1").
+			WithArgs(models.FetchPriorityRefresh, sqlmock.AnyArg(), sqlmock.AnyArg(), id, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	// A failure is retried after the backoff, until the attempts run out
+	mock.ExpectExec("UPDATE fetch_tasks\\s+SET status = 'pending', attempts = CASE WHEN \\$1").
+		WithArgs(false, sqlmock.AnyArg(), "quota exceeded", 2, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE fetch_tasks\\s+SET status = 'pending', attempts = CASE WHEN \\$1").
+		WithArgs(true, sqlmock.AnyArg(), "quota exceeded", 3, w.MaxAttempts).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	claimed, err := w.RunDue(context.Background(), now)
+	if err != nil || claimed != 5 {
+		t.Fatalf("Expected five tasks run, got %d (%v)", claimed, err)
+	}
+	if len(saved) != 2 {
+		t.Fatalf("Expected two postings stored, got %+v", saved)
+	}
+	for _, job := range saved {
+		switch job.Source {
+		case "greenhouse":
+			if job.CompanyID != 7 || job.SubscriptionID != 0 || job.ExternalID != "4" ||
+				job.CanonicalURL != "https://boards.greenhouse.io/acme/jobs/4" || job.DedupKey != "backend engineer|berlin" {
+				t.Errorf("Expected the board's posting stored under company 7 for everyone, got %+v", job)
+			}
+		case "feed":
+			if job.CompanyID != 7 || job.SubscriptionID != 5 {
+				t.Errorf("Expected the feed's posting stored for subscription 5 only, got %+v", job)
+			}
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// fakeSource is an enabled source that finds nothing
+type fakeSource struct{ name string }
+
+func (f *fakeSource) Name() string { return f.name }
+
+func (f *fakeSource) Search(ctx context.Context, q services.JobQuery) ([]services.Job, error) {
+	return nil, nil
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"JobScoop/internal/db" // Import the db package
+	"JobScoop/internal/handlers"
+	"JobScoop/internal/mailer"
 	"JobScoop/internal/models"
+	"JobScoop/internal/services"
 	"JobScoop/routes" // Import the routes package (where you define your routes)
 	"context"
 	"fmt"
@@ -24,6 +27,20 @@ func main() {
 		}
 	}()
 
+	// Load the token signing keys
+	if err := services.LoadKeySetFromEnv(); err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	if err := services.LoadOIDCProvidersFromEnv(); err != nil {
+		log.Fatalf("Failed to load OIDC providers: %v", err)
+	}
+	if err := services.LoadScraperConfigsFromEnv(); err != nil {
+		log.Fatalf("Failed to load scraper configs: %v", err)
+	}
+	if err := mailer.LoadFromEnv(); err != nil {
+		log.Fatalf("Failed to configure the mailer: %v", err)
+	}
+
 	// Create tables
 	models.CreateUserTable()
 	models.CreateResetTokensTable()
@@ -31,6 +48,33 @@ func main() {
 	models.CreateCareerSiteTable()
 	models.CreateRoleTable()
 	models.CreateSubscriptionTable()
+	models.CreateRefreshTokensTable()
+	models.CreateRevokedTokensTable()
+	models.CreateLoginThrottlesTable()
+	models.CreateLoginAttemptsTable()
+	models.CreateRecoveryCodesTable()
+	models.CreateIdentitiesTable()
+	models.CreateOIDCLoginStatesTable()
+	models.CreateAPIKeysTable()
+	models.CreateEmailOutboxTable()
+	models.CreateJobsTable()
+	models.CreateSentJobsTable()
+	models.PromoteAdminsFromEnv()
+
+	// Background jobs run until the server shuts down
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Purge accounts whose deletion grace period is over
+	go purgeDeletedAccounts(bgCtx, time.Hour)
+
+	// Handlers queue their emails, a background sender delivers and retries them
+	outbox := mailer.NewOutbox()
+	mailer.SetQueue(outbox)
+	go outbox.Run(bgCtx, 10*time.Second)
+
+	// Mail job alert digests to subscribers
+	go sendDigests(bgCtx, 15*time.Minute)
 
 	// Register your routes
 	router := routes.RegisterRoutes()
@@ -65,3 +109,55 @@ func main() {
 
 	fmt.Println("Server exiting")
 }
+
+// purgeDeletedAccounts permanently removes deleted accounts once their grace period is over, and
+// the revoked access tokens that have expired, checking every interval until ctx is cancelled.
+func purgeDeletedAccounts(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := models.PurgeDeletedUsers(time.Now().UTC().Add(-models.AccountDeletionGrace()))
+		if err != nil {
+			fmt.Println("Failed to purge deleted accounts:", err)
+		} else if purged > 0 {
+			fmt.Println("Purged deleted accounts:", purged)
+		}
+		if _, err := models.PurgeRevokedTokens(time.Now().UTC()); err != nil {
+			fmt.Println("Failed to purge revoked tokens:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sentJobsRetention is how long a mailed posting is remembered, well past the week searched
+const sentJobsRetention = 90 * 24 * time.Hour
+
+// sendDigests queues the job alert digests that are due every interval until ctx is cancelled.
+// Instant digests go out on every run, so interval is also their delay.
+func sendDigests(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now().UTC()
+		sent, err := handlers.SendDueDigests(now)
+		if err != nil {
+			fmt.Println("Failed to send job digests:", err)
+		} else if sent > 0 {
+			fmt.Println("Queued job digests:", sent)
+		}
+		if _, err := models.PurgeSentJobs(now.Add(-sentJobsRetention)); err != nil {
+			fmt.Println("Failed to purge sent jobs:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
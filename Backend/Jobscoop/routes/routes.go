@@ -28,29 +28,154 @@ func RegisterRoutes() *mux.Router {
 	router.HandleFunc("/reset-password", user.ResetPasswordHandler).Methods(http.MethodPut)
 	router.HandleFunc("/reset-password", user.ResetPasswordHandler).Methods(http.MethodOptions)
 
-	router.HandleFunc("/save-subscriptions", subscription.SaveSubscriptionsHandler).Methods(http.MethodPost)
-	router.HandleFunc("/save-subscriptions", subscription.SaveSubscriptionsHandler).Methods(http.MethodOptions)
+	router.HandleFunc("/verify-email", user.VerifyEmailHandler).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/verify-email", user.VerifyEmailHandler).Methods(http.MethodOptions)
 
-	router.HandleFunc("/fetch-user-subscriptions", subscription.FetchUserSubscriptionsHandler).Methods(http.MethodPost)
-	router.HandleFunc("/fetch-user-subscriptions", subscription.FetchUserSubscriptionsHandler).Methods(http.MethodOptions)
+	router.HandleFunc("/confirm-email-change", user.ConfirmEmailChangeHandler).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/confirm-email-change", user.ConfirmEmailChangeHandler).Methods(http.MethodOptions)
 
-	router.HandleFunc("/update-subscriptions", subscription.UpdateSubscriptionsHandler).Methods(http.MethodPut)
-	router.HandleFunc("/update-subscriptions", subscription.UpdateSubscriptionsHandler).Methods(http.MethodOptions)
+	router.HandleFunc("/unsubscribe", user.UnsubscribeHandler).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/unsubscribe", user.UnsubscribeHandler).Methods(http.MethodOptions)
 
-	router.HandleFunc("/delete-subscriptions", subscription.DeleteSubscriptionsHandler).Methods(http.MethodPost)
-	router.HandleFunc("/delete-subscriptions", subscription.DeleteSubscriptionsHandler).Methods(http.MethodOptions)
+	router.HandleFunc("/.well-known/jwks.json", user.JWKSHandler).Methods(http.MethodGet)
+
+	router.HandleFunc("/auth/refresh", user.RefreshHandler).Methods(http.MethodPost)
+	router.HandleFunc("/auth/refresh", user.RefreshHandler).Methods(http.MethodOptions)
+
+	router.HandleFunc("/auth/2fa/verify", user.VerifyMFAHandler).Methods(http.MethodPost)
+	router.HandleFunc("/auth/2fa/verify", user.VerifyMFAHandler).Methods(http.MethodOptions)
+
+	router.HandleFunc("/auth/oidc/providers", user.ListOIDCProvidersHandler).Methods(http.MethodGet)
+	router.HandleFunc("/auth/oidc/providers", user.ListOIDCProvidersHandler).Methods(http.MethodOptions)
+
+	router.HandleFunc("/auth/oidc/{provider}/start", user.StartOIDCLoginHandler).Methods(http.MethodGet)
+	router.HandleFunc("/auth/oidc/{provider}/callback", user.OIDCCallbackHandler).Methods(http.MethodGet)
+	router.HandleFunc("/auth/oidc/exchange", user.ExchangeOIDCCodeHandler).Methods(http.MethodPost)
+	router.HandleFunc("/auth/oidc/exchange", user.ExchangeOIDCCodeHandler).Methods(http.MethodOptions)
 
 	router.HandleFunc("/fetch-all-subscriptions", subscription.FetchAllSubscriptionsHandler).Methods(http.MethodGet)
 	router.HandleFunc("/fetch-all-subscriptions", subscription.FetchAllSubscriptionsHandler).Methods(http.MethodOptions)
 
-	router.HandleFunc("/get-user", user.GetUser).Methods(http.MethodPost)
-	router.HandleFunc("/get-user", user.GetUser).Methods(http.MethodOptions)
+	// Routes that scripts may also call with a personal API key holding one of the listed scopes
+	withScope := func(h http.HandlerFunc, scopes ...string) http.Handler {
+		return middleware.AuthWithScope(scopes...)(h)
+	}
+
+	router.Handle("/save-subscriptions", withScope(subscription.SaveSubscriptionsHandler, middleware.ScopeSubscriptionsWrite)).Methods(http.MethodPost)
+	router.Handle("/save-subscriptions", withScope(subscription.SaveSubscriptionsHandler, middleware.ScopeSubscriptionsWrite)).Methods(http.MethodOptions)
+
+	router.Handle("/fetch-user-subscriptions", withScope(subscription.FetchUserSubscriptionsHandler, middleware.ScopeJobsRead, middleware.ScopeSubscriptionsWrite)).Methods(http.MethodPost)
+	router.Handle("/fetch-user-subscriptions", withScope(subscription.FetchUserSubscriptionsHandler, middleware.ScopeJobsRead, middleware.ScopeSubscriptionsWrite)).Methods(http.MethodOptions)
+
+	router.Handle("/update-subscriptions", withScope(subscription.UpdateSubscriptionsHandler, middleware.ScopeSubscriptionsWrite)).Methods(http.MethodPut)
+	router.Handle("/update-subscriptions", withScope(subscription.UpdateSubscriptionsHandler, middleware.ScopeSubscriptionsWrite)).Methods(http.MethodOptions)
+
+	router.Handle("/delete-subscriptions", withScope(subscription.DeleteSubscriptionsHandler, middleware.ScopeSubscriptionsWrite)).Methods(http.MethodPost)
+	router.Handle("/delete-subscriptions", withScope(subscription.DeleteSubscriptionsHandler, middleware.ScopeSubscriptionsWrite)).Methods(http.MethodOptions)
+
+	router.Handle("/subscriptions/jobs", withScope(jobs.GetAllJobs, middleware.ScopeJobsRead)).Methods(http.MethodPost)
+	router.Handle("/subscriptions/jobs", withScope(jobs.GetAllJobs, middleware.ScopeJobsRead)).Methods(http.MethodOptions)
+
+	// Everything below requires a valid access token; handlers read the user from the request context
+	protected := router.NewRoute().Subrouter()
+	protected.Use(middleware.Auth)
+
+	protected.HandleFunc("/auth/logout", user.LogoutHandler).Methods(http.MethodPost)
+	protected.HandleFunc("/auth/logout", user.LogoutHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/auth/logout-all", user.LogoutAllHandler).Methods(http.MethodPost)
+	protected.HandleFunc("/auth/logout-all", user.LogoutAllHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/auth/2fa/enroll", user.EnrollTOTPHandler).Methods(http.MethodPost)
+	protected.HandleFunc("/auth/2fa/enroll", user.EnrollTOTPHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/auth/2fa/confirm", user.ConfirmTOTPHandler).Methods(http.MethodPost)
+	protected.HandleFunc("/auth/2fa/confirm", user.ConfirmTOTPHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/auth/2fa/disable", user.DisableTOTPHandler).Methods(http.MethodPost)
+	protected.HandleFunc("/auth/2fa/disable", user.DisableTOTPHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/auth/oidc/{provider}/link", user.StartOIDCLinkHandler).Methods(http.MethodPost)
+	protected.HandleFunc("/auth/oidc/{provider}/link", user.UnlinkOIDCHandler).Methods(http.MethodDelete)
+	protected.HandleFunc("/auth/oidc/{provider}/link", user.StartOIDCLinkHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/api-keys", user.CreateAPIKeyHandler).Methods(http.MethodPost)
+	protected.HandleFunc("/api-keys", user.ListAPIKeysHandler).Methods(http.MethodGet)
+	protected.HandleFunc("/api-keys", user.ListAPIKeysHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/api-keys/{id}", user.RenameAPIKeyHandler).Methods(http.MethodPut)
+	protected.HandleFunc("/api-keys/{id}", user.RevokeAPIKeyHandler).Methods(http.MethodDelete)
+	protected.HandleFunc("/api-keys/{id}", user.RenameAPIKeyHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/account/login-activity", user.LoginActivityHandler).Methods(http.MethodGet)
+	protected.HandleFunc("/account/login-activity", user.LoginActivityHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/account/email", user.ChangeEmailHandler).Methods(http.MethodPost)
+	protected.HandleFunc("/account/email", user.ChangeEmailHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/account/digest", user.GetDigestSettingsHandler).Methods(http.MethodGet)
+	protected.HandleFunc("/account/digest", user.UpdateDigestSettingsHandler).Methods(http.MethodPut)
+	protected.HandleFunc("/account/digest", user.GetDigestSettingsHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/account/export", user.AccountExportHandler).Methods(http.MethodGet)
+	protected.HandleFunc("/account/export", user.AccountExportHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/account/delete", user.DeleteAccountHandler).Methods(http.MethodPost)
+	protected.HandleFunc("/account/delete", user.DeleteAccountHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/resend-verification", user.ResendVerificationHandler).Methods(http.MethodPost)
+	protected.HandleFunc("/resend-verification", user.ResendVerificationHandler).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/get-user", user.GetUser).Methods(http.MethodPost)
+	protected.HandleFunc("/get-user", user.GetUser).Methods(http.MethodOptions)
+
+	protected.HandleFunc("/update-user", user.UpdateUser).Methods(http.MethodPut)
+	protected.HandleFunc("/update-user", user.UpdateUser).Methods(http.MethodOptions)
+
+	// Admin routes additionally require the admin role
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.Auth, middleware.RequireAdmin)
+
+	admin.HandleFunc("/users", user.AdminListUsersHandler).Methods(http.MethodGet)
+	admin.HandleFunc("/users", user.AdminListUsersHandler).Methods(http.MethodOptions)
+
+	admin.HandleFunc("/users/{id}/disable", user.AdminSetUserDisabledHandler(true)).Methods(http.MethodPut)
+	admin.HandleFunc("/users/{id}/disable", user.AdminSetUserDisabledHandler(true)).Methods(http.MethodOptions)
+
+	admin.HandleFunc("/users/{id}/enable", user.AdminSetUserDisabledHandler(false)).Methods(http.MethodPut)
+	admin.HandleFunc("/users/{id}/enable", user.AdminSetUserDisabledHandler(false)).Methods(http.MethodOptions)
+
+	admin.HandleFunc("/users/{id}/admin", user.AdminSetUserAdminHandler).Methods(http.MethodPut)
+	admin.HandleFunc("/users/{id}/admin", user.AdminSetUserAdminHandler).Methods(http.MethodOptions)
+
+	admin.HandleFunc("/companies", user.AdminListCompaniesHandler).Methods(http.MethodGet)
+	admin.HandleFunc("/companies", user.AdminListCompaniesHandler).Methods(http.MethodOptions)
+
+	admin.HandleFunc("/companies/{id}", user.AdminRenameCompanyHandler).Methods(http.MethodPut)
+	admin.HandleFunc("/companies/{id}", user.AdminDeleteCompanyHandler).Methods(http.MethodDelete)
+	admin.HandleFunc("/companies/{id}", user.AdminRenameCompanyHandler).Methods(http.MethodOptions)
+
+	admin.HandleFunc("/companies/{id}/merge", user.AdminMergeCompanyHandler).Methods(http.MethodPost)
+	admin.HandleFunc("/companies/{id}/merge", user.AdminMergeCompanyHandler).Methods(http.MethodOptions)
+
+	admin.HandleFunc("/roles", user.AdminListRolesHandler).Methods(http.MethodGet)
+	admin.HandleFunc("/roles", user.AdminListRolesHandler).Methods(http.MethodOptions)
+
+	admin.HandleFunc("/roles/{id}", user.AdminRenameRoleHandler).Methods(http.MethodPut)
+	admin.HandleFunc("/roles/{id}", user.AdminDeleteRoleHandler).Methods(http.MethodDelete)
+	admin.HandleFunc("/roles/{id}", user.AdminRenameRoleHandler).Methods(http.MethodOptions)
+
+	admin.HandleFunc("/roles/{id}/merge", user.AdminMergeRoleHandler).Methods(http.MethodPost)
+	admin.HandleFunc("/roles/{id}/merge", user.AdminMergeRoleHandler).Methods(http.MethodOptions)
+
+	admin.HandleFunc("/emails", user.AdminListEmailsHandler).Methods(http.MethodGet)
+	admin.HandleFunc("/emails", user.AdminListEmailsHandler).Methods(http.MethodOptions)
 
-	router.HandleFunc("/update-user", user.UpdateUser).Methods(http.MethodPut)
-	router.HandleFunc("/update-user", user.UpdateUser).Methods(http.MethodOptions)
+	admin.HandleFunc("/emails/{id}/requeue", user.AdminRequeueEmailHandler).Methods(http.MethodPost)
+	admin.HandleFunc("/emails/{id}/requeue", user.AdminRequeueEmailHandler).Methods(http.MethodOptions)
 
-	router.HandleFunc("/subscriptions/jobs", jobs.GetAllJobs).Methods(http.MethodPost)
-	router.HandleFunc("/subscriptions/jobs", jobs.GetAllJobs).Methods(http.MethodOptions)
+	admin.HandleFunc("/scraper/test", user.AdminTestScraperHandler).Methods(http.MethodPost)
+	admin.HandleFunc("/scraper/test", user.AdminTestScraperHandler).Methods(http.MethodOptions)
 
 	return router
 }